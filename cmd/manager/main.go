@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"reflect"
 	"runtime"
@@ -17,9 +19,13 @@ import (
 	argocd "github.com/argoproj-labs/argocd-operator/pkg/apis"
 	"github.com/redhat-developer/gitops-operator/pkg/apis"
 	"github.com/redhat-developer/gitops-operator/pkg/controller"
+	argocdcontroller "github.com/redhat-developer/gitops-operator/pkg/controller/argocd"
+	"github.com/redhat-developer/gitops-operator/pkg/dependency"
 	"github.com/redhat-developer/gitops-operator/version"
 
 	console "github.com/openshift/api/console/v1"
+	operatorsv1 "github.com/operator-framework/api/pkg/operators/v1"
+	olmv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 	kubemetrics "github.com/operator-framework/operator-sdk/pkg/kube-metrics"
 	"github.com/operator-framework/operator-sdk/pkg/leader"
@@ -30,6 +36,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -48,6 +55,22 @@ var (
 )
 var log = logf.Log.WithName("cmd")
 
+// Leader election settings, by default the operator-sdk leader-for-life
+// election is used, which blocks until this replica becomes the leader
+var (
+	leaderElect             bool
+	leaderElectionID        = "gitops-operator-lock"
+	leaderElectionNamespace string
+)
+
+// printConsoleLinkHost, if set, makes the operator print the ConsoleLink it
+// would create for a route with this host and exit
+var printConsoleLinkHost string
+
+// validateOperatorsFile, if set, makes the operator validate the operators
+// listed in this file, in the format of the operators ConfigMap, and exit
+var validateOperatorsFile string
+
 func printVersion() {
 	log.Info(fmt.Sprintf("Operator Version: %s", version.Version))
 	log.Info(fmt.Sprintf("Go Version: %s", runtime.Version()))
@@ -73,8 +96,32 @@ func main() {
 	// controller-runtime)
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 
+	pflag.BoolVar(&leaderElect, "leader-elect", leaderElect, "Use the manager's lease based leader election instead of leader-for-life")
+	pflag.StringVar(&leaderElectionID, "leader-election-id", leaderElectionID, "Name of the resource used for leader election")
+	pflag.StringVar(&leaderElectionNamespace, "leader-election-namespace", leaderElectionNamespace, "Namespace of the resource used for leader election, defaults to the operator namespace")
+
+	pflag.StringVar(&printConsoleLinkHost, "print-console-link", "", "Print the ConsoleLink created for a route with this host, e.g. argocd.example.com/argocd, and exit")
+
+	pflag.StringVar(&validateOperatorsFile, "validate-operators", "", "Validate the operators listed in this file, in the format of the "+dependency.OperatorsConfigMapName+" ConfigMap, and exit")
+
 	pflag.Parse()
 
+	if printConsoleLinkHost != "" {
+		if err := printConsoleLink(printConsoleLinkHost); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if validateOperatorsFile != "" {
+		if err := validateOperators(validateOperatorsFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Use a zap logr.Logger implementation. If none of the zap
 	// flags are configured (or if the zap flag set is not being
 	// used), this defaults to a production zap logger.
@@ -101,18 +148,25 @@ func main() {
 	}
 
 	ctx := context.TODO()
-	// Become the leader before proceeding
-	err = leader.Become(ctx, "gitops-operator-lock")
-	if err != nil {
-		log.Error(err, "")
-		os.Exit(1)
+	if !leaderElect {
+		// Become the leader before proceeding
+		log.Info("Waiting to become the leader", "LeaderElectionID", leaderElectionID)
+		err = leader.Become(ctx, leaderElectionID)
+		if err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
 	}
 
 	// Set default manager options
 	options := manager.Options{
-		Namespace:          namespace,
-		MetricsBindAddress: fmt.Sprintf("%s:%d", metricsHost, metricsPort),
+		Namespace:               namespace,
+		MetricsBindAddress:      fmt.Sprintf("%s:%d", metricsHost, metricsPort),
+		LeaderElection:          leaderElect,
+		LeaderElectionID:        leaderElectionID,
+		LeaderElectionNamespace: leaderElectionNamespace,
 	}
+	log.Info("Leader election configured", "LeaderElection", leaderElect, "LeaderElectionID", leaderElectionID, "LeaderElectionNamespace", leaderElectionNamespace)
 
 	// Add support for MultiNamespace set in WATCH_NAMESPACE (e.g ns1,ns2)
 	// Note that this is not intended to be used for excluding namespaces, this is better done via a Predicate
@@ -140,6 +194,9 @@ func main() {
 
 	registerComponentOrExit(mgr, argocd.AddToScheme)
 	registerComponentOrExit(mgr, console.AddToScheme)
+	registerComponentOrExit(mgr, operatorsv1.AddToScheme)
+	registerComponentOrExit(mgr, olmv1alpha1.AddToScheme)
+	registerComponentOrExit(mgr, routev1.AddToScheme) // Adding the routev1 api
 
 	// Setup all Controllers
 	if err := controller.AddToManager(mgr); err != nil {
@@ -147,8 +204,6 @@ func main() {
 		os.Exit(1)
 	}
 
-	registerComponentOrExit(mgr, routev1.AddToScheme) // Adding the routev1 api
-
 	// Add the Metrics Service
 	addMetrics(ctx, cfg)
 
@@ -159,6 +214,59 @@ func main() {
 		log.Error(err, "Manager exited non-zero")
 		os.Exit(1)
 	}
+
+	// Only the leader manages the ConsoleLinks, a standby replica exiting
+	// must not remove them from under the leader
+	select {
+	case <-mgr.Elected():
+	default:
+		log.Info("Skipping cleanup on shutdown, this replica was not the leader")
+		return
+	}
+
+	// The manager's cache is stopped at this point, use a direct client for cleanup
+	c, err := client.New(cfg, client.Options{Scheme: mgr.GetScheme()})
+	if err != nil {
+		log.Error(err, "Failed to create client for shutdown cleanup")
+		os.Exit(1)
+	}
+	if err := argocdcontroller.Shutdown(c); err != nil {
+		log.Error(err, "Failed to clean up on shutdown")
+		os.Exit(1)
+	}
+}
+
+// printConsoleLink prints the ConsoleLink created for a route with host using
+// the configuration set in the environment, without connecting to a cluster
+func printConsoleLink(host string) error {
+	config, err := argocdcontroller.ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	link, err := argocdcontroller.BuildConsoleLink(host, config)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(link, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// validateOperators validates the operators listed in file, without
+// connecting to a cluster
+func validateOperators(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := dependency.ValidateOperators(string(data)); err != nil {
+		return fmt.Errorf("invalid operators in %s: %w", file, err)
+	}
+	fmt.Printf("%s is valid\n", file)
+	return nil
 }
 
 // addMetrics will create the Services and Service Monitors to allow the operator export the metrics by using
@@ -7,7 +7,9 @@ require (
 	github.com/go-logr/logr v0.1.0
 	github.com/google/go-cmp v0.4.0
 	github.com/openshift/api v3.9.1-0.20190916204813-cdbe64fb0c91+incompatible
+	github.com/operator-framework/api v0.3.8
 	github.com/operator-framework/operator-sdk v0.18.2
+	github.com/prometheus/client_golang v1.6.0
 	github.com/rakyll/statik v0.1.7
 	github.com/spf13/pflag v1.0.5
 	k8s.io/api v0.18.3
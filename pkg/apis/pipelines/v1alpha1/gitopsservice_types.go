@@ -1,6 +1,7 @@
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -16,9 +17,27 @@ type GitopsServiceSpec struct {
 
 // GitopsServiceStatus defines the observed state of GitopsService
 type GitopsServiceStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
-	// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+	// Conditions report the readiness of the operators the GitOps service
+	// depends on, e.g. ArgoCDOperatorReady
+	// +optional
+	Conditions []GitopsServiceCondition `json:"conditions,omitempty"`
+}
+
+// GitopsServiceCondition is the state of an operator the GitOps service depends on
+type GitopsServiceCondition struct {
+	// Type of the condition, e.g. ArgoCDOperatorReady
+	Type string `json:"type"`
+	// Status of the condition, one of True, False or Unknown
+	Status corev1.ConditionStatus `json:"status"`
+	// LastTransitionTime is when the condition last changed status
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Reason is a one-word CamelCase reason for the last transition
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable description of the last transition
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
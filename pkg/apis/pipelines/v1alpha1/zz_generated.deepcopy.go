@@ -14,7 +14,7 @@ func (in *GitopsService) DeepCopyInto(out *GitopsService) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -36,6 +36,23 @@ func (in *GitopsService) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitopsServiceCondition) DeepCopyInto(out *GitopsServiceCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitopsServiceCondition.
+func (in *GitopsServiceCondition) DeepCopy() *GitopsServiceCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(GitopsServiceCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GitopsServiceList) DeepCopyInto(out *GitopsServiceList) {
 	*out = *in
@@ -88,6 +105,13 @@ func (in *GitopsServiceSpec) DeepCopy() *GitopsServiceSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GitopsServiceStatus) DeepCopyInto(out *GitopsServiceStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]GitopsServiceCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -2,24 +2,33 @@ package argocd
 
 import (
 	"context"
-	"encoding/base64"
+	"encoding/json"
+	goerrors "errors"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	argoprojv1alpha1 "github.com/argoproj-labs/argocd-operator/pkg/apis/argoproj/v1alpha1"
 	"github.com/go-logr/logr"
 	console "github.com/openshift/api/console/v1"
 	routev1 "github.com/openshift/api/route/v1"
-	"github.com/rakyll/statik/fs"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -38,72 +47,328 @@ const (
 	argocdNS           = "argocd"
 	consoleLinkName    = "argocd"
 	argocdInstanceName = "argocd"
-	argocdRouteName    = "argocd-server"
+	serverRouteSuffix  = "-server"
+	grpcRouteSuffix    = "-grpc"
 	argocdKind         = "ArgoCD"
 	argocdGroup        = "argoproj.io"
+	routeKind          = "Route"
 	iconFilePath       = "/argo.png"
+	controllerName     = "argocd-controller"
+
+	// debugLevel and traceLevel are the verbosities of detailed and of high
+	// frequency log messages, enabled with --zap-level=1 and --zap-level=2
+	debugLevel = 1
+	traceLevel = 2
+
+	// consoleLinkResource is the resource name of ConsoleLinks in API errors
+	consoleLinkResource = "consolelinks"
+
+	// crdMissingReason is the reason of the event recorded when the
+	// controller is disabled because the ArgoCD CRD is not installed
+	crdMissingReason = "ArgoCDCRDMissing"
+
+	// consoleLinkCollisionReason is the reason of the event recorded on the
+	// ArgoCD instance when a ConsoleLink the operator doesn't manage already
+	// has the name of its link
+	consoleLinkCollisionReason = "ConsoleLinkCollision"
+
+	// invalidConsoleLinkReason is the reason of the event recorded on the
+	// ArgoCD instance when its ConsoleLink would not be valid
+	invalidConsoleLinkReason = "InvalidConsoleLink"
+
+	// collisionRequeueDelay is how long to wait before checking again
+	// whether a colliding ConsoleLink has been removed
+	collisionRequeueDelay = time.Minute
+
+	// cacheSyncRequeueDelay is how long to wait before checking again
+	// whether the cache of the manager has synced
+	cacheSyncRequeueDelay = time.Second
+
+	// managedByLabel identifies the ConsoleLinks created by this operator
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "gitops-operator"
+
+	// routeRequeueDelay is how long to wait before checking again for an
+	// argocd-server route which is enabled but has not been provisioned yet
+	routeRequeueDelay = 10 * time.Second
+
+	// hrefOverrideAnnotation on the ArgoCD instance holds a URL used for the
+	// ConsoleLink instead of the route host, e.g. behind an external gateway
+	hrefOverrideAnnotation = "gitops.redhat.com/console-link-href-override"
+
+	// routeNameAnnotation on the ArgoCD instance holds the name of the server
+	// route when it is not the default <instance>-server
+	routeNameAnnotation = "gitops.redhat.com/console-link-route-name"
+
+	// disabledAnnotation set to true on the ArgoCD instance removes its ConsoleLink
+	disabledAnnotation = "gitops.redhat.com/console-link-disabled"
+
+	// pausedAnnotation set to true on the ArgoCD instance stops the
+	// controller from changing its ConsoleLink, e.g. during maintenance
+	pausedAnnotation = "gitops.openshift.io/paused"
+
+	// caBundleAnnotation on the ConsoleLink references the ConfigMap holding
+	// the CA bundle trusted for the ArgoCD route, as <namespace>/<name>
+	caBundleAnnotation = "gitops.redhat.com/ca-bundle-configmap"
+
+	// argocdNamespaceAnnotation on the ConsoleLink records the namespace of
+	// the linked ArgoCD instance, so that a link left behind when ArgoCD
+	// moves to another namespace is replaced rather than patched
+	argocdNamespaceAnnotation = "gitops.redhat.com/argocd-namespace"
+
+	// extraLinkLabel marks the ConsoleLinks of the configured extra links
+	extraLinkLabel = "gitops.redhat.com/extra-link"
+
+	// injectTrustedCABundleLabel makes OpenShift inject the cluster trusted
+	// CA bundle into a ConfigMap
+	injectTrustedCABundleLabel = "config.openshift.io/inject-trusted-cabundle"
 )
 
 //go:generate statik --src ./img -f
-var image string
-
-func init() {
-	image = imageDataURL(base64.StdEncoding.EncodeToString(readStatikImage()))
-}
 
 // Add creates a new ArgoCD Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func Add(mgr manager.Manager) error {
-	return add(mgr, newReconciler(mgr))
+	if err := checkScheme(mgr.GetScheme()); err != nil {
+		return err
+	}
+	r, err := newReconciler(mgr)
+	if err != nil {
+		return err
+	}
+	return add(mgr, r)
+}
+
+// requiredKinds are the kinds the controller reads and writes, which must be
+// registered on the scheme of the manager
+var requiredKinds = []schema.GroupVersionKind{
+	argoprojv1alpha1.SchemeGroupVersion.WithKind(argocdKind),
+	routev1.GroupVersion.WithKind(routeKind),
+	console.GroupVersion.WithKind("ConsoleLink"),
+}
+
+// checkScheme returns an error naming the kinds required by the controller
+// which are not registered on scheme, so that the operator fails at startup
+// rather than on every reconcile
+func checkScheme(scheme *runtime.Scheme) error {
+	var missing []string
+	for _, gvk := range requiredKinds {
+		if !scheme.Recognizes(gvk) {
+			missing = append(missing, gvk.String())
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the scheme lacks the types of the ArgoCD controller, register them before adding it: %s", strings.Join(missing, ", "))
+	}
+	return nil
 }
 
 // newReconciler returns a new reconcile.Reconciler
-func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileArgoCD{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+func newReconciler(mgr manager.Manager) (*ReconcileArgoCD, error) {
+	config, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	r := newReconcilerFromConfig(mgr.GetClient(), mgr.GetScheme(), config)
+	r.recorder = mgr.GetEventRecorderFor(controllerName)
+	r.cacheSynced = cacheSyncedFunc(mgr.GetCache())
+	return r, nil
+}
+
+// cacheSyncedFunc returns a func reporting, without blocking, whether the
+// informers of c have started and synced
+func cacheSyncedFunc(c cache.Cache) func() bool {
+	stop := make(chan struct{})
+	close(stop)
+	return func() bool {
+		return c.WaitForCacheSync(stop)
+	}
+}
+
+// newReconcilerFromConfig returns a ReconcileArgoCD configured by config
+func newReconcilerFromConfig(c client.Client, scheme *runtime.Scheme, config Config) *ReconcileArgoCD {
+	return &ReconcileArgoCD{
+		client: c,
+		scheme: scheme,
+		config: config,
+		links:  newConsoleLinkRegistrar(c, config),
+
+		linkReady: newLinkReadyTracker(linkReadySeconds),
+	}
+}
+
+// Shutdown removes the ConsoleLink, and ConsoleCLIDownload, managed by the
+// controller if cleanup on shutdown has been enabled. c must not depend on
+// the manager's cache, which is stopped by then.
+func Shutdown(c client.Client) error {
+	config, err := ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	if !config.CleanupOnShutdown {
+		return nil
+	}
+	if err := removeConsoleLinks(c, config); err != nil {
+		return err
+	}
+	if config.CLIDownload {
+		return deleteCLIDownload(context.Background(), c, logs)
+	}
+	return nil
+}
+
+// removeConsoleLinks removes the ConsoleLinks managed by the controller on
+// shutdown
+func removeConsoleLinks(c client.Client, config Config) error {
+	var err error
+	if config.AllNamespaces {
+		logs.Info("Removing ConsoleLinks on shutdown")
+		err = c.DeleteAllOf(context.Background(), &console.ConsoleLink{}, client.MatchingLabels{managedByLabel: managedByValue})
+		if err != nil && !meta.IsNoMatchError(err) && !isConsoleLinkForbidden(err) {
+			return err
+		}
+		return nil
+	}
+	// the link is looked up by name as other operator instances may manage
+	// links in other namespaces, links the operator doesn't manage are kept
+	link := &console.ConsoleLink{}
+	err = c.Get(context.Background(), types.NamespacedName{Name: config.ConsoleLinkName}, link)
+	if err == nil {
+		if !isManaged(link) {
+			logs.Info("Keeping ConsoleLink not managed by the operator on shutdown", "ConsoleLink.Name", config.ConsoleLinkName)
+		} else {
+			logs.Info("Removing ConsoleLink on shutdown", "ConsoleLink.Name", config.ConsoleLinkName)
+			err = c.Delete(context.Background(), link)
+		}
+	}
+	if err != nil && !errors.IsNotFound(err) && !meta.IsNoMatchError(err) && !isConsoleLinkForbidden(err) {
+		return err
+	}
+	err = c.DeleteAllOf(context.Background(), &console.ConsoleLink{}, client.MatchingLabels{managedByLabel: managedByValue, extraLinkLabel: "true"})
+	if err != nil && !meta.IsNoMatchError(err) && !isConsoleLinkForbidden(err) {
+		return err
+	}
+	return nil
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
-func add(mgr manager.Manager, r reconcile.Reconciler) error {
+func add(mgr manager.Manager, r *ReconcileArgoCD) error {
 
 	reqLogger := logs.WithValues()
-	reqLogger.Info("Watching ArgoCD")
+	reqLogger.Info("Watching ArgoCD, reconciling only starts once this replica is the leader")
 
 	// Skip controller creation if ArgoCD CRD is not present
-	_, err := mgr.GetRESTMapper().RESTMapping(schema.GroupKind{
-		Group: argocdGroup,
-		Kind:  argocdKind,
-	})
-	if err != nil {
-		reqLogger.Error(err, "Unable to find ArgoCD CRD")
+	if !argoCDAPIAvailable(mgr.GetRESTMapper(), mgr.GetEventRecorderFor(controllerName), r.config.Namespace) {
 		return nil
 	}
 
 	// Create a new controller
-	c, err := controller.New("argocd-controller", mgr, controller.Options{Reconciler: r})
+	c, err := controller.New(controllerName, mgr, r.controllerOptions())
 	if err != nil {
 		return err
 	}
 
+	return watchResources(c, mgr.GetRESTMapper(), r)
+}
+
+// controllerOptions returns the options of the controller reconciling with r
+func (r *ReconcileArgoCD) controllerOptions() controller.Options {
+	return controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: r.config.MaxConcurrentReconciles,
+	}
+}
+
+// argoCDAPIAvailable reports whether the ArgoCD CRD is installed. If it is not,
+// the controller is disabled and a Warning event is recorded on the ArgoCD
+// namespace so that monitoring can alert on it rather than only logging it.
+func argoCDAPIAvailable(mapper meta.RESTMapper, recorder record.EventRecorder, namespace string) bool {
+	_, err := mapper.RESTMapping(schema.GroupKind{
+		Group: argocdGroup,
+		Kind:  argocdKind,
+	})
+	if err == nil {
+		return true
+	}
+	logs.Error(err, "Unable to find ArgoCD CRD, the ConsoleLink controller is disabled")
+	recorder.Eventf(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}, corev1.EventTypeWarning, crdMissingReason,
+		"The ConsoleLink controller is disabled: the %s.%s CRD is not installed", argocdKind, argocdGroup)
+	return false
+}
+
+// watchResources registers the watches of the controller. The argocd-server
+// route is only watched if the Route API is available, i.e. on OpenShift.
+func watchResources(c controller.Controller, mapper meta.RESTMapper, r *ReconcileArgoCD) error {
+	if r == nil {
+		return fmt.Errorf("the reconciler must be constructed before registering watches")
+	}
+
 	// Watch for changes to primary resource ArgoCD
-	err = c.Watch(&source.Kind{Type: &argoprojv1alpha1.ArgoCD{}}, &handler.EnqueueRequestForObject{}, filterPredicate(assertArgoCD))
+	err := c.Watch(&source.Kind{Type: &argoprojv1alpha1.ArgoCD{}}, &handler.EnqueueRequestForObject{}, NewNamespaceNamePredicate(r.config.isInstance), labelPredicate(r.config.InstanceSelector), changedPredicate())
 	if err != nil {
 		return err
 	}
 
+	// Skip the route watch if the Route API is not present
+	_, err = mapper.RESTMapping(schema.GroupKind{
+		Group: routev1.GroupName,
+		Kind:  routeKind,
+	})
+	if err != nil {
+		logs.Info("Unable to find Route API, watching the argocd-server Ingress and Service instead", "Error", err.Error())
+		return watchAlternateTargets(c, r)
+	}
+
 	// Watch for changes to argocd-server route in argocd namespace
 	// The ConsoleLink holds the route URL and should be regenerated when route is updated
-	err = c.Watch(&source.Kind{Type: &routev1.Route{}}, &handler.EnqueueRequestForOwner{
-		IsController: true,
-		OwnerType:    &argoprojv1alpha1.ArgoCD{},
-	}, filterPredicate(assertArgoCDRoute))
-	if err != nil {
-		return err
+	return c.Watch(&source.Kind{Type: &routev1.Route{}}, r.instanceHandler(), NewNamespaceNamePredicate(r.config.inNamespace))
+}
+
+// instanceHandler enqueues the configured ArgoCD instance for events of the
+// resources its ConsoleLink is built from, or the instances in their
+// namespace when all namespaces are linked. These are not necessarily owned
+// by the instance, so they are mapped to it rather than enqueued by owner.
+func (r *ReconcileArgoCD) instanceHandler() handler.EventHandler {
+	return &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			if !r.config.AllNamespaces {
+				return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: r.config.Namespace, Name: r.config.InstanceName}}}
+			}
+			return r.instancesIn(obj.Meta.GetNamespace())
+		}),
 	}
+}
 
+// instancesIn returns a request for each ArgoCD instance in namespace
+func (r *ReconcileArgoCD) instancesIn(namespace string) []reconcile.Request {
+	instances := &argoprojv1alpha1.ArgoCDList{}
+	if err := r.client.List(context.Background(), instances, client.InNamespace(namespace)); err != nil {
+		logs.Error(err, "Failed to list ArgoCD instances", "Namespace", namespace)
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(instances.Items))
+	for _, instance := range instances.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: instance.Namespace, Name: instance.Name}})
+	}
+	return requests
+}
+
+// watchAlternateTargets watches the argocd-server Ingress and Service the
+// ConsoleLink is built from when the Route API is not available
+func watchAlternateTargets(c controller.Controller, r *ReconcileArgoCD) error {
+	for _, obj := range []runtime.Object{&networkingv1beta1.Ingress{}, &corev1.Service{}} {
+		err := c.Watch(&source.Kind{Type: obj}, r.instanceHandler(), NewNamespaceNamePredicate(r.config.inNamespace))
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func filterPredicate(assert func(namespace, name string) bool) predicate.Funcs {
+// NewNamespaceNamePredicate returns a predicate filtering events by the
+// namespace and name of their object with assert. Updates which don't change
+// the resource version, e.g. resyncs, are filtered out too.
+func NewNamespaceNamePredicate(assert func(namespace, name string) bool) predicate.Funcs {
 	return predicate.Funcs{
 		UpdateFunc: func(e event.UpdateEvent) bool {
 			return assert(e.MetaNew.GetNamespace(), e.MetaNew.GetName()) &&
@@ -118,12 +383,59 @@ func filterPredicate(assert func(namespace, name string) bool) predicate.Funcs {
 	}
 }
 
-func assertArgoCD(namespace, name string) bool {
-	return namespace == argocdNS && argocdInstanceName == name
+// changedPredicate filters out updates which only change the status of the
+// ArgoCD instance. Spec changes bump the generation, labels and annotations
+// don't but the ConsoleLink depends on them too.
+func changedPredicate() predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration() ||
+				e.MetaOld.GetDeletionTimestamp().IsZero() != e.MetaNew.GetDeletionTimestamp().IsZero() ||
+				!equality.Semantic.DeepEqual(e.MetaOld.GetLabels(), e.MetaNew.GetLabels()) ||
+				!equality.Semantic.DeepEqual(e.MetaOld.GetAnnotations(), e.MetaNew.GetAnnotations())
+		},
+	}
 }
 
-func assertArgoCDRoute(namespace, name string) bool {
-	return namespace == argocdNS && argocdRouteName == name
+// labelPredicate filters out objects whose labels do not match selector.
+// Updates are let through if either the old or new labels match so that
+// removing the label is reconciled. A nil selector matches everything.
+func labelPredicate(selector labels.Selector) predicate.Funcs {
+	matches := func(meta metav1.Object) bool {
+		return selector == nil || selector.Matches(labels.Set(meta.GetLabels()))
+	}
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return matches(e.MetaOld) || matches(e.MetaNew)
+		},
+		CreateFunc: func(e event.CreateEvent) bool {
+			return matches(e.Meta)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return matches(e.Meta)
+		},
+	}
+}
+
+// isConsoleLinkDisabled reports whether the ConsoleLink has been disabled by
+// annotating the instance
+func isConsoleLinkDisabled(argocd *argoprojv1alpha1.ArgoCD) bool {
+	disabled, _ := strconv.ParseBool(argocd.Annotations[disabledAnnotation])
+	return disabled
+}
+
+// isReconcilePaused reports whether the paused annotation of the instance is true
+func isReconcilePaused(argocd *argoprojv1alpha1.ArgoCD) bool {
+	paused, _ := strconv.ParseBool(argocd.Annotations[pausedAnnotation])
+	return paused
+}
+
+// serverRouteName returns the name of the argocd-server route of the instance
+func serverRouteName(argocd *argoprojv1alpha1.ArgoCD) string {
+	if name := argocd.Annotations[routeNameAnnotation]; name != "" {
+		return name
+	}
+	return argocd.Name + serverRouteSuffix
 }
 
 // blank assignment to verify that ReconcileArgoCD implements reconcile.Reconciler
@@ -135,6 +447,32 @@ type ReconcileArgoCD struct {
 	// that reads objects from the cache and writes to the apiserver
 	client client.Client
 	scheme *runtime.Scheme
+
+	config Config
+
+	// links registers the link to the ArgoCD UI, as a ConsoleLink by default
+	links LinkRegistrar
+
+	// linkReady measures how long instances wait for their link
+	linkReady *linkReadyTracker
+
+	// instanceLinks holds the registrar of each ArgoCD instance when all
+	// namespaces are linked, links is used otherwise
+	mu            sync.Mutex
+	instanceLinks map[types.NamespacedName]LinkRegistrar
+
+	// instanceLocks serializes the reconciles of each ArgoCD instance, which
+	// would otherwise race on its ConsoleLink when several workers run
+	instanceLocks keyedMutex
+
+	// recorder, if set, records events on the ArgoCD instance, e.g. when
+	// its ConsoleLink collides with one the operator doesn't manage
+	recorder record.EventRecorder
+
+	// cacheSynced, if set, reports whether the cache read by client has
+	// synced. Reconciles are deferred until it has, as a cache still being
+	// populated reports existing objects as not found.
+	cacheSynced func() bool
 }
 
 // Reconcile reads that state of the cluster for a ArgoCD object and makes changes based on the state read
@@ -144,9 +482,27 @@ type ReconcileArgoCD struct {
 // Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
 func (r *ReconcileArgoCD) Reconcile(request reconcile.Request) (reconcile.Result, error) {
 	reqLogger := logs.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
-	reqLogger.Info("Reconciling ArgoCD")
+	reqLogger.V(debugLevel).Info("Reconciling ArgoCD")
 
-	ctx := context.Background()
+	if r.cacheSynced != nil && !r.cacheSynced() {
+		reqLogger.V(debugLevel).Info("Cache not synced yet, deferring reconcile")
+		return reconcile.Result{RequeueAfter: cacheSyncRequeueDelay}, nil
+	}
+
+	defer r.instanceLocks.lock(request.NamespacedName)()
+
+	ctx, summary := withReconcileSummary(context.Background())
+	result, err := r.reconcile(ctx, request, reqLogger)
+	if err == nil {
+		reqLogger.Info("Reconciled ArgoCD", "Action", summary.action, "Href", summary.href)
+	}
+	return result, err
+}
+
+// reconcile makes the link of the ArgoCD instance of request match its
+// state, recording what it did to the link in the summary of ctx
+func (r *ReconcileArgoCD) reconcile(ctx context.Context, request reconcile.Request, reqLogger logr.Logger) (reconcile.Result, error) {
+	links := r.linksFor(request.NamespacedName)
 
 	// Fetch the ArgoCD instance
 	argocdInstance := &argoprojv1alpha1.ArgoCD{}
@@ -154,58 +510,482 @@ func (r *ReconcileArgoCD) Reconcile(request reconcile.Request) (reconcile.Result
 	if err != nil {
 		if errors.IsNotFound(err) {
 			reqLogger.Info("ArgoCD instance not found")
+			r.linkReady.forget(request.NamespacedName)
 			// if argocd instance is deleted, remove the ConsoleLink if present
-			return reconcile.Result{}, r.deleteConsoleLinkIfPresent(ctx, reqLogger)
+			err := links.Unregister(ctx, reqLogger)
+			r.forgetLinks(request.NamespacedName)
+			return reconcile.Result{}, err
 		}
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
 
-	reqLogger.Info("ArgoCD instance found", "ArgoCD.Namespace:", argocdInstance.Namespace, "ArgoCD.Name", argocdInstance.Name)
+	reqLogger.V(debugLevel).Info("ArgoCD instance found", "ArgoCD.Namespace:", argocdInstance.Namespace, "ArgoCD.Name", argocdInstance.Name)
 
-	// Set ArgoCD instance as the owner
-	if err := controllerutil.SetControllerReference(argocdInstance, newArgoCDRoute(), r.scheme); err != nil {
+	if isReconcilePaused(argocdInstance) {
+		reqLogger.Info("Reconcile paused, ConsoleLink left untouched", "Annotation", pausedAnnotation)
+		return reconcile.Result{}, nil
+	}
+
+	if argocdInstance.GetDeletionTimestamp() != nil {
+		reqLogger.Info("ArgoCD instance is being deleted")
+		r.linkReady.forget(request.NamespacedName)
+		// the route is about to be deleted too, remove the ConsoleLink if present
+		return reconcile.Result{}, links.Unregister(ctx, reqLogger)
+	}
+
+	if r.config.Disabled || isConsoleLinkDisabled(argocdInstance) {
+		reqLogger.Info("ConsoleLink disabled", "Annotation", disabledAnnotation, "EnvVar", disableConsoleLinkEnvVar)
+		return reconcile.Result{}, links.Unregister(ctx, reqLogger)
+	}
+
+	if r.config.InstanceSelector != nil && !r.config.InstanceSelector.Matches(labels.Set(argocdInstance.Labels)) {
+		reqLogger.Info("ArgoCD instance does not match the ConsoleLink instance selector", "Selector", r.config.InstanceSelector.String())
+		return reconcile.Result{}, links.Unregister(ctx, reqLogger)
+	}
+
+	selected, err := r.isNamespaceSelected(ctx, argocdInstance.Namespace)
+	if err != nil {
 		return reconcile.Result{}, err
 	}
+	if !selected {
+		reqLogger.Info("Namespace does not match the ConsoleLink namespace selector", "Selector", r.config.NamespaceSelector.String())
+		return reconcile.Result{}, links.Unregister(ctx, reqLogger)
+	}
 
-	argoCDRoute := &routev1.Route{}
-	err = r.client.Get(ctx, types.NamespacedName{Name: argocdRouteName, Namespace: argocdNS}, argoCDRoute)
+	routeName := serverRouteName(argocdInstance)
+	argoCDRoute, err := r.serverRoute(ctx, argocdInstance, routeName)
 	if err != nil {
+		if meta.IsNoMatchError(err) {
+			reqLogger.Info("Route API not available, looking for the argocd-server Ingress or LoadBalancer Service")
+			href, err := r.alternateHref(ctx, argocdInstance)
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+			if href == "" {
+				reqLogger.Info("No Ingress or LoadBalancer Service found for argocd-server")
+				if err := links.Unregister(ctx, reqLogger); err != nil {
+					return reconcile.Result{}, err
+				}
+				return reconcile.Result{RequeueAfter: routeRequeueDelay}, nil
+			}
+			return r.registerLink(ctx, argocdInstance, href, reqLogger)
+		}
 		if errors.IsNotFound(err) {
-			reqLogger.Info("ArgoCD server route not found", "Route.Namespace", argocdNS)
+			reqLogger.Info("ArgoCD server route not found", "Route.Namespace", argocdInstance.Namespace, "Route.Name", routeName)
 			// if argocd-server route is deleted, remove the ConsoleLink if present
-			return reconcile.Result{}, r.deleteConsoleLinkIfPresent(ctx, reqLogger)
+			if err := links.Unregister(ctx, reqLogger); err != nil {
+				return reconcile.Result{}, err
+			}
+			if !argocdInstance.Spec.Server.Route.Enabled {
+				// routes are watched, creating one reconciles the instance again
+				return reconcile.Result{}, nil
+			}
+			// the route is enabled but may not have been provisioned yet, check again later
+			return reconcile.Result{RequeueAfter: routeRequeueDelay}, nil
 		}
 		return reconcile.Result{}, err
 	}
 
-	reqLogger.Info("Route found for argocd-server", "Route.Host", argoCDRoute.Spec.Host)
+	reqLogger.V(debugLevel).Info("Route found for argocd-server", "Route.Host", routeHost(argoCDRoute))
 
-	consoleLink := newConsoleLink("https://"+argoCDRoute.Spec.Host, "ArgoCD")
+	href := ""
+	if override, ok := argocdInstance.Annotations[hrefOverrideAnnotation]; ok {
+		if err := validateHref(override); err != nil {
+			reqLogger.Error(err, "Ignoring invalid ConsoleLink href override", "Annotation", hrefOverrideAnnotation)
+		} else {
+			href = override
+		}
+	}
+	if href == "" {
+		if routeHost(argoCDRoute) == "" {
+			// the host is set once the router admits the route, check again
+			// later rather than creating a link to https://
+			reqLogger.Info("ArgoCD server route has no host yet", "Route.Namespace", argocdInstance.Namespace, "Route.Name", routeName)
+			return reconcile.Result{RequeueAfter: routeRequeueDelay}, nil
+		}
+		href = routeURL(r.config.routeScheme(argoCDRoute), argoCDRoute)
+	}
 
-	found := &console.ConsoleLink{}
-	err = r.client.Get(ctx, types.NamespacedName{Name: consoleLink.Name}, found)
-	if err != nil && errors.IsNotFound(err) {
-		reqLogger.Info("Creating a new ConsoleLink", "ConsoleLink.Name", consoleLink.Name)
-		err = r.client.Create(ctx, consoleLink)
-		if err != nil {
+	return r.registerLink(ctx, argocdInstance, href, reqLogger)
+}
+
+// linksFor returns the registrar of the link of the ArgoCD instance with
+// key, creating it on first use when all namespaces are linked
+func (r *ReconcileArgoCD) linksFor(key types.NamespacedName) LinkRegistrar {
+	if !r.config.AllNamespaces {
+		return r.links
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if links, ok := r.instanceLinks[key]; ok {
+		return links
+	}
+	var icon *remoteIcon
+	if registrar, ok := r.links.(*consoleLinkRegistrar); ok {
+		icon = registrar.icon
+	}
+	if r.instanceLinks == nil {
+		r.instanceLinks = map[types.NamespacedName]LinkRegistrar{}
+	}
+	links := newInstanceRegistrar(r.client, r.config.forInstance(key.Namespace, key.Name), icon)
+	r.instanceLinks[key] = links
+	return links
+}
+
+// forgetLinks drops the registrar of a deleted ArgoCD instance
+func (r *ReconcileArgoCD) forgetLinks(key types.NamespacedName) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.instanceLinks, key)
+}
+
+// linkAction is what a reconcile did to the link of the instance
+type linkAction string
+
+const (
+	linkCreated   linkAction = "created"
+	linkUpdated   linkAction = "updated"
+	linkUnchanged linkAction = "unchanged"
+	linkDeleted   linkAction = "deleted"
+)
+
+type reconcileSummaryKey struct{}
+
+// reconcileSummary collects what a reconcile did to the link, logged as a
+// single line once it succeeds
+type reconcileSummary struct {
+	action linkAction
+	href   string
+}
+
+// withReconcileSummary returns ctx carrying a summary in which the
+// registrars record their actions, unchanged until they do
+func withReconcileSummary(ctx context.Context) (context.Context, *reconcileSummary) {
+	summary := &reconcileSummary{action: linkUnchanged}
+	return context.WithValue(ctx, reconcileSummaryKey{}, summary), summary
+}
+
+// recordAction records action in the summary of ctx, if any
+func recordAction(ctx context.Context, action linkAction) {
+	if summary, ok := ctx.Value(reconcileSummaryKey{}).(*reconcileSummary); ok {
+		summary.action = action
+	}
+}
+
+// recordedAction returns the action recorded in the summary of ctx, if any
+func recordedAction(ctx context.Context) linkAction {
+	if summary, ok := ctx.Value(reconcileSummaryKey{}).(*reconcileSummary); ok {
+		return summary.action
+	}
+	return ""
+}
+
+// recordHref records the href of the link in the summary of ctx, if any
+func recordHref(ctx context.Context, href string) {
+	if summary, ok := ctx.Value(reconcileSummaryKey{}).(*reconcileSummary); ok {
+		summary.href = href
+	}
+}
+
+// keyedMutex is a mutex per key, the zero value is ready to use
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[types.NamespacedName]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	// waiters counts the holder and the goroutines waiting for the lock, it
+	// is dropped from the map once none are left
+	waiters int
+}
+
+// lock locks the mutex of key and returns the function unlocking it
+func (m *keyedMutex) lock(key types.NamespacedName) func() {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = map[types.NamespacedName]*keyedLock{}
+	}
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		m.locks[key] = l
+	}
+	l.waiters++
+	m.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		l.waiters--
+		if l.waiters == 0 {
+			delete(m.locks, key)
+		}
+	}
+}
+
+// sameConsoleLink reports whether the desired state of both ConsoleLinks is the same
+func sameConsoleLink(a, b *console.ConsoleLink) bool {
+	return equality.Semantic.DeepEqual(a.Spec, b.Spec) &&
+		equality.Semantic.DeepEqual(a.Labels, b.Labels) &&
+		equality.Semantic.DeepEqual(a.Annotations, b.Annotations)
+}
+
+// hasAnnotations reports whether all the annotations are set on the ConsoleLink
+func hasAnnotations(link *console.ConsoleLink, annotations map[string]string) bool {
+	for k, v := range annotations {
+		if got, ok := link.Annotations[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// isNamespaceSelected reports whether the namespace matches the configured namespace selector
+func (r *ReconcileArgoCD) isNamespaceSelected(ctx context.Context, name string) (bool, error) {
+	if r.config.NamespaceSelector == nil {
+		return true, nil
+	}
+	namespace := &corev1.Namespace{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: name}, namespace)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return r.config.NamespaceSelector.Matches(labels.Set(namespace.Labels)), nil
+}
+
+// routeHost returns the host of the first ingress which admitted the route,
+// which can differ from spec.host with sharded routers or custom domains,
+// falling back to spec.host
+func routeHost(route *routev1.Route) string {
+	for _, ingress := range route.Status.Ingress {
+		if ingress.Host == "" {
+			continue
+		}
+		for _, condition := range ingress.Conditions {
+			if condition.Type == routev1.RouteAdmitted && condition.Status == corev1.ConditionTrue {
+				return ingress.Host
+			}
+		}
+	}
+	return route.Spec.Host
+}
+
+// serverRoute returns the route of the ArgoCD UI. Unless the instance names
+// it, the route is selected among the routes of the namespace if a route
+// selector or port is configured, e.g. when the server is exposed by both a
+// grpc and an http route, and looked up by name otherwise.
+func (r *ReconcileArgoCD) serverRoute(ctx context.Context, argocd *argoprojv1alpha1.ArgoCD, name string) (*routev1.Route, error) {
+	route := &routev1.Route{}
+	if argocd.Annotations[routeNameAnnotation] != "" || (r.config.RouteSelector == nil && r.config.RoutePort == "") {
+		if err := r.client.Get(ctx, types.NamespacedName{Name: name, Namespace: argocd.Namespace}, route); err != nil {
+			return nil, err
+		}
+		if ownedByOtherInstance(route, argocd) {
+			logs.Info("Ignoring route owned by another ArgoCD instance", "Route.Namespace", route.Namespace, "Route.Name", route.Name)
+			return nil, errors.NewNotFound(routev1.Resource("routes"), name)
+		}
+		return route, nil
+	}
+
+	opts := []client.ListOption{client.InNamespace(argocd.Namespace)}
+	if r.config.RouteSelector != nil {
+		opts = append(opts, client.MatchingLabelsSelector{Selector: r.config.RouteSelector})
+	}
+	routes := &routev1.RouteList{}
+	if err := r.client.List(ctx, routes, opts...); err != nil {
+		return nil, err
+	}
+	var selected *routev1.Route
+	for i := range routes.Items {
+		candidate := &routes.Items[i]
+		if isGRPCRoute(candidate) || ownedByOtherInstance(candidate, argocd) || (r.config.RoutePort != "" && routePort(candidate) != r.config.RoutePort) {
+			continue
+		}
+		// prefer the route named after the instance, then the first by name
+		if selected == nil || candidate.Name == name || (selected.Name != name && candidate.Name < selected.Name) {
+			selected = candidate
+		}
+	}
+	if selected == nil {
+		return nil, errors.NewNotFound(routev1.Resource("routes"), name)
+	}
+	return selected, nil
+}
+
+// ownedByOtherInstance reports whether the route belongs to another ArgoCD
+// instance than argocd, either because it is in another namespace or because
+// it is controlled by another instance. Routes without an ArgoCD controller,
+// e.g. created by hand, are not.
+func ownedByOtherInstance(route *routev1.Route, argocd *argoprojv1alpha1.ArgoCD) bool {
+	if route.Namespace != argocd.Namespace {
+		return true
+	}
+	owner := metav1.GetControllerOf(route)
+	if owner == nil || owner.Kind != argocdKind || !strings.HasPrefix(owner.APIVersion, argocdGroup+"/") {
+		return false
+	}
+	if owner.UID != "" && argocd.UID != "" {
+		return owner.UID != argocd.UID
+	}
+	return owner.Name != argocd.Name
+}
+
+// isGRPCRoute reports whether the route exposes the argocd-server grpc API,
+// used by the CLI, rather than the UI
+func isGRPCRoute(route *routev1.Route) bool {
+	return strings.HasSuffix(route.Name, grpcRouteSuffix) || strings.Contains(routePort(route), "grpc")
+}
+
+// routePort returns the target port of the route, empty if it targets all ports
+func routePort(route *routev1.Route) string {
+	if route.Spec.Port == nil {
+		return ""
+	}
+	return route.Spec.Port.TargetPort.String()
+}
+
+// routeURL returns the URL of the route, including its path if it is not
+// served from the root
+func routeURL(scheme string, route *routev1.Route) string {
+	href := scheme + "://" + strings.TrimSuffix(routeHost(route), "/")
+	if path := strings.Trim(route.Spec.Path, "/"); path != "" {
+		href += "/" + path
+	}
+	return href
+}
+
+// registerLink registers the link of instance to href and, if enabled,
+// requeues the request for the periodic resync
+func (r *ReconcileArgoCD) registerLink(ctx context.Context, instance *argoprojv1alpha1.ArgoCD, href string, reqLogger logr.Logger) (reconcile.Result, error) {
+	recordHref(ctx, href)
+	if err := r.linksFor(types.NamespacedName{Namespace: instance.Namespace, Name: instance.Name}).Register(ctx, href, reqLogger); err != nil {
+		invalid := &InvalidConsoleLinkError{}
+		if goerrors.As(err, &invalid) {
+			// retrying cannot fix it, the instance or route has to change
+			reqLogger.Info("Not creating an invalid ConsoleLink", "Error", err.Error())
+			if r.recorder != nil {
+				r.recorder.Event(instance, corev1.EventTypeWarning, invalidConsoleLinkReason, err.Error())
+			}
+			return reconcile.Result{}, nil
+		}
+		collision := &consoleLinkCollisionError{}
+		if !goerrors.As(err, &collision) {
 			return reconcile.Result{}, err
 		}
-		// ConsoleLink created successfully - don't requeue
-		return reconcile.Result{}, nil
-	} else if err != nil {
-		reqLogger.Error(err, "Failed to create ConsoleLink", "ConsoleLink.Name", consoleLink.Name)
-		return reconcile.Result{}, err
+		// ConsoleLinks aren't watched, check again later whether the
+		// colliding link has been removed
+		reqLogger.Info("Not overwriting a ConsoleLink the operator doesn't manage", "ConsoleLink.Name", collision.name)
+		if r.recorder != nil {
+			r.recorder.Event(instance, corev1.EventTypeWarning, consoleLinkCollisionReason, err.Error())
+		}
+		return reconcile.Result{RequeueAfter: collisionRequeueDelay}, nil
+	}
+	if recordedAction(ctx) == linkCreated {
+		r.linkReady.linkCreated(instance)
+	}
+	return reconcile.Result{RequeueAfter: r.config.ResyncInterval}, nil
+}
+
+// alternateHref returns the URL of the argocd-server from its Ingress or,
+// failing that, its LoadBalancer Service, or an empty string if neither
+// exposes a host yet. It is used on clusters without the Route API.
+func (r *ReconcileArgoCD) alternateHref(ctx context.Context, argocd *argoprojv1alpha1.ArgoCD) (string, error) {
+	name := types.NamespacedName{Name: argocd.Name + serverRouteSuffix, Namespace: argocd.Namespace}
+
+	ingress := &networkingv1beta1.Ingress{}
+	err := r.client.Get(ctx, name, ingress)
+	if err != nil && !errors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+		return "", err
+	}
+	if err == nil {
+		if href := ingressURL(ingress); href != "" {
+			return href, nil
+		}
 	}
 
-	reqLogger.Info("Skip reconcile: ConsoleLink already exists", "ConsoleLink.Name", consoleLink.Name)
-	return reconcile.Result{}, nil
+	service := &corev1.Service{}
+	err = r.client.Get(ctx, name, service)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return loadBalancerURL(service), nil
+}
+
+// ingressURL returns the URL of the first host rule of the ingress, using
+// https if the host is covered by its TLS configuration
+func ingressURL(ingress *networkingv1beta1.Ingress) string {
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Host == "" {
+			continue
+		}
+		scheme := "http"
+		for _, tls := range ingress.Spec.TLS {
+			for _, host := range tls.Hosts {
+				if host == rule.Host {
+					scheme = "https"
+				}
+			}
+		}
+		href := scheme + "://" + rule.Host
+		if rule.HTTP != nil && len(rule.HTTP.Paths) > 0 {
+			if path := strings.Trim(rule.HTTP.Paths[0].Path, "/"); path != "" {
+				href += "/" + path
+			}
+		}
+		return href
+	}
+	return ""
+}
+
+// loadBalancerURL returns the https URL of a LoadBalancer service once its
+// load balancer has been provisioned
+func loadBalancerURL(service *corev1.Service) string {
+	if service.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		return ""
+	}
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.Hostname != "" {
+			return "https://" + ingress.Hostname
+		}
+		if ingress.IP != "" {
+			return "https://" + ingress.IP
+		}
+	}
+	return ""
+}
+
+// validateHref checks that href is an absolute http(s) URL
+func validateHref(href string) error {
+	u, err := url.Parse(href)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", href, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid URL %q: scheme must be http or https", href)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid URL %q: missing host", href)
+	}
+	return nil
 }
 
 func newConsoleLink(href, text string) *console.ConsoleLink {
 	return &console.ConsoleLink{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: consoleLinkName,
+			Labels: map[string]string{
+				managedByLabel: managedByValue,
+			},
 		},
 		Spec: console.ConsoleLinkSpec{
 			Link: console.Link{
@@ -214,51 +994,26 @@ func newConsoleLink(href, text string) *console.ConsoleLink {
 			},
 			Location: console.ApplicationMenu,
 			ApplicationMenu: &console.ApplicationMenuSpec{
-				Section:  "Application Stages",
-				ImageURL: image,
+				Section:  defaultSection,
+				ImageURL: embeddedImageURL(),
 			},
 		},
 	}
 }
 
-func (r *ReconcileArgoCD) deleteConsoleLinkIfPresent(ctx context.Context, log logr.Logger) error {
-	err := r.client.Get(ctx, types.NamespacedName{Name: consoleLinkName}, &console.ConsoleLink{})
-	if err != nil {
-		if errors.IsNotFound(err) {
-			return nil
-		}
-		return err
-	}
-	log.Info("Deleting ConsoleLink", "ConsoleLink.Name", consoleLinkName)
-	return r.client.Delete(ctx, &console.ConsoleLink{ObjectMeta: metav1.ObjectMeta{Name: consoleLinkName}})
-}
-
-func newArgoCDRoute() *routev1.Route {
-	return &routev1.Route{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      argocdRouteName,
-			Namespace: argocdNS,
+// namespaceDashboardPatch returns a merge patch restricting a NamespaceDashboard
+// ConsoleLink to namespaces, the vendored console API doesn't define the
+// namespaceDashboard field of the ConsoleLink spec yet
+func namespaceDashboardPatch(namespaces []string) (client.Patch, error) {
+	data, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"namespaceDashboard": map[string]interface{}{
+				"namespaces": namespaces,
+			},
 		},
-	}
-}
-
-func readStatikImage() []byte {
-	statikFs, err := fs.New()
-	if err != nil {
-		log.Fatalf("Failed to create a new statik filesystem: %v", err)
-	}
-	file, err := statikFs.Open(iconFilePath)
-	if err != nil {
-		log.Fatalf("Failed to open ArgoCD icon file: %v", err)
-	}
-	defer file.Close()
-	data, err := ioutil.ReadAll(file)
+	})
 	if err != nil {
-		log.Fatalf("Failed to read ArgoCD icon file: %v", err)
+		return nil, err
 	}
-	return data
-}
-
-func imageDataURL(data string) string {
-	return fmt.Sprintf("data:image/png;base64,%s", data)
+	return client.RawPatch(types.MergePatchType, data), nil
 }
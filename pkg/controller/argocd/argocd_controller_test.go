@@ -1,20 +1,43 @@
 package argocd
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	goerrors "errors"
+	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	argoprojv1alpha1 "github.com/argoproj-labs/argocd-operator/pkg/apis/argoproj/v1alpha1"
+	"github.com/go-logr/logr"
 	"github.com/google/go-cmp/cmp"
 	console "github.com/openshift/api/console/v1"
 	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 var (
@@ -27,7 +50,7 @@ var (
 
 	argoCDRoute = &routev1.Route{
 		ObjectMeta: v1.ObjectMeta{
-			Name:      argocdRouteName,
+			Name:      "argocd-server",
 			Namespace: argocdNS,
 		},
 		Spec: routev1.RouteSpec{
@@ -71,21 +94,1768 @@ func TestReconcile_delete_consolelink(t *testing.T) {
 		assertConsoleLinkDeletion(t, fakeClient, reconcileResult{result, err})
 	})
 	t.Run("Deletion of ConsoleLink because of Route", func(t *testing.T) {
-		err := fakeClient.Delete(context.TODO(), &routev1.Route{ObjectMeta: v1.ObjectMeta{Name: argocdRouteName, Namespace: argocdNS}})
+		err := fakeClient.Delete(context.TODO(), &routev1.Route{ObjectMeta: v1.ObjectMeta{Name: "argocd-server", Namespace: argocdNS}})
 		assertNoError(t, err)
 
-		result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdRouteName))
+		result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, "argocd-server"))
 		assertConsoleLinkDeletion(t, fakeClient, reconcileResult{result, err})
 	})
 }
 
-func newFakeReconcileArgoCD(client client.Client, scheme *runtime.Scheme) *ReconcileArgoCD {
-	return &ReconcileArgoCD{
-		client: client,
-		scheme: scheme,
+func TestReconcile_route_path(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	route := argoCDRoute.DeepCopy()
+	route.Spec.Path = "/argocd"
+	fakeClient := fake.NewFakeClient(argoCD, route)
+
+	reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+	want := newConsoleLink("https://test.com/argocd", "ArgoCD")
+
+	result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, want)
+}
+
+func TestReconcile_href_override(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	tests := []struct {
+		name     string
+		override string
+		want     string
+	}{
+		{"valid override", "https://argocd.example.com/ui", "https://argocd.example.com/ui"},
+		{"override without scheme", "argocd.example.com", "https://test.com"},
+		{"override with unsupported scheme", "ftp://argocd.example.com", "https://test.com"},
+		{"override without host", "https://", "https://test.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := argoCD.DeepCopy()
+			instance.Annotations = map[string]string{hrefOverrideAnnotation: tt.override}
+			fakeClient := fake.NewFakeClient(instance, argoCDRoute)
+			reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+
+			result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+			assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink(tt.want, "ArgoCD"))
+		})
+	}
+}
+
+func TestReconcile_custom_route_name(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	instance := argoCD.DeepCopy()
+	instance.Annotations = map[string]string{routeNameAnnotation: "argocd-ui"}
+	route := argoCDRoute.DeepCopy()
+	route.Name = "argocd-ui"
+	route.Spec.Host = "ui.test.com"
+	fakeClient := fake.NewFakeClient(instance, argoCDRoute, route)
+	reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+
+	result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink("https://ui.test.com", "ArgoCD"))
+}
+
+func TestReconcile_multiple_routes(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	newRoute := func(name, host, port string) *routev1.Route {
+		return &routev1.Route{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      name,
+				Namespace: argocdNS,
+				Labels:    map[string]string{"app.kubernetes.io/part-of": "argocd"},
+			},
+			Spec: routev1.RouteSpec{
+				Host: host,
+				Port: &routev1.RoutePort{TargetPort: intstr.FromString(port)},
+			},
+		}
+	}
+	grpc := newRoute("argocd-grpc", "grpc.test.com", "grpc")
+	ui := newRoute("argocd-ui", "ui.test.com", "https")
+	metrics := newRoute("argocd-metrics", "metrics.test.com", "metrics")
+	selector, err := labels.Parse("app.kubernetes.io/part-of=argocd")
+	assertNoError(t, err)
+
+	t.Run("grpc route ignored", func(t *testing.T) {
+		fakeClient := fake.NewFakeClient(argoCD, grpc, ui)
+		config := DefaultConfig()
+		config.RouteSelector = selector
+		reconcileArgoCD := newReconcilerFromConfig(fakeClient, s, config)
+
+		result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink("https://ui.test.com", "ArgoCD"))
+	})
+	t.Run("route selected by port", func(t *testing.T) {
+		fakeClient := fake.NewFakeClient(argoCD, grpc, metrics, ui)
+		config := DefaultConfig()
+		config.RoutePort = "https"
+		reconcileArgoCD := newReconcilerFromConfig(fakeClient, s, config)
+
+		result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink("https://ui.test.com", "ArgoCD"))
+	})
+	t.Run("no matching route", func(t *testing.T) {
+		fakeClient := fake.NewFakeClient(argoCD, grpc)
+		config := DefaultConfig()
+		config.RouteSelector = selector
+		reconcileArgoCD := newReconcilerFromConfig(fakeClient, s, config)
+
+		result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertNoError(t, err)
+		if result != (reconcile.Result{}) {
+			t.Fatalf("got result %v, want no requeue", result)
+		}
+		if _, err := getConsoleLink(fakeClient); !apierrors.IsNotFound(err) {
+			t.Fatalf("got error %v, want the ConsoleLink not to be created", err)
+		}
+	})
+}
+
+func TestReconcile_route_owned_by_other_instance(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	other := &argoprojv1alpha1.ArgoCD{ObjectMeta: v1.ObjectMeta{Name: "other", Namespace: argocdNS, UID: "other-uid"}}
+	route := argoCDRoute.DeepCopy()
+	route.OwnerReferences = []v1.OwnerReference{*v1.NewControllerRef(other, argoprojv1alpha1.SchemeGroupVersion.WithKind(argocdKind))}
+	fakeClient := fake.NewFakeClient(argoCD, route)
+	reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+
+	result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+	if result != (reconcile.Result{}) {
+		t.Fatalf("got result %v, want no requeue", result)
+	}
+	if _, err := getConsoleLink(fakeClient); !apierrors.IsNotFound(err) {
+		t.Fatalf("got error %v, want the ConsoleLink not to be created", err)
+	}
+
+	owned := argoCD.DeepCopy()
+	owned.UID = "argocd-uid"
+	if !ownedByOtherInstance(route, owned) {
+		t.Fatal("a route owned by another instance should be rejected")
+	}
+	route.OwnerReferences = []v1.OwnerReference{*v1.NewControllerRef(owned, argoprojv1alpha1.SchemeGroupVersion.WithKind(argocdKind))}
+	if ownedByOtherInstance(route, owned) {
+		t.Fatal("a route owned by the instance should be accepted")
+	}
+}
+
+func TestServerRouteName(t *testing.T) {
+	instance := argoCD.DeepCopy()
+	instance.Name = "example"
+	if got := serverRouteName(instance); got != "example-server" {
+		t.Fatalf("got %s, want example-server", got)
+	}
+	instance.Annotations = map[string]string{routeNameAnnotation: "custom"}
+	if got := serverRouteName(instance); got != "custom" {
+		t.Fatalf("got %s, want custom", got)
+	}
+}
+
+func TestRouteURL(t *testing.T) {
+	tests := []struct {
+		host string
+		path string
+		want string
+	}{
+		{"test.com", "", "https://test.com"},
+		{"test.com", "/", "https://test.com"},
+		{"test.com", "/argocd", "https://test.com/argocd"},
+		{"test.com", "/argocd/", "https://test.com/argocd"},
+		{"test.com/", "/argocd", "https://test.com/argocd"},
+	}
+	for _, tt := range tests {
+		route := &routev1.Route{Spec: routev1.RouteSpec{Host: tt.host, Path: tt.path}}
+		if got := routeURL("https", route); got != tt.want {
+			t.Errorf("routeURL(%q, %q) got %s, want %s", tt.host, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRouteHost(t *testing.T) {
+	admitted := func(host string, status corev1.ConditionStatus) routev1.RouteIngress {
+		return routev1.RouteIngress{
+			Host: host,
+			Conditions: []routev1.RouteIngressCondition{
+				{Type: routev1.RouteAdmitted, Status: status},
+			},
+		}
+	}
+	tests := []struct {
+		name    string
+		ingress []routev1.RouteIngress
+		want    string
+	}{
+		{"no ingress", nil, "test.com"},
+		{"admitted ingress", []routev1.RouteIngress{admitted("apps.shard.test.com", corev1.ConditionTrue)}, "apps.shard.test.com"},
+		{"rejected ingress", []routev1.RouteIngress{admitted("apps.shard.test.com", corev1.ConditionFalse)}, "test.com"},
+		{"first admitted ingress", []routev1.RouteIngress{
+			admitted("rejected.test.com", corev1.ConditionFalse),
+			admitted("admitted.test.com", corev1.ConditionTrue),
+		}, "admitted.test.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := &routev1.Route{
+				Spec:   routev1.RouteSpec{Host: "test.com"},
+				Status: routev1.RouteStatus{Ingress: tt.ingress},
+			}
+			if got := routeHost(route); got != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcile_route_status_host(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	route := argoCDRoute.DeepCopy()
+	route.Status.Ingress = []routev1.RouteIngress{
+		{
+			Host: "argocd.apps.custom.com",
+			Conditions: []routev1.RouteIngressCondition{
+				{Type: routev1.RouteAdmitted, Status: corev1.ConditionTrue},
+			},
+		},
+	}
+	fakeClient := fake.NewFakeClient(argoCD, route)
+	reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+
+	result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink("https://argocd.apps.custom.com", "ArgoCD"))
+}
+
+func TestReconcile_route_not_provisioned(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	routeEnabled := argoCD.DeepCopy()
+	routeEnabled.Spec.Server.Route.Enabled = true
+	tests := []struct {
+		name     string
+		instance *argoprojv1alpha1.ArgoCD
+		want     reconcile.Result
+	}{
+		{"route enabled", routeEnabled, reconcile.Result{RequeueAfter: routeRequeueDelay}},
+		{"route disabled", argoCD, reconcile.Result{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClient := fake.NewFakeClient(tt.instance)
+			reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+
+			result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+			assertNoError(t, err)
+
+			if result != tt.want {
+				t.Fatalf("got result %v, want %v", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcile_route_without_host(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	route := argoCDRoute.DeepCopy()
+	route.Spec.Host = ""
+	route.Status.Ingress = []routev1.RouteIngress{
+		{
+			RouterName: "default",
+			Conditions: []routev1.RouteIngressCondition{
+				{Type: routev1.RouteAdmitted, Status: corev1.ConditionTrue},
+			},
+		},
+	}
+	fakeClient := fake.NewFakeClient(argoCD, route)
+	reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+
+	result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+
+	if result.RequeueAfter != routeRequeueDelay {
+		t.Fatalf("got RequeueAfter %v, want %v", result.RequeueAfter, routeRequeueDelay)
+	}
+	if _, err := getConsoleLink(fakeClient); !apierrors.IsNotFound(err) {
+		t.Fatalf("was expecting no ConsoleLink, got error %v", err)
+	}
+}
+
+func TestReconcile_link_registrar(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	fakeClient := fake.NewFakeClient(argoCD, argoCDRoute)
+	reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+	registrar := &fakeRegistrar{}
+	reconcileArgoCD.links = registrar
+
+	_, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+
+	if diff := cmp.Diff([]string{"https://test.com"}, registrar.registered); diff != "" {
+		t.Fatalf("registered links mismatch: %v", diff)
+	}
+	if _, err := getConsoleLink(fakeClient); !apierrors.IsNotFound(err) {
+		t.Fatalf("was expecting no ConsoleLink, got error %v", err)
+	}
+
+	assertNoError(t, fakeClient.Delete(context.TODO(), argoCDRoute.DeepCopy()))
+	_, err = reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+	if registrar.unregistered != 1 {
+		t.Fatalf("got %d Unregister calls, want 1", registrar.unregistered)
+	}
+}
+
+// fakeRegistrar records the links registered through it
+type fakeRegistrar struct {
+	registered   []string
+	unregistered int
+}
+
+func (f *fakeRegistrar) Register(ctx context.Context, href string, log logr.Logger) error {
+	f.registered = append(f.registered, href)
+	return nil
+}
+
+func (f *fakeRegistrar) Unregister(ctx context.Context, log logr.Logger) error {
+	f.unregistered++
+	return nil
+}
+
+func TestReconcile_consolelink_disabled(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	t.Run("disabled by configuration", func(t *testing.T) {
+		fakeClient := fake.NewFakeClient(argoCD, argoCDRoute, newConsoleLink("https://test.com", "ArgoCD"))
+		reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+		reconcileArgoCD.config.Disabled = true
+
+		result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertConsoleLinkDeletion(t, fakeClient, reconcileResult{result, err})
+
+		result, err = reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertConsoleLinkDeletion(t, fakeClient, reconcileResult{result, err})
+	})
+	t.Run("disabled by annotation", func(t *testing.T) {
+		instance := argoCD.DeepCopy()
+		instance.Annotations = map[string]string{disabledAnnotation: "true"}
+		fakeClient := fake.NewFakeClient(instance, argoCDRoute)
+		reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+
+		result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertConsoleLinkDeletion(t, fakeClient, reconcileResult{result, err})
+	})
+}
+
+func TestReconcile_paused(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	instance := argoCD.DeepCopy()
+	instance.Annotations = map[string]string{pausedAnnotation: "true", disabledAnnotation: "true"}
+	route := argoCDRoute.DeepCopy()
+	route.Spec.Host = "updated.test.com"
+	existing := newConsoleLink("https://test.com", "ArgoCD")
+	fakeClient := fake.NewFakeClient(instance, route, existing)
+	reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+
+	result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+	if result != (reconcile.Result{}) {
+		t.Fatalf("got result %v, want no requeue", result)
+	}
+	got, err := getConsoleLink(fakeClient)
+	assertNoError(t, err)
+	if diff := cmp.Diff(existing.Spec, got.Spec); diff != "" {
+		t.Fatalf("the ConsoleLink of a paused instance changed: %v", diff)
+	}
+
+	// resuming applies the pending changes
+	instance.Annotations = map[string]string{pausedAnnotation: "false"}
+	assertNoError(t, fakeClient.Update(context.TODO(), instance))
+	result, err = reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink("https://updated.test.com", "ArgoCD"))
+}
+
+func TestReconcile_cache_not_synced(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	// the ArgoCD instance is missing from the cache until it syncs
+	existing := newConsoleLink("https://test.com", "ArgoCD")
+	fakeClient := fake.NewFakeClient(argoCDRoute, existing)
+	reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+	synced := false
+	reconcileArgoCD.cacheSynced = func() bool { return synced }
+
+	result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+	if result.RequeueAfter != cacheSyncRequeueDelay {
+		t.Fatalf("got requeue after %v, want %v", result.RequeueAfter, cacheSyncRequeueDelay)
+	}
+	if _, err := getConsoleLink(fakeClient); err != nil {
+		t.Fatalf("the ConsoleLink should be kept until the cache has synced: %v", err)
+	}
+
+	synced = true
+	assertNoError(t, fakeClient.Create(context.TODO(), argoCD.DeepCopy()))
+	result, err = reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink("https://test.com", "ArgoCD"))
+}
+
+func TestCacheSyncedFunc(t *testing.T) {
+	c := &syncingCache{}
+	synced := cacheSyncedFunc(c)
+	if synced() {
+		t.Fatal("the cache should not be reported synced before it has")
+	}
+	c.synced = true
+	if !synced() {
+		t.Fatal("the cache should be reported synced")
+	}
+}
+
+// syncingCache reports as synced once synced is set, without waiting for
+// stop to be closed
+type syncingCache struct {
+	cache.Cache
+	synced bool
+}
+
+func (c *syncingCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	if c.synced {
+		return true
+	}
+	<-stop
+	return false
+}
+
+func TestReconcile_instance_being_deleted(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	instance := argoCD.DeepCopy()
+	instance.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	instance.Finalizers = []string{"argoproj.io/finalizer"}
+
+	t.Run("ConsoleLink deleted", func(t *testing.T) {
+		fakeClient := fake.NewFakeClient(instance, argoCDRoute, newConsoleLink("https://test.com", "ArgoCD"))
+		reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+
+		result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertConsoleLinkDeletion(t, fakeClient, reconcileResult{result, err})
+	})
+	t.Run("ConsoleLink not created", func(t *testing.T) {
+		fakeClient := fake.NewFakeClient(instance, argoCDRoute)
+		reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+
+		result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertConsoleLinkDeletion(t, fakeClient, reconcileResult{result, err})
+	})
+}
+
+func TestReconcile_without_route_api(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	t.Run("ConsoleLink built from the Ingress", func(t *testing.T) {
+		ingress := &networkingv1beta1.Ingress{
+			ObjectMeta: v1.ObjectMeta{Name: "argocd-server", Namespace: argocdNS},
+			Spec: networkingv1beta1.IngressSpec{
+				TLS:   []networkingv1beta1.IngressTLS{{Hosts: []string{"argocd.test.com"}}},
+				Rules: []networkingv1beta1.IngressRule{{Host: "argocd.test.com"}},
+			},
+		}
+		fakeClient := &noRouteClient{fake.NewFakeClient(argoCD, ingress)}
+		reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+
+		result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink("https://argocd.test.com", "ArgoCD"))
+	})
+	t.Run("ConsoleLink built from the LoadBalancer Service", func(t *testing.T) {
+		service := &corev1.Service{
+			ObjectMeta: v1.ObjectMeta{Name: "argocd-server", Namespace: argocdNS},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+			Status: corev1.ServiceStatus{
+				LoadBalancer: corev1.LoadBalancerStatus{
+					Ingress: []corev1.LoadBalancerIngress{{Hostname: "lb.test.com"}},
+				},
+			},
+		}
+		fakeClient := &noRouteClient{fake.NewFakeClient(argoCD, service)}
+		reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+
+		result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink("https://lb.test.com", "ArgoCD"))
+	})
+	t.Run("Requeued while nothing exposes argocd-server", func(t *testing.T) {
+		service := &corev1.Service{
+			ObjectMeta: v1.ObjectMeta{Name: "argocd-server", Namespace: argocdNS},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP},
+		}
+		fakeClient := &noRouteClient{fake.NewFakeClient(argoCD, service)}
+		reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+
+		result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertNoError(t, err)
+		if result.RequeueAfter != routeRequeueDelay {
+			t.Fatalf("got RequeueAfter %v, want %v", result.RequeueAfter, routeRequeueDelay)
+		}
+	})
+}
+
+func TestIngressURL(t *testing.T) {
+	tests := []struct {
+		name string
+		spec networkingv1beta1.IngressSpec
+		want string
+	}{
+		{"no rules", networkingv1beta1.IngressSpec{}, ""},
+		{"plain http", networkingv1beta1.IngressSpec{
+			Rules: []networkingv1beta1.IngressRule{{Host: "argocd.test.com"}},
+		}, "http://argocd.test.com"},
+		{"tls with path", networkingv1beta1.IngressSpec{
+			TLS: []networkingv1beta1.IngressTLS{{Hosts: []string{"argocd.test.com"}}},
+			Rules: []networkingv1beta1.IngressRule{{
+				Host: "argocd.test.com",
+				IngressRuleValue: networkingv1beta1.IngressRuleValue{
+					HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+						Paths: []networkingv1beta1.HTTPIngressPath{{Path: "/argocd/"}},
+					},
+				},
+			}},
+		}, "https://argocd.test.com/argocd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ingressURL(&networkingv1beta1.Ingress{Spec: tt.spec}); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// noRouteClient behaves as if the Route API was not available
+type noRouteClient struct {
+	client.Client
+}
+
+func (c *noRouteClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if _, ok := obj.(*routev1.Route); ok {
+		return &meta.NoKindMatchError{GroupKind: schema.GroupKind{Group: routev1.GroupName, Kind: routeKind}}
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func TestReconcile_namespace_selector(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	selector, err := labels.Parse("gitops.redhat.com/console=enabled")
+	assertNoError(t, err)
+
+	t.Run("ConsoleLink created for labeled namespace", func(t *testing.T) {
+		fakeClient := fake.NewFakeClient(argoCD, argoCDRoute, newNamespace(argocdNS, map[string]string{"gitops.redhat.com/console": "enabled"}))
+		reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+		reconcileArgoCD.config.NamespaceSelector = selector
+		want := newConsoleLink("https://test.com", "ArgoCD")
+
+		result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, want)
+	})
+	t.Run("ConsoleLink not created for unlabeled namespace", func(t *testing.T) {
+		fakeClient := fake.NewFakeClient(argoCD, argoCDRoute, newNamespace(argocdNS, nil))
+		reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+		reconcileArgoCD.config.NamespaceSelector = selector
+
+		result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertConsoleLinkDeletion(t, fakeClient, reconcileResult{result, err})
+	})
+	t.Run("ConsoleLink removed when namespace is unlabeled", func(t *testing.T) {
+		fakeClient := fake.NewFakeClient(argoCD, argoCDRoute, consoleLink, newNamespace(argocdNS, map[string]string{"gitops.redhat.com/console": "disabled"}))
+		reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+		reconcileArgoCD.config.NamespaceSelector = selector
+
+		result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertConsoleLinkDeletion(t, fakeClient, reconcileResult{result, err})
+	})
+}
+
+func TestReconcile_instance_selector(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	selector, err := labels.Parse("gitops.openshift.io/console-link=true")
+	assertNoError(t, err)
+
+	t.Run("ConsoleLink created for labeled instance", func(t *testing.T) {
+		labeled := argoCD.DeepCopy()
+		labeled.Labels = map[string]string{"gitops.openshift.io/console-link": "true"}
+		fakeClient := fake.NewFakeClient(labeled, argoCDRoute)
+		reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+		reconcileArgoCD.config.InstanceSelector = selector
+		want := newConsoleLink("https://test.com", "ArgoCD")
+
+		result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, want)
+	})
+	t.Run("ConsoleLink not created for unlabeled instance", func(t *testing.T) {
+		fakeClient := fake.NewFakeClient(argoCD, argoCDRoute)
+		reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+		reconcileArgoCD.config.InstanceSelector = selector
+
+		result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertConsoleLinkDeletion(t, fakeClient, reconcileResult{result, err})
+	})
+}
+
+func TestNewNamespaceNamePredicate(t *testing.T) {
+	p := NewNamespaceNamePredicate(DefaultConfig().isInstance)
+	other := argoCD.DeepCopy()
+	other.Namespace = "other"
+	resynced := argoCD.DeepCopy()
+	updated := argoCD.DeepCopy()
+	updated.ResourceVersion = "2"
+
+	if !p.Create(event.CreateEvent{Meta: argoCD, Object: argoCD}) || p.Create(event.CreateEvent{Meta: other, Object: other}) {
+		t.Fatal("only the instance should pass create events")
+	}
+	if !p.Delete(event.DeleteEvent{Meta: argoCD, Object: argoCD}) || p.Delete(event.DeleteEvent{Meta: other, Object: other}) {
+		t.Fatal("only the instance should pass delete events")
+	}
+	if !p.Update(event.UpdateEvent{MetaOld: argoCD, ObjectOld: argoCD, MetaNew: updated, ObjectNew: updated}) {
+		t.Fatal("updates of the instance should pass")
+	}
+	if p.Update(event.UpdateEvent{MetaOld: argoCD, ObjectOld: argoCD, MetaNew: resynced, ObjectNew: resynced}) {
+		t.Fatal("resyncs without a new resource version should be filtered out")
+	}
+}
+
+func TestChangedPredicate(t *testing.T) {
+	old := argoCD.DeepCopy()
+	old.Generation = 1
+
+	statusOnly := old.DeepCopy()
+	statusOnly.ResourceVersion = "2"
+	statusOnly.Status.Phase = "Available"
+	specChanged := old.DeepCopy()
+	specChanged.Generation = 2
+	annotated := old.DeepCopy()
+	annotated.Annotations = map[string]string{hrefOverrideAnnotation: "https://argocd.test.com"}
+	deleted := old.DeepCopy()
+	deleted.DeletionTimestamp = &v1.Time{Time: time.Now()}
+
+	tests := []struct {
+		name string
+		new  *argoprojv1alpha1.ArgoCD
+		want bool
+	}{
+		{"status only", statusOnly, false},
+		{"spec changed", specChanged, true},
+		{"annotations changed", annotated, true},
+		{"being deleted", deleted, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := event.UpdateEvent{MetaOld: old, ObjectOld: old, MetaNew: tt.new, ObjectNew: tt.new}
+			if got := changedPredicate().Update(e); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelPredicate(t *testing.T) {
+	selector, err := labels.Parse("gitops.openshift.io/console-link=true")
+	assertNoError(t, err)
+
+	labeled := argoCD.DeepCopy()
+	labeled.Labels = map[string]string{"gitops.openshift.io/console-link": "true"}
+
+	if !labelPredicate(selector).Create(event.CreateEvent{Meta: labeled, Object: labeled}) {
+		t.Fatal("expected labeled instance to be accepted")
+	}
+	if labelPredicate(selector).Create(event.CreateEvent{Meta: argoCD, Object: argoCD}) {
+		t.Fatal("expected unlabeled instance to be filtered out")
+	}
+	if !labelPredicate(selector).Update(event.UpdateEvent{MetaOld: labeled, ObjectOld: labeled, MetaNew: argoCD, ObjectNew: argoCD}) {
+		t.Fatal("expected removal of the label to be accepted")
+	}
+	if !labelPredicate(nil).Create(event.CreateEvent{Meta: argoCD, Object: argoCD}) {
+		t.Fatal("expected all instances to be accepted without a selector")
+	}
+}
+
+func TestReconcile_delete_orphaned_consolelinks(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	orphan := newConsoleLink("https://old.test.com", "ArgoCD")
+	orphan.Name = "argocd-argocd"
+	unmanaged := newConsoleLink("https://other.test.com", "Other")
+	unmanaged.Name = "other"
+	unmanaged.Labels = nil
+	fakeClient := fake.NewFakeClient(argoCD, argoCDRoute, orphan, unmanaged)
+	reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+
+	result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink("https://test.com", "ArgoCD"))
+
+	err = fakeClient.Get(context.TODO(), types.NamespacedName{Name: orphan.Name}, &console.ConsoleLink{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("got %v, want the orphaned ConsoleLink to be deleted", err)
+	}
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: unmanaged.Name}, &console.ConsoleLink{}))
+}
+
+func TestNewConsoleLink_managed_by_label(t *testing.T) {
+	link := newConsoleLink("https://test.com", "ArgoCD")
+	if got := link.Labels[managedByLabel]; got != managedByValue {
+		t.Fatalf("got %s label %q, want %q", managedByLabel, got, managedByValue)
+	}
+}
+
+func TestReconcile_skip_unchanged_consolelink(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	fakeClient := fake.NewFakeClient(argoCD, argoCDRoute)
+	c := &countingClient{Client: fakeClient}
+	reconcileArgoCD := newFakeReconcileArgoCD(c, s)
+
+	for i := 0; i < 2; i++ {
+		_, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertNoError(t, err)
+	}
+	if c.writes != 1 {
+		t.Fatalf("got %d ConsoleLink writes for the same host, want 1", c.writes)
+	}
+	// each reconcile reads the link, from the cache of the manager, to
+	// restore it if it was deleted or edited by hand
+	if c.reads != 2 {
+		t.Fatalf("got %d ConsoleLink reads for the same host, want 2", c.reads)
+	}
+
+	route := &routev1.Route{}
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "argocd-server", Namespace: argocdNS}, route))
+	route.Spec.Host = "new.test.com"
+	assertNoError(t, fakeClient.Update(context.TODO(), route))
+
+	result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink("https://new.test.com", "ArgoCD"))
+	if c.writes != 2 {
+		t.Fatalf("got %d ConsoleLink writes after a host change, want 2", c.writes)
+	}
+}
+
+func TestReconcile_log_verbosity(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	// production loggers log at Info level unless --zap-level is set
+	out := &bytes.Buffer{}
+	old := logs
+	logs = zap.New(zap.WriteTo(out))
+	t.Cleanup(func() { logs = old })
+
+	reconcileArgoCD := newFakeReconcileArgoCD(fake.NewFakeClient(argoCD, argoCDRoute), s)
+	for i := 0; i < 2; i++ {
+		_, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertNoError(t, err)
+	}
+
+	if !strings.Contains(out.String(), `"Action":"created"`) {
+		t.Fatalf("was expecting the creation of the ConsoleLink to be logged, got %s", out)
+	}
+	for _, debug := range []string{"Reconciling ArgoCD", "Creating a new ConsoleLink", "Skip reconcile: ConsoleLink unchanged since last reconcile"} {
+		if strings.Contains(out.String(), debug) {
+			t.Fatalf("debug message %q logged at the default verbosity", debug)
+		}
+	}
+}
+
+func TestReconcile_summary_log(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	out := &bytes.Buffer{}
+	old := logs
+	logs = zap.New(zap.WriteTo(out))
+	t.Cleanup(func() { logs = old })
+
+	fakeClient := fake.NewFakeClient(argoCD, argoCDRoute)
+	reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+	assertSummary := func(wantAction linkAction, wantHref string) {
+		t.Helper()
+		out.Reset()
+		_, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertNoError(t, err)
+
+		var summaries []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			entry := map[string]interface{}{}
+			assertNoError(t, json.Unmarshal([]byte(line), &entry))
+			if entry["msg"] == "Reconciled ArgoCD" {
+				summaries = append(summaries, entry)
+			}
+		}
+		if len(summaries) != 1 {
+			t.Fatalf("got %d summary lines, want 1: %s", len(summaries), out)
+		}
+		if summaries[0]["Action"] != string(wantAction) || summaries[0]["Href"] != wantHref {
+			t.Fatalf("got summary %v, want action %s and href %s", summaries[0], wantAction, wantHref)
+		}
+		if summaries[0]["Request.Name"] != argocdInstanceName || summaries[0]["Request.Namespace"] != argocdNS {
+			t.Fatalf("got summary %v, want the instance %s/%s", summaries[0], argocdNS, argocdInstanceName)
+		}
+	}
+
+	assertSummary(linkCreated, "https://test.com")
+	assertSummary(linkUnchanged, "https://test.com")
+
+	route := argoCDRoute.DeepCopy()
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: route.Name, Namespace: route.Namespace}, route))
+	route.Spec.Host = "new.test.com"
+	assertNoError(t, fakeClient.Update(context.TODO(), route))
+	assertSummary(linkUpdated, "https://new.test.com")
+
+	assertNoError(t, fakeClient.Delete(context.TODO(), argoCD.DeepCopy()))
+	assertSummary(linkDeleted, "")
+}
+
+// countingClient counts the ConsoleLink reads and writes going through it
+type countingClient struct {
+	client.Client
+	reads  int
+	writes int
+}
+
+func (c *countingClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if _, ok := obj.(*console.ConsoleLink); ok {
+		c.reads++
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c *countingClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	if _, ok := obj.(*console.ConsoleLink); ok {
+		c.writes++
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *countingClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	if _, ok := obj.(*console.ConsoleLink); ok {
+		c.writes++
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+// slowCreateClient delays returning ConsoleLink reads, widening the window between a
+// reconcile finding no link and creating it, and counts the creates
+type slowCreateClient struct {
+	client.Client
+	mu      sync.Mutex
+	creates int
+}
+
+func (c *slowCreateClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	err := c.Client.Get(ctx, key, obj)
+	if _, ok := obj.(*console.ConsoleLink); ok {
+		time.Sleep(50 * time.Millisecond)
+	}
+	return err
+}
+
+func (c *slowCreateClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	if _, ok := obj.(*console.ConsoleLink); ok {
+		c.mu.Lock()
+		c.creates++
+		c.mu.Unlock()
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func TestReconcile_concurrent_same_instance(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	slowClient := &slowCreateClient{Client: fake.NewFakeClient(argoCD, argoCDRoute)}
+	reconcileArgoCD := newReconcilerFromConfig(slowClient, s, DefaultConfig())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assertNoError(t, err)
+	}
+
+	if slowClient.creates != 1 {
+		t.Fatalf("got %d ConsoleLink creates, want 1", slowClient.creates)
+	}
+	if len(reconcileArgoCD.instanceLocks.locks) != 0 {
+		t.Fatalf("got %d instance locks left, want none", len(reconcileArgoCD.instanceLocks.locks))
+	}
+}
+
+func TestReconcile_restores_consolelink_changed_by_hand(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	t.Run("deleted", func(t *testing.T) {
+		fakeClient := fake.NewFakeClient(argoCD, argoCDRoute)
+		reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+		_, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertNoError(t, err)
+
+		link, err := getConsoleLink(fakeClient)
+		assertNoError(t, err)
+		assertNoError(t, fakeClient.Delete(context.TODO(), link))
+
+		result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink("https://test.com", "ArgoCD"))
+	})
+	t.Run("edited", func(t *testing.T) {
+		fakeClient := fake.NewFakeClient(argoCD, argoCDRoute)
+		reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+		_, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertNoError(t, err)
+
+		link, err := getConsoleLink(fakeClient)
+		assertNoError(t, err)
+		link.Spec.Href = "https://drifted.test.com"
+		assertNoError(t, fakeClient.Update(context.TODO(), link))
+
+		result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink("https://test.com", "ArgoCD"))
+	})
+}
+
+func TestReconcile_periodic_resync(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	fakeClient := fake.NewFakeClient(argoCD, argoCDRoute)
+	config := DefaultConfig()
+	config.ResyncInterval = 10 * time.Minute
+	reconcileArgoCD := newReconcilerFromConfig(fakeClient, s, config)
+
+	result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+	if result.RequeueAfter != 10*time.Minute {
+		t.Fatalf("got RequeueAfter %v, want %v", result.RequeueAfter, 10*time.Minute)
+	}
+
+	// the link is edited directly, the resync puts it back
+	link, err := getConsoleLink(fakeClient)
+	assertNoError(t, err)
+	link.Spec.Href = "https://drifted.test.com"
+	assertNoError(t, fakeClient.Update(context.TODO(), link))
+	consoleLinks(reconcileArgoCD).lastAppliedAt = time.Now().Add(-10 * time.Minute)
+
+	result, err = reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink("https://test.com", "ArgoCD"))
+	if result.RequeueAfter != 10*time.Minute {
+		t.Fatalf("got RequeueAfter %v, want %v", result.RequeueAfter, 10*time.Minute)
+	}
+}
+
+func TestBuildConsoleLink(t *testing.T) {
+	httpConfig := DefaultConfig()
+	httpConfig.URLScheme = "http"
+	noScheme := DefaultConfig()
+	noScheme.URLScheme = ""
+	helpMenu := DefaultConfig()
+	helpMenu.Location = console.HelpMenu
+
+	tests := []struct {
+		name   string
+		host   string
+		config Config
+		want   string
+	}{
+		{"host", "argocd.test.com", DefaultConfig(), "https://argocd.test.com"},
+		{"host with path", "apps.test.com/argocd", DefaultConfig(), "https://apps.test.com/argocd"},
+		{"host with trailing slash", "apps.test.com/argocd/", DefaultConfig(), "https://apps.test.com/argocd"},
+		{"http scheme", "argocd.test.com", httpConfig, "http://argocd.test.com"},
+		{"default scheme", "argocd.test.com", noScheme, "https://argocd.test.com"},
+		{"help menu", "argocd.test.com", helpMenu, "https://argocd.test.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuildConsoleLink(tt.host, tt.config)
+			assertNoError(t, err)
+
+			want := newConsoleLink(tt.want, tt.config.LinkText)
+			want.TypeMeta = v1.TypeMeta{APIVersion: "console.openshift.io/v1", Kind: "ConsoleLink"}
+			want.Annotations = map[string]string{argocdNamespaceAnnotation: argocdNS}
+			if tt.config.Location != console.ApplicationMenu {
+				want.Spec.Location = tt.config.Location
+				want.Spec.ApplicationMenu = nil
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Fatalf("ConsoleLink mismatch: %v", diff)
+			}
+		})
+	}
+}
+
+func TestBuildConsoleLink_invalid(t *testing.T) {
+	ftpConfig := DefaultConfig()
+	ftpConfig.URLScheme = "ftp"
+	noText := DefaultConfig()
+	noText.LinkText = " "
+	longText := DefaultConfig()
+	longText.LinkText = strings.Repeat("a", maxLinkTextLength+1)
+	longSection := DefaultConfig()
+	longSection.Section = strings.Repeat("a", maxSectionLength+1)
+
+	tests := []struct {
+		name   string
+		host   string
+		config Config
+		field  string
+		reason string
+	}{
+		{"empty host", "", DefaultConfig(), "host", "must not be empty"},
+		{"malformed scheme", "argocd.test.com", ftpConfig, "href", "scheme must be http or https"},
+		{"missing host", "/argocd", DefaultConfig(), "href", "missing host"},
+		{"empty text", "argocd.test.com", noText, "text", "must not be empty"},
+		{"long text", "argocd.test.com", longText, "text", "must be at most 128 characters"},
+		{"long section", "argocd.test.com", longSection, "section", "must be at most 64 characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link, err := BuildConsoleLink(tt.host, tt.config)
+			invalid := &InvalidConsoleLinkError{}
+			if !goerrors.As(err, &invalid) {
+				t.Fatalf("got link %v and error %v, want an InvalidConsoleLinkError", link, err)
+			}
+			if invalid.Field != tt.field || invalid.Reason != tt.reason {
+				t.Fatalf("got invalid %s: %s, want invalid %s: %s", invalid.Field, invalid.Reason, tt.field, tt.reason)
+			}
+		})
+	}
+}
+
+func TestReconcile_invalid_consolelink(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	fakeClient := fake.NewFakeClient(argoCD, argoCDRoute)
+	config := DefaultConfig()
+	config.LinkText = strings.Repeat("a", maxLinkTextLength+1)
+	reconcileArgoCD := newReconcilerFromConfig(fakeClient, s, config)
+	recorder := record.NewFakeRecorder(1)
+	reconcileArgoCD.recorder = recorder
+
+	result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+	if result != (reconcile.Result{}) {
+		t.Fatalf("got result %v, want no requeue", result)
+	}
+	if _, err := getConsoleLink(fakeClient); !apierrors.IsNotFound(err) {
+		t.Fatalf("got error %v, want the invalid ConsoleLink not to be created", err)
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Warning "+invalidConsoleLinkReason+" invalid ConsoleLink text") {
+		t.Fatalf("got event %q, want an %s warning", event, invalidConsoleLinkReason)
+	}
+}
+
+func TestReconcile_ca_bundle(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	t.Run("ConfigMap created and referenced", func(t *testing.T) {
+		fakeClient := fake.NewFakeClient(argoCD, argoCDRoute)
+		config := DefaultConfig()
+		config.CABundleConfigMap = "argocd-ca"
+		reconcileArgoCD := newReconcilerFromConfig(fakeClient, s, config)
+
+		_, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertNoError(t, err)
+
+		link, err := getConsoleLink(fakeClient)
+		assertNoError(t, err)
+		if got := link.Annotations[caBundleAnnotation]; got != "argocd/argocd-ca" {
+			t.Fatalf("got CA bundle annotation %q, want %q", got, "argocd/argocd-ca")
+		}
+		configMap := &corev1.ConfigMap{}
+		assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "argocd-ca", Namespace: argocdNS}, configMap))
+		if configMap.Labels[injectTrustedCABundleLabel] != "true" {
+			t.Fatalf("got labels %v, want the trusted CA bundle to be injected", configMap.Labels)
+		}
+	})
+	t.Run("existing ConfigMap untouched", func(t *testing.T) {
+		existing := &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{Name: "internal-ca", Namespace: argocdNS},
+			Data:       map[string]string{"ca-bundle.crt": "-----BEGIN CERTIFICATE-----"},
+		}
+		fakeClient := fake.NewFakeClient(argoCD, argoCDRoute, existing)
+		config := DefaultConfig()
+		config.CABundleConfigMap = "internal-ca"
+		reconcileArgoCD := newReconcilerFromConfig(fakeClient, s, config)
+
+		_, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertNoError(t, err)
+
+		configMap := &corev1.ConfigMap{}
+		assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "internal-ca", Namespace: argocdNS}, configMap))
+		if diff := cmp.Diff(existing.Data, configMap.Data); diff != "" || len(configMap.Labels) != 0 {
+			t.Fatalf("existing ConfigMap modified: %v %v", diff, configMap.Labels)
+		}
+	})
+	t.Run("not referenced by default", func(t *testing.T) {
+		link, err := BuildConsoleLink("argocd.test.com", DefaultConfig())
+		assertNoError(t, err)
+		if _, ok := link.Annotations[caBundleAnnotation]; ok {
+			t.Fatalf("got annotations %v, want no CA bundle reference", link.Annotations)
+		}
+	})
+}
+
+func TestReconcile_update_conflict(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	drifted := newConsoleLink("https://drifted.test.com", "ArgoCD")
+	fakeClient := &conflictingClient{Client: fake.NewFakeClient(argoCD, argoCDRoute, drifted), conflicts: 1}
+	reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+
+	result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink("https://test.com", "ArgoCD"))
+	if fakeClient.updates != 2 {
+		t.Fatalf("got %d updates, want 2", fakeClient.updates)
+	}
+	got, err := getConsoleLink(fakeClient)
+	assertNoError(t, err)
+	if got.Annotations["example.com/edited-by"] != "console" {
+		t.Fatalf("got annotations %v, want the concurrent edit to be kept", got.Annotations)
+	}
+}
+
+func TestReconcile_consolelink_collision(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	// a link created by a user for another purpose
+	unmanaged := &console.ConsoleLink{
+		ObjectMeta: v1.ObjectMeta{Name: consoleLinkName},
+		Spec: console.ConsoleLinkSpec{
+			Link:     console.Link{Text: "Argo Workflows", Href: "https://workflows.example.com"},
+			Location: console.HelpMenu,
+		},
+	}
+	fakeClient := fake.NewFakeClient(argoCD, argoCDRoute, unmanaged.DeepCopy())
+	reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+	recorder := record.NewFakeRecorder(1)
+	reconcileArgoCD.recorder = recorder
+
+	result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+	if result.RequeueAfter != collisionRequeueDelay {
+		t.Fatalf("got requeue after %v, want %v", result.RequeueAfter, collisionRequeueDelay)
+	}
+	got, err := getConsoleLink(fakeClient)
+	assertNoError(t, err)
+	if diff := cmp.Diff(unmanaged.Spec, got.Spec); diff != "" || isManaged(got) {
+		t.Fatalf("the unmanaged ConsoleLink was overwritten: %v", diff)
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Warning "+consoleLinkCollisionReason) {
+		t.Fatalf("got event %q, want a %s warning", event, consoleLinkCollisionReason)
+	}
+
+	// nor is it deleted with the ArgoCD instance
+	assertNoError(t, fakeClient.Delete(context.TODO(), argoCD.DeepCopy()))
+	_, err = reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+	_, err = getConsoleLink(fakeClient)
+	assertNoError(t, err)
+}
+
+func TestReconcile_stale_icon(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	// a link created by an operator version embedding another icon
+	stale := newConsoleLink("https://test.com", "ArgoCD")
+	stale.Spec.ApplicationMenu.ImageURL = "data:image/png;base64,c3RhbGU="
+	fakeClient := fake.NewFakeClient(argoCD, argoCDRoute, stale)
+	reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+
+	result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink("https://test.com", "ArgoCD"))
+	got, err := getConsoleLink(fakeClient)
+	assertNoError(t, err)
+	if got.Spec.ApplicationMenu.ImageURL != embeddedImageURL() {
+		t.Fatal("got the stale icon, want the embedded one")
+	}
+
+	// an icon which could not be loaded doesn't remove the existing one
+	desired := newConsoleLink("https://test.com", "ArgoCD")
+	desired.Spec.ApplicationMenu.ImageURL = ""
+	if staleIcon(got, desired) || desired.Spec.ApplicationMenu.ImageURL != got.Spec.ApplicationMenu.ImageURL {
+		t.Fatal("the existing icon should be kept")
+	}
+}
+
+func TestReconcile_malformed_consolelink(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	noHref := newConsoleLink("", "ArgoCD")
+	noApplicationMenu := newConsoleLink("https://test.com", "ArgoCD")
+	noApplicationMenu.Spec.ApplicationMenu = nil
+	noImage := newConsoleLink("https://test.com", "ArgoCD")
+	noImage.Spec.ApplicationMenu.ImageURL = ""
+	if problem := malformedConsoleLink(noImage); problem != "" {
+		t.Fatalf("got problem %q for a link without an image, want none", problem)
+	}
+
+	for name, malformed := range map[string]*console.ConsoleLink{"empty href": noHref, "no application menu": noApplicationMenu} {
+		t.Run(name, func(t *testing.T) {
+			if problem := malformedConsoleLink(malformed); problem != name {
+				t.Fatalf("got problem %q, want %q", problem, name)
+			}
+			fakeClient := fake.NewFakeClient(argoCD, argoCDRoute, malformed)
+			reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+
+			result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+			assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink("https://test.com", "ArgoCD"))
+		})
+	}
+}
+
+func TestReconcile_argocd_namespace_migration(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	// the link created while ArgoCD was deployed in the default namespace
+	fakeClient := fake.NewFakeClient(argoCD, argoCDRoute)
+	c := &deleteRecordingClient{Client: fakeClient}
+	_, err := newFakeReconcileArgoCD(c, s).Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+	old, err := getConsoleLink(fakeClient)
+	assertNoError(t, err)
+
+	config := DefaultConfig()
+	config.Namespace = "gitops"
+	instance := argoCD.DeepCopy()
+	instance.Namespace = "gitops"
+	route := argoCDRoute.DeepCopy()
+	route.Namespace = "gitops"
+	route.Spec.Host = "gitops.test.com"
+	assertNoError(t, fakeClient.Create(context.TODO(), instance))
+	assertNoError(t, fakeClient.Create(context.TODO(), route))
+
+	result, err := newReconcilerFromConfig(c, s, config).Reconcile(newRequest("gitops", argocdInstanceName))
+	assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink("https://gitops.test.com", "ArgoCD"))
+	if diff := cmp.Diff([]types.UID{old.UID}, c.deleted); diff != "" {
+		t.Fatalf("deleted ConsoleLinks mismatch: %v", diff)
+	}
+	got, err := getConsoleLink(fakeClient)
+	assertNoError(t, err)
+	if got.Annotations[argocdNamespaceAnnotation] != "gitops" {
+		t.Fatalf("got annotations %v, want the link to record the gitops namespace", got.Annotations)
+	}
+}
+
+// deleteRecordingClient records the UIDs of the ConsoleLinks it deletes
+type deleteRecordingClient struct {
+	client.Client
+	deleted []types.UID
+}
+
+func (c *deleteRecordingClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	if link, ok := obj.(*console.ConsoleLink); ok {
+		c.deleted = append(c.deleted, link.UID)
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func TestReconcile_consolelink_annotations(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	annotations, err := parseAnnotations("console.openshift.io/open-in-new-tab=true, example.com/team=gitops")
+	assertNoError(t, err)
+
+	fakeClient := fake.NewFakeClient(argoCD, argoCDRoute)
+	config := DefaultConfig()
+	config.Annotations = annotations
+	reconcileArgoCD := newReconcilerFromConfig(fakeClient, s, config)
+
+	_, err = reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+
+	got, err := getConsoleLink(fakeClient)
+	assertNoError(t, err)
+	want := map[string]string{"console.openshift.io/open-in-new-tab": "true", "example.com/team": "gitops", argocdNamespaceAnnotation: argocdNS}
+	if diff := cmp.Diff(want, got.Annotations); diff != "" {
+		t.Fatalf("ConsoleLink annotations mismatch: %v", diff)
+	}
+}
+
+func TestParseAnnotations(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"a=b", map[string]string{"a": "b"}, false},
+		{"example.com/a=https://test.com,b=", map[string]string{"example.com/a": "https://test.com", "b": ""}, false},
+		{"a", nil, true},
+		{"=b", nil, true},
+		{"a b=c", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseAnnotations(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseAnnotations(%q) got error %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("parseAnnotations(%q) mismatch: %v", tt.value, diff)
+		}
+	}
+}
+
+func TestReconcile_consolelink_location(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	// an application menu link is turned into a help menu one, the section
+	// only applying to application menu links
+	existing := newConsoleLink("https://test.com", "ArgoCD")
+	fakeClient := fake.NewFakeClient(argoCD, argoCDRoute, existing)
+	config := DefaultConfig()
+	config.Location = console.HelpMenu
+	config.Section = "Continuous Delivery"
+	reconcileArgoCD := newReconcilerFromConfig(fakeClient, s, config)
+
+	_, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+
+	got, err := getConsoleLink(fakeClient)
+	assertNoError(t, err)
+	if got.Spec.Location != console.HelpMenu {
+		t.Fatalf("got location %s, want %s", got.Spec.Location, console.HelpMenu)
+	}
+	if got.Spec.ApplicationMenu != nil {
+		t.Fatalf("got ApplicationMenu %v, want nil", got.Spec.ApplicationMenu)
+	}
+}
+
+func TestReconcile_consolelink_namespace_dashboard(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	tests := []struct {
+		name       string
+		namespaces []string
+		want       []string
+	}{
+		{"defaults to the ArgoCD namespace", nil, []string{argocdNS}},
+		{"configured namespaces", []string{"team-a", "team-b"}, []string{"team-a", "team-b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClient := &patchRecordingClient{Client: fake.NewFakeClient(argoCD, argoCDRoute)}
+			config := DefaultConfig()
+			config.Location = namespaceDashboard
+			config.DashboardNamespaces = tt.namespaces
+			reconcileArgoCD := newReconcilerFromConfig(fakeClient, s, config)
+
+			_, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+			assertNoError(t, err)
+
+			got, err := getConsoleLink(fakeClient)
+			assertNoError(t, err)
+			if got.Spec.Location != namespaceDashboard || got.Spec.ApplicationMenu != nil {
+				t.Fatalf("got location %s with ApplicationMenu %v", got.Spec.Location, got.Spec.ApplicationMenu)
+			}
+			if len(fakeClient.patches) != 1 {
+				t.Fatalf("got %d patches, want 1", len(fakeClient.patches))
+			}
+			patch := struct {
+				Spec struct {
+					NamespaceDashboard struct {
+						Namespaces []string `json:"namespaces"`
+					} `json:"namespaceDashboard"`
+				} `json:"spec"`
+			}{}
+			assertNoError(t, json.Unmarshal(fakeClient.patches[0], &patch))
+			if diff := cmp.Diff(tt.want, patch.Spec.NamespaceDashboard.Namespaces); diff != "" {
+				t.Fatalf("NamespaceDashboard namespaces mismatch: %v", diff)
+			}
+		})
+	}
+}
+
+// conflictingClient fails the first conflicts ConsoleLink updates as if the
+// console had edited the link concurrently
+type conflictingClient struct {
+	client.Client
+	conflicts int
+	updates   int
+}
+
+func (c *conflictingClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	link, ok := obj.(*console.ConsoleLink)
+	if !ok {
+		return c.Client.Update(ctx, obj, opts...)
+	}
+	c.updates++
+	if c.updates <= c.conflicts {
+		edited := &console.ConsoleLink{}
+		if err := c.Client.Get(ctx, types.NamespacedName{Name: link.Name}, edited); err != nil {
+			return err
+		}
+		edited.Annotations = map[string]string{"example.com/edited-by": "console"}
+		if err := c.Client.Update(ctx, edited); err != nil {
+			return err
+		}
+		return apierrors.NewConflict(schema.GroupResource{Group: "console.openshift.io", Resource: "consolelinks"}, link.Name, goerrors.New("the object has been modified"))
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func TestReconcile_consolelink_forbidden(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	c := &forbiddenClient{Client: fake.NewFakeClient(argoCD, argoCDRoute)}
+	reconcileArgoCD := newFakeReconcileArgoCD(c, s)
+
+	for i := 0; i < 2; i++ {
+		_, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertNoError(t, err)
+	}
+	if c.calls != 1 {
+		t.Fatalf("got %d ConsoleLink calls, want ConsoleLink management to be off after the first one", c.calls)
+	}
+
+	// removing the link is skipped too
+	assertNoError(t, c.Delete(context.TODO(), argoCD.DeepCopy()))
+	_, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+	if c.calls != 1 {
+		t.Fatalf("got %d ConsoleLink calls after deleting the instance, want 1", c.calls)
+	}
+}
+
+// forbiddenClient denies access to ConsoleLinks as namespace-scoped RBAC would
+type forbiddenClient struct {
+	client.Client
+	calls int
+}
+
+func (c *forbiddenClient) forbidden(obj runtime.Object) error {
+	switch obj.(type) {
+	case *console.ConsoleLink, *console.ConsoleLinkList:
+		c.calls++
+		return apierrors.NewForbidden(schema.GroupResource{Group: console.GroupName, Resource: consoleLinkResource}, "", goerrors.New("cluster-scoped access denied"))
+	}
+	return nil
+}
+
+func (c *forbiddenClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if err := c.forbidden(obj); err != nil {
+		return err
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c *forbiddenClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	if err := c.forbidden(list); err != nil {
+		return err
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
+func (c *forbiddenClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	if err := c.forbidden(obj); err != nil {
+		return err
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+// patchRecordingClient records the data of the patches it applies
+type patchRecordingClient struct {
+	client.Client
+	patches [][]byte
+}
+
+func (c *patchRecordingClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+	c.patches = append(c.patches, data)
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func TestParseLocation(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    console.ConsoleLinkLocation
+		wantErr bool
+	}{
+		{"", console.ApplicationMenu, false},
+		{"ApplicationMenu", console.ApplicationMenu, false},
+		{"HelpMenu", console.HelpMenu, false},
+		{"UserMenu", console.UserMenu, false},
+		{"NamespaceDashboard", namespaceDashboard, false},
+		{"helpmenu", "", true},
+		{"Sidebar", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseLocation(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseLocation(%q) got error %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseLocation(%q) got %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	t.Run("ConsoleLink removed when cleanup is enabled", func(t *testing.T) {
+		setEnv(t, cleanupOnShutdownEnvVar, "true")
+		fakeClient := fake.NewFakeClient(newConsoleLink("https://test.com", "ArgoCD"))
+
+		err := Shutdown(fakeClient)
+		assertConsoleLinkDeletion(t, fakeClient, reconcileResult{err: err})
+	})
+	t.Run("ConsoleLink kept when cleanup is disabled", func(t *testing.T) {
+		setEnv(t, cleanupOnShutdownEnvVar, "")
+		fakeClient := fake.NewFakeClient(newConsoleLink("https://test.com", "ArgoCD"))
+
+		err := Shutdown(fakeClient)
+		assertNoError(t, err)
+		_, err = getConsoleLink(fakeClient)
+		assertNoError(t, err)
+	})
+	t.Run("No error when ConsoleLink is absent", func(t *testing.T) {
+		setEnv(t, cleanupOnShutdownEnvVar, "true")
+
+		err := Shutdown(fake.NewFakeClient())
+		assertNoError(t, err)
+	})
+	t.Run("ConsoleCLIDownload removed with the ConsoleLink", func(t *testing.T) {
+		setEnv(t, cleanupOnShutdownEnvVar, "true")
+		setEnv(t, cliDownloadEnvVar, "true")
+		fakeClient := fake.NewFakeClient(newConsoleLink("https://test.com", "ArgoCD"), newCLIDownload("https://test.com"))
+
+		err := Shutdown(fakeClient)
+		assertConsoleLinkDeletion(t, fakeClient, reconcileResult{err: err})
+		err = fakeClient.Get(context.TODO(), types.NamespacedName{Name: cliDownloadName}, &console.ConsoleCLIDownload{})
+		if !apierrors.IsNotFound(err) {
+			t.Fatalf("got error %v, want the ConsoleCLIDownload to be removed", err)
+		}
+	})
+	t.Run("ConsoleLink not managed by the operator kept", func(t *testing.T) {
+		setEnv(t, cleanupOnShutdownEnvVar, "true")
+		unmanaged := newConsoleLink("https://argocd.example.com", "ArgoCD")
+		unmanaged.Labels = nil
+		fakeClient := fake.NewFakeClient(unmanaged)
+
+		err := Shutdown(fakeClient)
+		assertNoError(t, err)
+		_, err = getConsoleLink(fakeClient)
+		assertNoError(t, err)
+	})
+}
+
+func TestArgoCDAPIAvailable(t *testing.T) {
+	argoCDGVK := argoprojv1alpha1.SchemeGroupVersion.WithKind(argocdKind)
+
+	t.Run("CRD installed", func(t *testing.T) {
+		mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{argoCDGVK.GroupVersion()})
+		mapper.Add(argoCDGVK, meta.RESTScopeNamespace)
+		recorder := record.NewFakeRecorder(1)
+
+		if !argoCDAPIAvailable(mapper, recorder, argocdNS) {
+			t.Fatal("expected the ArgoCD API to be available")
+		}
+		if len(recorder.Events) != 0 {
+			t.Fatalf("got event %q, want none", <-recorder.Events)
+		}
+	})
+	t.Run("CRD missing", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(1)
+
+		if argoCDAPIAvailable(meta.NewDefaultRESTMapper(nil), recorder, argocdNS) {
+			t.Fatal("expected the ArgoCD API not to be available")
+		}
+		if len(recorder.Events) != 1 {
+			t.Fatalf("got %d events, want 1", len(recorder.Events))
+		}
+		want := "Warning ArgoCDCRDMissing The ConsoleLink controller is disabled: the ArgoCD.argoproj.io CRD is not installed"
+		if got := <-recorder.Events; got != want {
+			t.Fatalf("got event %q, want %q", got, want)
+		}
+	})
+}
+
+func TestWatchResources(t *testing.T) {
+	argoCDGVK := argoprojv1alpha1.SchemeGroupVersion.WithKind(argocdKind)
+	routeGVK := routev1.GroupVersion.WithKind(routeKind)
+
+	t.Run("Route watched on OpenShift", func(t *testing.T) {
+		mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{argoCDGVK.GroupVersion(), routeGVK.GroupVersion()})
+		mapper.Add(argoCDGVK, meta.RESTScopeNamespace)
+		mapper.Add(routeGVK, meta.RESTScopeNamespace)
+		c := &fakeController{}
+
+		err := watchResources(c, mapper, &ReconcileArgoCD{})
+		assertNoError(t, err)
+		if c.watches != 2 {
+			t.Fatalf("got %d watches, want 2", c.watches)
+		}
+	})
+	t.Run("Ingress and Service watched without Route API", func(t *testing.T) {
+		mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{argoCDGVK.GroupVersion(), routeGVK.GroupVersion()})
+		mapper.Add(argoCDGVK, meta.RESTScopeNamespace)
+		c := &fakeController{}
+
+		err := watchResources(c, mapper, &ReconcileArgoCD{})
+		assertNoError(t, err)
+		if c.watches != 3 {
+			t.Fatalf("got %d watches, want 3", c.watches)
+		}
+	})
+	t.Run("Route update enqueues the ArgoCD instance", func(t *testing.T) {
+		mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{argoCDGVK.GroupVersion(), routeGVK.GroupVersion()})
+		mapper.Add(argoCDGVK, meta.RESTScopeNamespace)
+		mapper.Add(routeGVK, meta.RESTScopeNamespace)
+		c := &fakeController{}
+		assertNoError(t, watchResources(c, mapper, &ReconcileArgoCD{config: DefaultConfig()}))
+
+		// the route is not owned by the ArgoCD instance
+		oldRoute, newRoute := argoCDRoute.DeepCopy(), argoCDRoute.DeepCopy()
+		newRoute.Spec.Host = "updated.example.com"
+		queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+		defer queue.ShutDown()
+		c.handlers[1].Update(event.UpdateEvent{MetaOld: oldRoute, ObjectOld: oldRoute, MetaNew: newRoute, ObjectNew: newRoute}, queue)
+
+		if queue.Len() != 1 {
+			t.Fatalf("got %d requests, want 1", queue.Len())
+		}
+		item, _ := queue.Get()
+		if want := newRequest(argocdNS, argocdInstanceName); item != want {
+			t.Fatalf("got request %v, want %v", item, want)
+		}
+	})
+	t.Run("Watches require a reconciler", func(t *testing.T) {
+		c := &fakeController{}
+
+		err := watchResources(c, meta.NewDefaultRESTMapper(nil), nil)
+		if err == nil {
+			t.Fatal("was expecting an error, but got nil")
+		}
+		if c.watches != 0 {
+			t.Fatalf("got %d watches, want 0", c.watches)
+		}
+	})
+}
+
+func TestCheckScheme(t *testing.T) {
+	s := runtime.NewScheme()
+	s.AddKnownTypes(argoprojv1alpha1.SchemeGroupVersion, &argoprojv1alpha1.ArgoCD{})
+
+	err := checkScheme(s)
+	if err == nil {
+		t.Fatal("was expecting an error for the missing Route and ConsoleLink types")
+	}
+	for _, kind := range []string{"Kind=Route", "Kind=ConsoleLink"} {
+		if !strings.Contains(err.Error(), kind) {
+			t.Fatalf("error %q does not name the missing %s", err, kind)
+		}
+	}
+
+	addKnownTypesToScheme(s)
+	assertNoError(t, checkScheme(s))
+}
+
+// fakeController counts the watches registered on it and keeps their handlers
+type fakeController struct {
+	reconcile.Reconciler
+	watches  int
+	handlers []handler.EventHandler
+}
+
+func (c *fakeController) Watch(src source.Source, eventhandler handler.EventHandler, predicates ...predicate.Predicate) error {
+	c.watches++
+	c.handlers = append(c.handlers, eventhandler)
+	return nil
+}
+
+func (c *fakeController) Start(stop <-chan struct{}) error {
+	return nil
+}
+
+// setEnv sets an environment variable for the duration of the test
+func setEnv(t *testing.T, key, value string) {
+	t.Helper()
+	old, ok := os.LookupEnv(key)
+	assertNoError(t, os.Setenv(key, value))
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+			return
+		}
+		os.Unsetenv(key)
+	})
+}
+
+func newNamespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: v1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
 	}
 }
 
+func newFakeReconcileArgoCD(client client.Client, scheme *runtime.Scheme) *ReconcileArgoCD {
+	return newReconcilerFromConfig(client, scheme, DefaultConfig())
+}
+
+// consoleLinks returns the default ConsoleLink registrar of the reconciler
+func consoleLinks(r *ReconcileArgoCD) *consoleLinkRegistrar {
+	return r.links.(*consoleLinkRegistrar)
+}
+
 func assertNoError(t *testing.T, err error) {
 	t.Helper()
 	if err != nil {
@@ -94,9 +1864,9 @@ func assertNoError(t *testing.T, err error) {
 }
 
 func addKnownTypesToScheme(scheme *runtime.Scheme) {
-	scheme.AddKnownTypes(argoprojv1alpha1.SchemeGroupVersion, &argoprojv1alpha1.ArgoCD{})
-	scheme.AddKnownTypes(routev1.GroupVersion, &routev1.Route{})
-	scheme.AddKnownTypes(console.GroupVersion, &console.ConsoleLink{})
+	scheme.AddKnownTypes(argoprojv1alpha1.SchemeGroupVersion, &argoprojv1alpha1.ArgoCD{}, &argoprojv1alpha1.ArgoCDList{})
+	scheme.AddKnownTypes(routev1.GroupVersion, &routev1.Route{}, &routev1.RouteList{})
+	scheme.AddKnownTypes(console.GroupVersion, &console.ConsoleLink{}, &console.ConsoleLinkList{}, &console.ConsoleCLIDownload{})
 }
 
 func newRequest(namespace, name string) reconcile.Request {
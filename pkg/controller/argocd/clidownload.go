@@ -0,0 +1,91 @@
+package argocd
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	console "github.com/openshift/api/console/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// cliDownloadName is the name of the ConsoleCLIDownload of the argocd CLI
+	cliDownloadName = "argocd-cli"
+
+	// cliDownloadPath is where argocd-server serves the argocd CLI binaries
+	cliDownloadPath = "/download/argocd-"
+)
+
+// newCLIDownload returns the ConsoleCLIDownload of the argocd CLI served by
+// the ArgoCD server at href
+func newCLIDownload(href string) *console.ConsoleCLIDownload {
+	return &console.ConsoleCLIDownload{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: cliDownloadName,
+			Labels: map[string]string{
+				managedByLabel: managedByValue,
+			},
+		},
+		Spec: console.ConsoleCLIDownloadSpec{
+			DisplayName: "argocd - Argo CD Command Line Interface",
+			Description: "The argocd CLI manages ArgoCD applications, projects and clusters from the command line.",
+			Links: []console.Link{
+				{Text: "Download argocd for Linux for x86_64", Href: href + cliDownloadPath + "linux-amd64"},
+				{Text: "Download argocd for Mac for x86_64", Href: href + cliDownloadPath + "darwin-amd64"},
+			},
+		},
+	}
+}
+
+// ensureCLIDownload creates the ConsoleCLIDownload of the argocd CLI served
+// by the ArgoCD server at href, or updates it if href changed
+func (r *consoleLinkRegistrar) ensureCLIDownload(ctx context.Context, href string, log logr.Logger) error {
+	cliDownload := newCLIDownload(href)
+	found := &console.ConsoleCLIDownload{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: cliDownload.Name}, found)
+	if errors.IsNotFound(err) {
+		log.Info("Creating ConsoleCLIDownload", "ConsoleCLIDownload.Name", cliDownload.Name)
+		return r.client.Create(ctx, cliDownload)
+	}
+	if err != nil {
+		return err
+	}
+	if equality.Semantic.DeepEqual(found.Spec, cliDownload.Spec) {
+		return nil
+	}
+	log.Info("Updating ConsoleCLIDownload", "ConsoleCLIDownload.Name", cliDownload.Name)
+	found.Spec = cliDownload.Spec
+	return r.client.Update(ctx, found)
+}
+
+// deleteCLIDownload deletes the ConsoleCLIDownload of the argocd CLI if it is present
+func (r *consoleLinkRegistrar) deleteCLIDownload(ctx context.Context, log logr.Logger) error {
+	return deleteCLIDownload(ctx, r.client, log)
+}
+
+// deleteCLIDownload deletes the ConsoleCLIDownload of the argocd CLI if it is
+// present and managed by the operator
+func deleteCLIDownload(ctx context.Context, c client.Client, log logr.Logger) error {
+	found := &console.ConsoleCLIDownload{}
+	err := c.Get(ctx, types.NamespacedName{Name: cliDownloadName}, found)
+	if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if found.Labels[managedByLabel] != managedByValue {
+		log.Info("Keeping ConsoleCLIDownload not managed by the operator", "ConsoleCLIDownload.Name", cliDownloadName)
+		return nil
+	}
+	log.Info("Deleting ConsoleCLIDownload", "ConsoleCLIDownload.Name", cliDownloadName)
+	if err := c.Delete(ctx, found); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
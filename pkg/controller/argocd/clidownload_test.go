@@ -0,0 +1,58 @@
+package argocd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	console "github.com/openshift/api/console/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcile_cli_download(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	t.Run("enabled", func(t *testing.T) {
+		fakeClient := fake.NewFakeClient(argoCD, argoCDRoute)
+		config := DefaultConfig()
+		config.CLIDownload = true
+		reconcileArgoCD := newReconcilerFromConfig(fakeClient, s, config)
+
+		_, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertNoError(t, err)
+
+		got := &console.ConsoleCLIDownload{}
+		assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: cliDownloadName}, got))
+		want := []console.Link{
+			{Text: "Download argocd for Linux for x86_64", Href: "https://test.com/download/argocd-linux-amd64"},
+			{Text: "Download argocd for Mac for x86_64", Href: "https://test.com/download/argocd-darwin-amd64"},
+		}
+		if diff := cmp.Diff(want, got.Spec.Links); diff != "" {
+			t.Fatalf("ConsoleCLIDownload links mismatch: %v", diff)
+		}
+
+		// removed along with the ConsoleLink
+		assertNoError(t, fakeClient.Delete(context.TODO(), argoCD.DeepCopy()))
+		_, err = reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertNoError(t, err)
+		if err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: cliDownloadName}, got); !apierrors.IsNotFound(err) {
+			t.Fatalf("was expecting the ConsoleCLIDownload to be deleted, got error %v", err)
+		}
+	})
+	t.Run("disabled", func(t *testing.T) {
+		fakeClient := fake.NewFakeClient(argoCD, argoCDRoute)
+		reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+
+		_, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertNoError(t, err)
+
+		err = fakeClient.Get(context.TODO(), types.NamespacedName{Name: cliDownloadName}, &console.ConsoleCLIDownload{})
+		if !apierrors.IsNotFound(err) {
+			t.Fatalf("was expecting no ConsoleCLIDownload, got error %v", err)
+		}
+	})
+}
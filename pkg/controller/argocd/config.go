@@ -0,0 +1,452 @@
+package argocd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	console "github.com/openshift/api/console/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// namespaceSelectorEnvVar holds a label selector (e.g. gitops.redhat.com/console=enabled)
+	// restricting ConsoleLinks to ArgoCD instances in matching namespaces
+	namespaceSelectorEnvVar = "CONSOLE_LINK_NAMESPACE_SELECTOR"
+
+	// instanceSelectorEnvVar holds a label selector (e.g. gitops.openshift.io/console-link=true)
+	// restricting ConsoleLinks to ArgoCD instances with matching labels
+	instanceSelectorEnvVar = "CONSOLE_LINK_INSTANCE_SELECTOR"
+
+	// allNamespacesEnvVar enables a ConsoleLink for every ArgoCD instance in
+	// the namespaces watched by the operator rather than only for the
+	// instance in the ArgoCD namespace
+	allNamespacesEnvVar = "CONSOLE_LINK_ALL_NAMESPACES"
+
+	// iconURLEnvVar holds a URL from which the ConsoleLink icon is fetched
+	// instead of using the embedded ArgoCD icon
+	iconURLEnvVar = "CONSOLE_LINK_ICON_URL"
+
+	// iconPathEnvVar holds the path, among the icons bundled with the
+	// operator, of the ConsoleLink icon, e.g. /argo.png
+	iconPathEnvVar = "CONSOLE_LINK_ICON_PATH"
+
+	// iconTTLEnvVar holds how long a fetched icon is cached before being fetched again
+	iconTTLEnvVar = "CONSOLE_LINK_ICON_TTL"
+
+	// cleanupOnShutdownEnvVar enables removing the ConsoleLink when the operator
+	// shuts down, so that uninstalling it doesn't leave a dangling link
+	cleanupOnShutdownEnvVar = "CONSOLE_LINK_CLEANUP_ON_SHUTDOWN"
+
+	// annotationsEnvVar holds comma separated key=value annotations added to the ConsoleLink
+	annotationsEnvVar = "CONSOLE_LINK_ANNOTATIONS"
+
+	// locationEnvVar holds where the console shows the link, one of
+	// ApplicationMenu (the default), HelpMenu, UserMenu or NamespaceDashboard
+	locationEnvVar = "CONSOLE_LINK_LOCATION"
+
+	// sectionEnvVar holds the section of the application menu grouping the
+	// ConsoleLink, e.g. Continuous Delivery to group it with other CD tools
+	sectionEnvVar = "CONSOLE_LINK_SECTION"
+
+	// dashboardNamespacesEnvVar holds the comma separated namespaces whose
+	// dashboard shows a NamespaceDashboard link, the ArgoCD namespace by default
+	dashboardNamespacesEnvVar = "CONSOLE_LINK_NAMESPACES"
+
+	// routeSelectorEnvVar holds a label selector choosing the route of the
+	// ArgoCD UI among the routes of the ArgoCD namespace
+	routeSelectorEnvVar = "CONSOLE_LINK_ROUTE_SELECTOR"
+
+	// routePortEnvVar holds the target port, e.g. http, of the route of the
+	// ArgoCD UI among the routes of the ArgoCD namespace
+	routePortEnvVar = "CONSOLE_LINK_ROUTE_PORT"
+
+	// caBundleConfigMapEnvVar holds the name of a ConfigMap in the ArgoCD
+	// namespace holding the CA bundle trusted for the ArgoCD route, e.g. when
+	// its certificate is self-signed
+	caBundleConfigMapEnvVar = "CONSOLE_LINK_CA_BUNDLE_CONFIGMAP"
+
+	// deepLinkEnvVar holds a path, with an optional query, appended to the
+	// ConsoleLink URL, e.g. /applications?proj=default to open a project view
+	deepLinkEnvVar = "CONSOLE_LINK_DEEP_LINK"
+
+	// cliDownloadEnvVar enables a ConsoleCLIDownload of the argocd CLI
+	// served by the ArgoCD server alongside the ConsoleLink
+	cliDownloadEnvVar = "CONSOLE_LINK_CLI_DOWNLOAD"
+
+	// disableConsoleLinkEnvVar disables the ConsoleLink, removing it if present
+	disableConsoleLinkEnvVar = "DISABLE_CONSOLE_LINK"
+
+	// extraLinksEnvVar holds comma separated text=href pairs of static links,
+	// e.g. Runbook=https://wiki.example.com/argocd, each created as its own
+	// ConsoleLink alongside the link to the ArgoCD UI
+	extraLinksEnvVar = "CONSOLE_LINK_EXTRA_LINKS"
+
+	// detectSchemeEnvVar enables deriving the scheme of the ConsoleLink URL
+	// from the TLS configuration of the route
+	detectSchemeEnvVar = "CONSOLE_LINK_DETECT_SCHEME"
+
+	// maxConcurrentReconcilesEnvVar holds how many ArgoCD instances are
+	// reconciled concurrently, 1 by default
+	maxConcurrentReconcilesEnvVar = "CONSOLE_LINK_MAX_CONCURRENT_RECONCILES"
+
+	// resyncIntervalEnvVar holds how often the ConsoleLink is checked for
+	// drift without an event, e.g. 10m. Periodic resyncs are disabled if unset.
+	resyncIntervalEnvVar = "CONSOLE_LINK_RESYNC_INTERVAL"
+
+	// namespaceDashboard is not yet defined by the vendored console API
+	namespaceDashboard console.ConsoleLinkLocation = "NamespaceDashboard"
+
+	// defaultSection is the section of the application menu grouping the ConsoleLink
+	defaultSection = "Application Stages"
+
+	// maxSectionLength keeps the section readable in the application menu
+	maxSectionLength = 64
+
+	// maxLinkTextLength keeps the text of the link readable in the console
+	// menus, leaving room for the instance of links in all namespaces
+	maxLinkTextLength = 128
+)
+
+// Config holds the settings of the argocd controller
+type Config struct {
+	// Namespace and InstanceName identify the ArgoCD instance linked from the console
+	Namespace    string
+	InstanceName string
+
+	// AllNamespaces links every ArgoCD instance, whatever its namespace and
+	// name, each with its own ConsoleLink named after it
+	AllNamespaces bool
+
+	// ConsoleLinkName is the name of the ConsoleLink created for the instance
+	ConsoleLinkName string
+	// LinkText is the text of the ConsoleLink
+	LinkText string
+	// URLScheme is the scheme of the ConsoleLink URL built from the route host
+	URLScheme string
+	// DetectScheme derives the scheme of the URL from the TLS configuration
+	// of the route instead of using URLScheme: https if it terminates TLS,
+	// whatever the termination, http otherwise
+	DetectScheme bool
+
+	// NamespaceSelector, if set, restricts ConsoleLinks to ArgoCD instances
+	// in namespaces whose labels match
+	NamespaceSelector labels.Selector
+	// InstanceSelector, if set, restricts ConsoleLinks to ArgoCD instances
+	// whose labels match
+	InstanceSelector labels.Selector
+
+	// RouteSelector and RoutePort, if set, select the route of the ArgoCD UI
+	// among the routes of Namespace instead of looking it up by name. Routes
+	// exposing the grpc API are ignored.
+	RouteSelector labels.Selector
+	RoutePort     string
+
+	// IconPath is the path of the icon of the ConsoleLink among the icons
+	// bundled with the operator
+	IconPath string
+
+	// IconURL, if set, replaces the embedded icon with one fetched from this
+	// URL and cached for IconTTL
+	IconURL string
+	IconTTL time.Duration
+
+	// Annotations are added to the ConsoleLink, e.g. as hints for the console
+	Annotations map[string]string
+	// Location is where the console shows the link
+	Location console.ConsoleLinkLocation
+	// Section is the section of the application menu grouping the link when
+	// Location is ApplicationMenu, the default section if empty
+	Section string
+	// DashboardNamespaces are the namespaces whose dashboard shows the link
+	// when Location is NamespaceDashboard, Namespace if empty
+	DashboardNamespaces []string
+
+	// ExtraLinks are static links, e.g. to documentation, each created as its
+	// own ConsoleLink next to the link to the ArgoCD UI
+	ExtraLinks []ExtraLink
+
+	// DeepLink, if set, is the path and query appended to the URL of the
+	// ArgoCD UI, e.g. /applications?proj=default
+	DeepLink string
+
+	// CABundleConfigMap, if set, is the ConfigMap in Namespace holding the CA
+	// bundle trusted for the ArgoCD route. It is created with the cluster
+	// trusted CA bundle injected if absent and referenced by the ConsoleLink.
+	CABundleConfigMap string
+
+	// CLIDownload adds the argocd CLI served by the ArgoCD server to the
+	// command line tools of the console
+	CLIDownload bool
+
+	// Disabled makes the controller remove the ConsoleLink instead of creating it
+	Disabled bool
+
+	// ResyncInterval, if set, requeues successful reconciles so that drift of
+	// the ConsoleLink is corrected even if no event is received
+	ResyncInterval time.Duration
+
+	// CleanupOnShutdown removes the ConsoleLink when the operator shuts down
+	CleanupOnShutdown bool
+
+	// MaxConcurrentReconciles is how many ArgoCD instances are reconciled
+	// concurrently, reconciles of the same instance are never concurrent
+	MaxConcurrentReconciles int
+}
+
+// DefaultConfig returns the configuration used when no overrides are set
+func DefaultConfig() Config {
+	return Config{
+		Namespace:       argocdNS,
+		InstanceName:    argocdInstanceName,
+		ConsoleLinkName: consoleLinkName,
+		LinkText:        "ArgoCD",
+		URLScheme:       "https",
+		IconPath:        iconFilePath,
+		IconTTL:         defaultIconTTL,
+		Location:        console.ApplicationMenu,
+		Section:         defaultSection,
+
+		MaxConcurrentReconciles: 1,
+	}
+}
+
+// ConfigFromEnv returns the default configuration with the overrides set in
+// the environment applied
+func ConfigFromEnv() (Config, error) {
+	config := DefaultConfig()
+	var err error
+	if config.NamespaceSelector, err = selectorFromEnv(namespaceSelectorEnvVar); err != nil {
+		return Config{}, err
+	}
+	if config.InstanceSelector, err = selectorFromEnv(instanceSelectorEnvVar); err != nil {
+		return Config{}, err
+	}
+	if config.RouteSelector, err = selectorFromEnv(routeSelectorEnvVar); err != nil {
+		return Config{}, err
+	}
+	config.RoutePort = strings.TrimSpace(os.Getenv(routePortEnvVar))
+	if config.IconPath, err = parseIconPath(os.Getenv(iconPathEnvVar)); err != nil {
+		// the link is still usable with the default icon
+		logs.Error(err, "Using the default ConsoleLink icon")
+	}
+	config.IconURL = os.Getenv(iconURLEnvVar)
+	if value := os.Getenv(iconTTLEnvVar); value != "" {
+		if config.IconTTL, err = time.ParseDuration(value); err != nil {
+			return Config{}, fmt.Errorf("invalid %s %q: %w", iconTTLEnvVar, value, err)
+		}
+	}
+	if config.Annotations, err = parseAnnotations(os.Getenv(annotationsEnvVar)); err != nil {
+		return Config{}, err
+	}
+	if config.Location, err = parseLocation(os.Getenv(locationEnvVar)); err != nil {
+		return Config{}, err
+	}
+	if config.Section, err = parseSection(os.Getenv(sectionEnvVar)); err != nil {
+		return Config{}, err
+	}
+	config.DashboardNamespaces = parseList(os.Getenv(dashboardNamespacesEnvVar))
+	if config.ExtraLinks, err = parseExtraLinks(os.Getenv(extraLinksEnvVar)); err != nil {
+		return Config{}, err
+	}
+	if config.DeepLink, err = parseDeepLink(os.Getenv(deepLinkEnvVar)); err != nil {
+		return Config{}, err
+	}
+	if config.CABundleConfigMap = strings.TrimSpace(os.Getenv(caBundleConfigMapEnvVar)); config.CABundleConfigMap != "" {
+		if errs := validation.IsDNS1123Subdomain(config.CABundleConfigMap); len(errs) > 0 {
+			return Config{}, fmt.Errorf("invalid %s %q: %s", caBundleConfigMapEnvVar, config.CABundleConfigMap, strings.Join(errs, ", "))
+		}
+	}
+	if value := os.Getenv(resyncIntervalEnvVar); value != "" {
+		if config.ResyncInterval, err = time.ParseDuration(value); err != nil || config.ResyncInterval < 0 {
+			return Config{}, fmt.Errorf("invalid %s %q: must be a positive duration", resyncIntervalEnvVar, value)
+		}
+	}
+	if value := os.Getenv(allNamespacesEnvVar); value != "" {
+		if config.AllNamespaces, err = strconv.ParseBool(value); err != nil {
+			return Config{}, fmt.Errorf("invalid %s %q: %w", allNamespacesEnvVar, value, err)
+		}
+	}
+	if value := os.Getenv(cliDownloadEnvVar); value != "" {
+		if config.CLIDownload, err = strconv.ParseBool(value); err != nil {
+			return Config{}, fmt.Errorf("invalid %s %q: %w", cliDownloadEnvVar, value, err)
+		}
+	}
+	if value := os.Getenv(maxConcurrentReconcilesEnvVar); value != "" {
+		if config.MaxConcurrentReconciles, err = strconv.Atoi(value); err != nil || config.MaxConcurrentReconciles < 1 {
+			return Config{}, fmt.Errorf("invalid %s %q: must be a positive integer", maxConcurrentReconcilesEnvVar, value)
+		}
+	}
+	if value := os.Getenv(detectSchemeEnvVar); value != "" {
+		if config.DetectScheme, err = strconv.ParseBool(value); err != nil {
+			return Config{}, fmt.Errorf("invalid %s %q: %w", detectSchemeEnvVar, value, err)
+		}
+	}
+	if value := os.Getenv(disableConsoleLinkEnvVar); value != "" {
+		if config.Disabled, err = strconv.ParseBool(value); err != nil {
+			return Config{}, fmt.Errorf("invalid %s %q: %w", disableConsoleLinkEnvVar, value, err)
+		}
+	}
+	if value := os.Getenv(cleanupOnShutdownEnvVar); value != "" {
+		if config.CleanupOnShutdown, err = strconv.ParseBool(value); err != nil {
+			return Config{}, fmt.Errorf("invalid %s %q: %w", cleanupOnShutdownEnvVar, value, err)
+		}
+	}
+	return config, nil
+}
+
+// isInstance reports whether namespace and name identify a linked ArgoCD
+// instance, any instance if AllNamespaces is set
+func (c Config) isInstance(namespace, name string) bool {
+	return c.AllNamespaces || (namespace == c.Namespace && name == c.InstanceName)
+}
+
+// routeScheme returns the scheme of the URL of route
+func (c Config) routeScheme(route *routev1.Route) string {
+	if !c.DetectScheme {
+		return c.URLScheme
+	}
+	if route.Spec.TLS != nil && route.Spec.TLS.Termination != "" {
+		return "https"
+	}
+	return "http"
+}
+
+// inNamespace accepts any route in the ArgoCD namespace as the server route
+// name depends on the ArgoCD instance, the reconcile looks up the right one
+func (c Config) inNamespace(namespace, name string) bool {
+	return c.AllNamespaces || namespace == c.Namespace
+}
+
+// forInstance returns the configuration of the ConsoleLink of the ArgoCD
+// instance in namespace with name when AllNamespaces is set. The link is
+// named after the instance and its text tells it apart from the others.
+func (c Config) forInstance(namespace, name string) Config {
+	c.Namespace = namespace
+	c.InstanceName = name
+	c.ConsoleLinkName = fmt.Sprintf("%s-%s-%s", c.ConsoleLinkName, namespace, name)
+	c.LinkText = fmt.Sprintf("%s (%s/%s)", c.LinkText, namespace, name)
+	return c
+}
+
+// parseAnnotations parses comma separated key=value pairs, returning nil if value is empty
+func parseAnnotations(value string) (map[string]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	annotations := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		key := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || key == "" {
+			return nil, fmt.Errorf("invalid %s %q: expected key=value pairs", annotationsEnvVar, value)
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid %s annotation key %q: %s", annotationsEnvVar, key, strings.Join(errs, ", "))
+		}
+		annotations[key] = strings.TrimSpace(kv[1])
+	}
+	return annotations, nil
+}
+
+// parseLocation validates the ConsoleLink location, an empty value selects
+// the ApplicationMenu
+func parseLocation(value string) (console.ConsoleLinkLocation, error) {
+	location := console.ConsoleLinkLocation(strings.TrimSpace(value))
+	switch location {
+	case "":
+		return console.ApplicationMenu, nil
+	case console.ApplicationMenu, console.HelpMenu, console.UserMenu, namespaceDashboard:
+		return location, nil
+	}
+	return "", fmt.Errorf("invalid %s %q: must be one of %s, %s, %s or %s", locationEnvVar, value,
+		console.ApplicationMenu, console.HelpMenu, console.UserMenu, namespaceDashboard)
+}
+
+// parseIconPath returns the path of the bundled icon chosen by value, the
+// default icon if value is empty. If no icon is bundled at that path, the
+// default icon is returned along with an error listing the bundled icons.
+func parseIconPath(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return iconFilePath, nil
+	}
+	name := path.Clean("/" + value)
+	if _, err := readStatikFile(name); err != nil {
+		bundled, walkErr := bundledIconPaths()
+		if walkErr != nil {
+			return iconFilePath, fmt.Errorf("invalid %s %q: %w", iconPathEnvVar, value, err)
+		}
+		return iconFilePath, fmt.Errorf("invalid %s %q: no such icon, the bundled icons are %s", iconPathEnvVar, value, strings.Join(bundled, ", "))
+	}
+	return name, nil
+}
+
+// parseSection validates the application menu section of the ConsoleLink,
+// an empty value selects the default section
+func parseSection(value string) (string, error) {
+	section := strings.TrimSpace(value)
+	if section == "" {
+		return defaultSection, nil
+	}
+	if len(section) > maxSectionLength {
+		return "", fmt.Errorf("invalid %s %q: must be at most %d characters", sectionEnvVar, value, maxSectionLength)
+	}
+	if strings.IndexFunc(section, unicode.IsControl) >= 0 {
+		return "", fmt.Errorf("invalid %s %q: must not contain control characters", sectionEnvVar, value)
+	}
+	return section, nil
+}
+
+// parseDeepLink validates the path and query of a deep link into the ArgoCD
+// UI and returns them escaped, starting with a slash
+func parseDeepLink(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s %q: %w", deepLinkEnvVar, value, err)
+	}
+	if u.Scheme != "" || u.Host != "" || u.User != nil || u.Fragment != "" {
+		return "", fmt.Errorf("invalid %s %q: must be a path with an optional query", deepLinkEnvVar, value)
+	}
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s %q: %w", deepLinkEnvVar, value, err)
+	}
+	deepLink := url.URL{Path: "/" + strings.TrimLeft(u.Path, "/"), RawQuery: query.Encode()}
+	return deepLink.String(), nil
+}
+
+// parseList splits a comma separated list, ignoring empty items
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// selectorFromEnv parses the label selector held by envVar, returning nil if none is configured
+func selectorFromEnv(envVar string) (labels.Selector, error) {
+	value, ok := os.LookupEnv(envVar)
+	if !ok || value == "" {
+		return nil, nil
+	}
+	selector, err := labels.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: %w", envVar, value, err)
+	}
+	return selector, nil
+}
@@ -0,0 +1,268 @@
+package argocd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	argoprojv1alpha1 "github.com/argoproj-labs/argocd-operator/pkg/apis/argoproj/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+	console "github.com/openshift/api/console/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcile_custom_config(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	config := DefaultConfig()
+	config.Namespace = "gitops"
+	config.InstanceName = "main"
+	config.ConsoleLinkName = "gitops-argocd"
+	config.LinkText = "Argo CD"
+	config.URLScheme = "http"
+	config.Section = "Continuous Delivery"
+
+	instance := &argoprojv1alpha1.ArgoCD{ObjectMeta: v1.ObjectMeta{Name: "main", Namespace: "gitops"}}
+	route := &routev1.Route{
+		ObjectMeta: v1.ObjectMeta{Name: "main-server", Namespace: "gitops"},
+		Spec:       routev1.RouteSpec{Host: "gitops.test.com"},
+	}
+	fakeClient := fake.NewFakeClient(instance, route)
+	reconcileArgoCD := newReconcilerFromConfig(fakeClient, s, config)
+
+	_, err := reconcileArgoCD.Reconcile(newRequest("gitops", "main"))
+	assertNoError(t, err)
+
+	got := &console.ConsoleLink{}
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "gitops-argocd"}, got))
+	want := console.Link{Text: "Argo CD", Href: "http://gitops.test.com"}
+	if diff := cmp.Diff(want, got.Spec.Link); diff != "" {
+		t.Fatalf("ConsoleLink mismatch: %v", diff)
+	}
+	if got.Spec.ApplicationMenu == nil || got.Spec.ApplicationMenu.Section != "Continuous Delivery" {
+		t.Fatalf("got application menu %#v, want section Continuous Delivery", got.Spec.ApplicationMenu)
+	}
+	if !config.isInstance("gitops", "main") || config.isInstance(argocdNS, argocdInstanceName) {
+		t.Fatal("the configured instance should be the only one reconciled")
+	}
+}
+
+func TestReconcile_all_namespaces(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	var objs []runtime.Object
+	for _, namespace := range []string{"team-a", "team-b"} {
+		objs = append(objs,
+			&argoprojv1alpha1.ArgoCD{ObjectMeta: v1.ObjectMeta{Name: "argocd", Namespace: namespace}},
+			&routev1.Route{
+				ObjectMeta: v1.ObjectMeta{Name: "argocd-server", Namespace: namespace},
+				Spec:       routev1.RouteSpec{Host: namespace + ".test.com"},
+			})
+	}
+	fakeClient := fake.NewFakeClient(objs...)
+	config := DefaultConfig()
+	config.AllNamespaces = true
+	reconcileArgoCD := newReconcilerFromConfig(fakeClient, s, config)
+
+	for _, namespace := range []string{"team-a", "team-b"} {
+		_, err := reconcileArgoCD.Reconcile(newRequest(namespace, "argocd"))
+		assertNoError(t, err)
+	}
+	for _, namespace := range []string{"team-a", "team-b"} {
+		got := &console.ConsoleLink{}
+		assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "argocd-" + namespace + "-argocd"}, got))
+		want := console.Link{Text: "ArgoCD (" + namespace + "/argocd)", Href: "https://" + namespace + ".test.com"}
+		if diff := cmp.Diff(want, got.Spec.Link); diff != "" {
+			t.Fatalf("ConsoleLink of %s mismatch: %v", namespace, diff)
+		}
+	}
+
+	// route events are mapped to the instances of their namespace
+	if diff := cmp.Diff([]reconcile.Request{newRequest("team-b", "argocd")}, reconcileArgoCD.instancesIn("team-b")); diff != "" {
+		t.Fatalf("requests mismatch: %v", diff)
+	}
+
+	// deleting an instance only removes its own link
+	assertNoError(t, fakeClient.Delete(context.TODO(), objs[0]))
+	_, err := reconcileArgoCD.Reconcile(newRequest("team-a", "argocd"))
+	assertNoError(t, err)
+	links := &console.ConsoleLinkList{}
+	assertNoError(t, fakeClient.List(context.TODO(), links))
+	if len(links.Items) != 1 || links.Items[0].Name != "argocd-team-b-argocd" {
+		t.Fatalf("got ConsoleLinks %v, want only the link of team-b", links.Items)
+	}
+}
+
+func TestReconcile_route_tls_scheme(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	route := argoCDRoute.DeepCopy()
+	route.Spec.TLS = &routev1.TLSConfig{Termination: routev1.TLSTerminationEdge}
+	fakeClient := fake.NewFakeClient(argoCD, route)
+	config := DefaultConfig()
+	config.DetectScheme = true
+	reconcileArgoCD := newReconcilerFromConfig(fakeClient, s, config)
+
+	result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink("https://test.com", "ArgoCD"))
+
+	tests := []struct {
+		name string
+		tls  *routev1.TLSConfig
+		want string
+	}{
+		{"passthrough", &routev1.TLSConfig{Termination: routev1.TLSTerminationPassthrough}, "https://test.com"},
+		{"no TLS", nil, "http://test.com"},
+		{"reencrypt", &routev1.TLSConfig{Termination: routev1.TLSTerminationReencrypt}, "https://test.com"},
+	}
+	for _, tt := range tests {
+		assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: route.Name, Namespace: route.Namespace}, route))
+		route.Spec.TLS = tt.tls
+		assertNoError(t, fakeClient.Update(context.TODO(), route))
+
+		result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+		assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink(tt.want, "ArgoCD"))
+	}
+}
+
+func TestReconcile_deep_link(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	route := argoCDRoute.DeepCopy()
+	route.Spec.Path = "/argocd/"
+	fakeClient := fake.NewFakeClient(argoCD, route)
+	config := DefaultConfig()
+	deepLink, err := parseDeepLink("applications?proj=team a")
+	assertNoError(t, err)
+	config.DeepLink = deepLink
+	reconcileArgoCD := newReconcilerFromConfig(fakeClient, s, config)
+
+	result, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertConsoleLinkExists(t, fakeClient, reconcileResult{result, err}, newConsoleLink("https://test.com/argocd/applications?proj=team+a", "ArgoCD"))
+}
+
+func TestParseDeepLink(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"/applications", "/applications", false},
+		{"applications?proj=foo", "/applications?proj=foo", false},
+		{"/applications/my app", "/applications/my%20app", false},
+		{"/applications?proj=a&b", "/applications?b=&proj=a", false},
+		{"https://evil.example.com/applications", "", true},
+		{"//evil.example.com/applications", "", true},
+		{"/applications#tree", "", true},
+		{"/applications?proj=%zz", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseDeepLink(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDeepLink(%q) got error %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseDeepLink(%q) got %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseSection(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", defaultSection, false},
+		{"  ", defaultSection, false},
+		{" Continuous Delivery ", "Continuous Delivery", false},
+		{strings.Repeat("a", maxSectionLength), strings.Repeat("a", maxSectionLength), false},
+		{strings.Repeat("a", maxSectionLength+1), "", true},
+		{"Continuous\tDelivery", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseSection(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSection(%q) got error %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseSection(%q) got %q, want %q", tt.value, got, tt.want)
+		}
+	}
+
+	config := DefaultConfig()
+	config.Section = ""
+	link := buildConsoleLink("https://test.com", config)
+	if link.Spec.ApplicationMenu.Section != defaultSection {
+		t.Fatalf("got section %q, want the default %q", link.Spec.ApplicationMenu.Section, defaultSection)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		for _, envVar := range []string{namespaceSelectorEnvVar, instanceSelectorEnvVar, routeSelectorEnvVar, routePortEnvVar, iconPathEnvVar, iconURLEnvVar, iconTTLEnvVar, annotationsEnvVar, allNamespacesEnvVar, locationEnvVar, sectionEnvVar, dashboardNamespacesEnvVar, deepLinkEnvVar, extraLinksEnvVar, caBundleConfigMapEnvVar, cliDownloadEnvVar, resyncIntervalEnvVar, maxConcurrentReconcilesEnvVar, detectSchemeEnvVar, disableConsoleLinkEnvVar, cleanupOnShutdownEnvVar} {
+			setEnv(t, envVar, "")
+		}
+
+		config, err := ConfigFromEnv()
+		assertNoError(t, err)
+
+		want := DefaultConfig()
+		if diff := cmp.Diff(want, config); diff != "" {
+			t.Fatalf("config mismatch: %v", diff)
+		}
+	})
+	t.Run("overrides", func(t *testing.T) {
+		setEnv(t, iconURLEnvVar, "https://example.com/argo.png")
+		setEnv(t, iconTTLEnvVar, "5m")
+		setEnv(t, locationEnvVar, "HelpMenu")
+		setEnv(t, cleanupOnShutdownEnvVar, "true")
+
+		config, err := ConfigFromEnv()
+		assertNoError(t, err)
+
+		if config.IconURL != "https://example.com/argo.png" || config.IconTTL != 5*time.Minute {
+			t.Fatalf("got icon %s with TTL %v", config.IconURL, config.IconTTL)
+		}
+		if config.Location != console.HelpMenu || !config.CleanupOnShutdown {
+			t.Fatalf("got location %s and cleanup %v", config.Location, config.CleanupOnShutdown)
+		}
+	})
+	t.Run("max concurrent reconciles", func(t *testing.T) {
+		setEnv(t, maxConcurrentReconcilesEnvVar, "4")
+
+		config, err := ConfigFromEnv()
+		assertNoError(t, err)
+
+		options := newReconcilerFromConfig(fake.NewFakeClient(), scheme.Scheme, config).controllerOptions()
+		if options.MaxConcurrentReconciles != 4 {
+			t.Fatalf("got %d max concurrent reconciles, want 4", options.MaxConcurrentReconciles)
+		}
+
+		setEnv(t, maxConcurrentReconcilesEnvVar, "0")
+		if _, err := ConfigFromEnv(); err == nil {
+			t.Fatalf("was expecting an error for %s", maxConcurrentReconcilesEnvVar)
+		}
+	})
+	t.Run("invalid value", func(t *testing.T) {
+		setEnv(t, iconTTLEnvVar, "often")
+
+		if _, err := ConfigFromEnv(); err == nil {
+			t.Fatalf("was expecting an error for %s", iconTTLEnvVar)
+		}
+	})
+}
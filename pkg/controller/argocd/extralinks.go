@@ -0,0 +1,141 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/go-logr/logr"
+	console "github.com/openshift/api/console/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ExtraLink is a static link, e.g. to an internal ArgoCD runbook, created
+// as its own ConsoleLink alongside the link to the ArgoCD UI
+type ExtraLink struct {
+	Text string
+	Href string
+}
+
+// name returns the name of the ConsoleLink of the extra link, derived from
+// its text so that reordering the extra links doesn't rename them
+func (l ExtraLink) name() string {
+	slug := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '-'
+	}, strings.TrimSpace(l.Text))
+	for strings.Contains(slug, "--") {
+		slug = strings.Replace(slug, "--", "-", -1)
+	}
+	return consoleLinkName + "-" + strings.Trim(slug, "-")
+}
+
+// parseExtraLinks parses comma separated text=href pairs, returning nil if
+// value is empty
+func parseExtraLinks(value string) ([]ExtraLink, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var links []ExtraLink
+	names := map[string]bool{}
+	for _, pair := range strings.Split(value, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid %s %q: expected text=href pairs", extraLinksEnvVar, value)
+		}
+		link := ExtraLink{Text: strings.TrimSpace(kv[0]), Href: strings.TrimSpace(kv[1])}
+		if len(link.Text) > maxLinkTextLength {
+			return nil, fmt.Errorf("invalid %s text %q: must be at most %d characters", extraLinksEnvVar, link.Text, maxLinkTextLength)
+		}
+		if u, err := url.Parse(link.Href); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid %s href %q: must be an http or https URL", extraLinksEnvVar, link.Href)
+		}
+		name := link.name()
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 || name == consoleLinkName+"-" {
+			return nil, fmt.Errorf("invalid %s text %q: must contain letters or digits", extraLinksEnvVar, link.Text)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("invalid %s text %q: another link has the same name %s", extraLinksEnvVar, link.Text, name)
+		}
+		names[name] = true
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+// newExtraConsoleLink returns the ConsoleLink of the extra link, shown next
+// to the link to the ArgoCD UI. Static links aren't namespaced, they are
+// shown in the application menu when the UI link is on namespace dashboards.
+func newExtraConsoleLink(link ExtraLink, config Config) *console.ConsoleLink {
+	consoleLink := newConsoleLink(link.Href, link.Text)
+	consoleLink.Name = link.name()
+	consoleLink.Labels[extraLinkLabel] = "true"
+	if config.Location == console.HelpMenu || config.Location == console.UserMenu {
+		consoleLink.Spec.Location = config.Location
+		consoleLink.Spec.ApplicationMenu = nil
+	}
+	if consoleLink.Spec.ApplicationMenu != nil && config.Section != "" {
+		consoleLink.Spec.ApplicationMenu.Section = config.Section
+	}
+	return consoleLink
+}
+
+// syncExtraLinks creates or updates the ConsoleLinks of links and deletes
+// the extra links which are no longer configured
+func (r *consoleLinkRegistrar) syncExtraLinks(ctx context.Context, links []ExtraLink, log logr.Logger) error {
+	existing := &console.ConsoleLinkList{}
+	if err := r.client.List(ctx, existing, client.MatchingLabels{managedByLabel: managedByValue, extraLinkLabel: "true"}); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil
+		}
+		return err
+	}
+	desired := map[string]*console.ConsoleLink{}
+	for _, link := range links {
+		consoleLink := newExtraConsoleLink(link, r.config)
+		desired[consoleLink.Name] = consoleLink
+	}
+
+	for i := range existing.Items {
+		found := &existing.Items[i]
+		consoleLink, ok := desired[found.Name]
+		if !ok {
+			log.Info("Deleting extra ConsoleLink", "ConsoleLink.Name", found.Name)
+			if err := r.client.Delete(ctx, found); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			continue
+		}
+		delete(desired, found.Name)
+		if equality.Semantic.DeepEqual(found.Spec, consoleLink.Spec) {
+			continue
+		}
+		log.Info("Updating extra ConsoleLink", "ConsoleLink.Name", found.Name)
+		found.Spec = consoleLink.Spec
+		if err := r.client.Update(ctx, found); err != nil {
+			return err
+		}
+	}
+
+	for _, link := range links {
+		consoleLink, ok := desired[link.name()]
+		if !ok {
+			continue
+		}
+		log.Info("Creating extra ConsoleLink", "ConsoleLink.Name", consoleLink.Name)
+		if err := r.client.Create(ctx, consoleLink); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+	return nil
+}
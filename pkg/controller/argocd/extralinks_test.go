@@ -0,0 +1,97 @@
+package argocd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	console "github.com/openshift/api/console/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcile_extra_links(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	fakeClient := fake.NewFakeClient(argoCD, argoCDRoute)
+	config := DefaultConfig()
+	config.ExtraLinks = []ExtraLink{
+		{Text: "ArgoCD Runbook", Href: "https://wiki.test.com/argocd"},
+		{Text: "ArgoCD Docs", Href: "https://argo-cd.readthedocs.io"},
+	}
+	reconcileArgoCD := newReconcilerFromConfig(fakeClient, s, config)
+
+	_, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+
+	assertExtraLink(t, fakeClient, "argocd-argocd-runbook", console.Link{Text: "ArgoCD Runbook", Href: "https://wiki.test.com/argocd"})
+	assertExtraLink(t, fakeClient, "argocd-argocd-docs", console.Link{Text: "ArgoCD Docs", Href: "https://argo-cd.readthedocs.io"})
+	_, err = getConsoleLink(fakeClient)
+	assertNoError(t, err)
+
+	// the configuration changes, e.g. on restart
+	config.ExtraLinks = []ExtraLink{{Text: "ArgoCD Runbook", Href: "https://runbooks.test.com/argocd"}}
+	reconcileArgoCD = newReconcilerFromConfig(fakeClient, s, config)
+	_, err = reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+
+	assertExtraLink(t, fakeClient, "argocd-argocd-runbook", console.Link{Text: "ArgoCD Runbook", Href: "https://runbooks.test.com/argocd"})
+	err = fakeClient.Get(context.TODO(), types.NamespacedName{Name: "argocd-argocd-docs"}, &console.ConsoleLink{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("was expecting the extra link no longer configured to be deleted, got error %v", err)
+	}
+
+	// removed along with the ConsoleLink
+	assertNoError(t, fakeClient.Delete(context.TODO(), argoCD.DeepCopy()))
+	_, err = reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+	links := &console.ConsoleLinkList{}
+	assertNoError(t, fakeClient.List(context.TODO(), links))
+	if len(links.Items) != 0 {
+		t.Fatalf("got ConsoleLinks %v, want none", links.Items)
+	}
+}
+
+func TestParseExtraLinks(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []ExtraLink
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"Runbook=https://wiki.test.com/argocd?page=a", []ExtraLink{{Text: "Runbook", Href: "https://wiki.test.com/argocd?page=a"}}, false},
+		{" Runbook = https://wiki.test.com , Docs=http://docs.test.com", []ExtraLink{{Text: "Runbook", Href: "https://wiki.test.com"}, {Text: "Docs", Href: "http://docs.test.com"}}, false},
+		{"Runbook", nil, true},
+		{"=https://wiki.test.com", nil, true},
+		{"Runbook=wiki.test.com", nil, true},
+		{"Runbook=ftp://wiki.test.com", nil, true},
+		{"!!!=https://wiki.test.com", nil, true},
+		{"Runbook=https://a.test.com,runbook=https://b.test.com", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseExtraLinks(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseExtraLinks(%q) got error %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("parseExtraLinks(%q) mismatch: %v", tt.value, diff)
+		}
+	}
+}
+
+func assertExtraLink(t *testing.T, c client.Client, name string, want console.Link) {
+	t.Helper()
+	got := &console.ConsoleLink{}
+	assertNoError(t, c.Get(context.TODO(), types.NamespacedName{Name: name}, got))
+	if diff := cmp.Diff(want, got.Spec.Link); diff != "" {
+		t.Fatalf("extra ConsoleLink %s mismatch: %v", name, diff)
+	}
+	if !isManaged(got) || got.Labels[extraLinkLabel] != "true" {
+		t.Fatalf("got labels %v on extra ConsoleLink %s, want it managed", got.Labels, name)
+	}
+}
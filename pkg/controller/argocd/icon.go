@@ -0,0 +1,226 @@
+package argocd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/rakyll/statik/fs"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+const defaultIconTTL = time.Hour
+
+const (
+	// iconFetchTimeout bounds the time a reconcile waits for the icon server
+	iconFetchTimeout = 10 * time.Second
+
+	// maxIconSize is the largest icon read from the icon server, in bytes
+	maxIconSize = 1 << 20
+)
+
+// iconHTTPClient fetches remote icons
+var iconHTTPClient = &http.Client{Timeout: iconFetchTimeout}
+
+// embeddedIcon is the ArgoCD icon bundled with the operator
+var embeddedIcon = &statikIcon{path: iconFilePath}
+
+// bundledIcons caches the other icons bundled with the operator by path
+var (
+	bundledIconsMu sync.Mutex
+	bundledIcons   = map[string]*statikIcon{}
+)
+
+// statikIcon lazily loads an icon from the statik filesystem, only once
+type statikIcon struct {
+	path string
+
+	once    sync.Once
+	dataURL string
+	err     error
+}
+
+// imageURL returns the icon as a data URL
+func (i *statikIcon) imageURL() (string, error) {
+	i.once.Do(func() {
+		data, err := readStatikFile(i.path)
+		if err != nil {
+			i.err = err
+			return
+		}
+		i.dataURL = dataURL(mime.TypeByExtension(path.Ext(i.path)), data)
+	})
+	return i.dataURL, i.err
+}
+
+// embeddedImageURL returns the embedded icon as a data URL, or an empty
+// string if it could not be loaded so that the ConsoleLink is still created
+func embeddedImageURL() string {
+	imageURL, err := embeddedIcon.imageURL()
+	if err != nil {
+		logs.Error(err, "Failed to load embedded ArgoCD icon, ConsoleLink will have no image")
+	}
+	return imageURL
+}
+
+// bundledImageURL returns the bundled icon at name as a data URL, falling
+// back to the embedded ArgoCD icon if it could not be loaded
+func bundledImageURL(name string) string {
+	if name == "" || name == iconFilePath {
+		return embeddedImageURL()
+	}
+	bundledIconsMu.Lock()
+	icon, ok := bundledIcons[name]
+	if !ok {
+		icon = &statikIcon{path: name}
+		bundledIcons[name] = icon
+	}
+	bundledIconsMu.Unlock()
+
+	imageURL, err := icon.imageURL()
+	if err != nil {
+		logs.Error(err, "Failed to load bundled icon, using the embedded ArgoCD icon", "Path", name)
+		return embeddedImageURL()
+	}
+	return imageURL
+}
+
+// bundledIconPaths returns the paths of the icons bundled with the operator
+func bundledIconPaths() ([]string, error) {
+	statikFs, err := fs.New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a new statik filesystem: %w", err)
+	}
+	var paths []string
+	err = fs.Walk(statikFs, "/", func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			paths = append(paths, name)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+func readStatikFile(path string) ([]byte, error) {
+	statikFs, err := fs.New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a new statik filesystem: %w", err)
+	}
+	file, err := statikFs.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open icon file %s: %w", path, err)
+	}
+	defer file.Close()
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read icon file %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// remoteIcon fetches the ConsoleLink icon from a URL and caches it for ttl,
+// so that an updated icon is picked up without restarting the operator
+type remoteIcon struct {
+	url   string
+	ttl   time.Duration
+	clock clock.Clock
+	fetch func(url string) (data []byte, contentType string, err error)
+
+	mu        sync.Mutex
+	dataURL   string
+	fetchedAt time.Time
+	err       error
+	// refreshing is closed once the icon being fetched is cached, it is nil
+	// if no fetch is in flight
+	refreshing chan struct{}
+}
+
+func newRemoteIcon(url string, ttl time.Duration) *remoteIcon {
+	return &remoteIcon{
+		url:   url,
+		ttl:   ttl,
+		clock: clock.RealClock{},
+		fetch: httpGetIcon,
+	}
+}
+
+// imageURL returns the icon as a data URL, re-fetching it once the ttl has
+// expired. If the fetch fails, the previously cached icon is returned along
+// with the error. Only one fetch is in flight at a time, meanwhile the
+// previously cached icon is returned to the other callers.
+func (i *remoteIcon) imageURL() (string, error) {
+	i.mu.Lock()
+	if i.dataURL != "" && i.clock.Since(i.fetchedAt) < i.ttl {
+		defer i.mu.Unlock()
+		return i.dataURL, nil
+	}
+	if refreshing := i.refreshing; refreshing != nil {
+		cached := i.dataURL
+		i.mu.Unlock()
+		if cached != "" {
+			return cached, nil
+		}
+		// nothing to serve yet, wait for the first fetch
+		<-refreshing
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		return i.dataURL, i.err
+	}
+	refreshing := make(chan struct{})
+	i.refreshing = refreshing
+	i.mu.Unlock()
+
+	// fetched without holding the lock so that a slow icon server doesn't
+	// block the other reconciles
+	data, contentType, err := i.fetch(i.url)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.refreshing = nil
+	close(refreshing)
+	if err != nil {
+		i.err = fmt.Errorf("failed to fetch icon from %s: %w", i.url, err)
+		return i.dataURL, i.err
+	}
+	i.dataURL = dataURL(contentType, data)
+	i.fetchedAt = i.clock.Now()
+	i.err = nil
+	return i.dataURL, nil
+}
+
+func httpGetIcon(url string) ([]byte, string, error) {
+	resp, err := iconHTTPClient.Get(url)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxIconSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxIconSize {
+		return nil, "", fmt.Errorf("icon larger than %d bytes", maxIconSize)
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// dataURL encodes data as a data URL, assuming a PNG if contentType is unknown
+func dataURL(contentType string, data []byte) string {
+	if contentType == "" {
+		contentType = "image/png"
+	}
+	return fmt.Sprintf("data:%s;base64,%s", contentType, base64.StdEncoding.EncodeToString(data))
+}
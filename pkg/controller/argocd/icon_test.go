@@ -0,0 +1,256 @@
+package argocd
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRemoteIcon_refetch_after_ttl(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	fetcher := &fakeIconFetcher{data: "v1"}
+	icon := newFakeRemoteIcon(fakeClock, fetcher)
+
+	got, err := icon.imageURL()
+	assertNoError(t, err)
+	assertImageURL(t, got, dataURL("image/png", []byte("v1")))
+
+	fetcher.data = "v2"
+	fakeClock.Step(30 * time.Minute)
+	got, err = icon.imageURL()
+	assertNoError(t, err)
+	assertImageURL(t, got, dataURL("image/png", []byte("v1")))
+	assertFetches(t, fetcher, 1)
+
+	fakeClock.Step(31 * time.Minute)
+	got, err = icon.imageURL()
+	assertNoError(t, err)
+	assertImageURL(t, got, dataURL("image/png", []byte("v2")))
+	assertFetches(t, fetcher, 2)
+}
+
+func TestRemoteIcon_keeps_cached_icon_on_error(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	fetcher := &fakeIconFetcher{data: "v1"}
+	icon := newFakeRemoteIcon(fakeClock, fetcher)
+
+	_, err := icon.imageURL()
+	assertNoError(t, err)
+
+	fetcher.err = errors.New("connection refused")
+	fakeClock.Step(2 * time.Hour)
+	got, err := icon.imageURL()
+	if err == nil {
+		t.Fatal("was expecting an error, but got nil")
+	}
+	assertImageURL(t, got, dataURL("image/png", []byte("v1")))
+}
+
+func TestRemoteIcon_serves_cached_icon_while_fetching(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	fetcher := &fakeIconFetcher{data: "v1"}
+	icon := newFakeRemoteIcon(fakeClock, fetcher)
+
+	_, err := icon.imageURL()
+	assertNoError(t, err)
+
+	// the icon server hangs on the next fetch
+	started, release := make(chan struct{}), make(chan struct{})
+	icon.fetch = func(url string) ([]byte, string, error) {
+		close(started)
+		<-release
+		return []byte("v2"), "image/png", nil
+	}
+	fakeClock.Step(2 * time.Hour)
+	done := make(chan string)
+	go func() {
+		got, _ := icon.imageURL()
+		done <- got
+	}()
+	<-started
+
+	got, err := icon.imageURL()
+	assertNoError(t, err)
+	assertImageURL(t, got, dataURL("image/png", []byte("v1")))
+
+	close(release)
+	assertImageURL(t, <-done, dataURL("image/png", []byte("v2")))
+}
+
+func TestHTTPGetIcon(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		if r.URL.Path == "/large.svg" {
+			w.Write(make([]byte, maxIconSize+1))
+			return
+		}
+		w.Write([]byte("<svg/>"))
+	}))
+	defer server.Close()
+
+	data, contentType, err := httpGetIcon(server.URL + "/argo.svg")
+	assertNoError(t, err)
+	if string(data) != "<svg/>" || contentType != "image/svg+xml" {
+		t.Fatalf("got icon %q of type %s, want <svg/> of type image/svg+xml", data, contentType)
+	}
+
+	if _, _, err := httpGetIcon(server.URL + "/large.svg"); err == nil {
+		t.Fatal("was expecting an error for an icon over the size limit")
+	}
+}
+
+func TestReconcile_updates_consolelink_icon_after_ttl(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	fakeClient := fake.NewFakeClient(argoCD, argoCDRoute)
+	fakeClock := clock.NewFakeClock(time.Now())
+	fetcher := &fakeIconFetcher{data: "v1"}
+	reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+	consoleLinks(reconcileArgoCD).icon = newFakeRemoteIcon(fakeClock, fetcher)
+
+	_, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+
+	fetcher.data = "v2"
+	fakeClock.Step(2 * time.Hour)
+	_, err = reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+
+	got, err := getConsoleLink(fakeClient)
+	assertNoError(t, err)
+	assertImageURL(t, got.Spec.ApplicationMenu.ImageURL, dataURL("image/png", []byte("v2")))
+}
+
+func TestReconcile_missing_embedded_icon(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	defer func(icon *statikIcon) { embeddedIcon = icon }(embeddedIcon)
+	embeddedIcon = &statikIcon{path: "/missing.png"}
+
+	fakeClient := fake.NewFakeClient(argoCD, argoCDRoute)
+	c := &deleteRecordingClient{Client: fakeClient}
+	reconcileArgoCD := newFakeReconcileArgoCD(c, s)
+
+	_, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+
+	got, err := getConsoleLink(fakeClient)
+	assertNoError(t, err)
+	assertImageURL(t, got.Spec.ApplicationMenu.ImageURL, "")
+	if got.Spec.Href != "https://test.com" {
+		t.Fatalf("got href %s, want https://test.com", got.Spec.Href)
+	}
+
+	// the link without an image is kept rather than recreated, e.g. once the
+	// operator restarts
+	_, err = newFakeReconcileArgoCD(c, s).Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+	if len(c.deleted) != 0 {
+		t.Fatalf("got ConsoleLinks %v deleted, want none", c.deleted)
+	}
+}
+
+func TestStatikIcon(t *testing.T) {
+	icon := &statikIcon{path: iconFilePath}
+	got, err := icon.imageURL()
+	assertNoError(t, err)
+	if got == "" {
+		t.Fatal("was expecting the embedded icon to be loaded")
+	}
+}
+
+func TestParseIconPath(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", iconFilePath, false},
+		{"argo.png", iconFilePath, false},
+		{" /argo.png ", iconFilePath, false},
+		{"/argo-dark.png", iconFilePath, true},
+		{"/../argo.png", iconFilePath, false},
+	}
+	for _, tt := range tests {
+		got, err := parseIconPath(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseIconPath(%q) got error %v, want error %v", tt.value, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("parseIconPath(%q) got %q, want %q", tt.value, got, tt.want)
+		}
+	}
+
+	_, err := parseIconPath("/argo-dark.png")
+	if err == nil || !strings.Contains(err.Error(), iconFilePath) {
+		t.Fatalf("got error %v, want it to list the bundled icons", err)
+	}
+}
+
+func TestReconcile_missing_bundled_icon(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	setEnv(t, iconPathEnvVar, "/argo-dark.png")
+	config, err := ConfigFromEnv()
+	assertNoError(t, err)
+	if config.IconPath != iconFilePath {
+		t.Fatalf("got icon path %s, want the default %s", config.IconPath, iconFilePath)
+	}
+
+	// a path which is no longer bundled falls back to the embedded icon
+	config.IconPath = "/argo-dark.png"
+	fakeClient := fake.NewFakeClient(argoCD, argoCDRoute)
+	reconcileArgoCD := newReconcilerFromConfig(fakeClient, s, config)
+
+	_, err = reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+
+	got, err := getConsoleLink(fakeClient)
+	assertNoError(t, err)
+	assertImageURL(t, got.Spec.ApplicationMenu.ImageURL, embeddedImageURL())
+}
+
+type fakeIconFetcher struct {
+	data    string
+	err     error
+	fetches int
+}
+
+func (f *fakeIconFetcher) fetch(url string) ([]byte, string, error) {
+	f.fetches++
+	if f.err != nil {
+		return nil, "", f.err
+	}
+	return []byte(f.data), "image/png", nil
+}
+
+func newFakeRemoteIcon(c clock.Clock, fetcher *fakeIconFetcher) *remoteIcon {
+	icon := newRemoteIcon("https://example.com/argo.png", time.Hour)
+	icon.clock = c
+	icon.fetch = fetcher.fetch
+	return icon
+}
+
+func assertImageURL(t *testing.T, got, want string) {
+	t.Helper()
+	if got != want {
+		t.Fatalf("got image %s, want %s", got, want)
+	}
+}
+
+func assertFetches(t *testing.T, f *fakeIconFetcher, want int) {
+	t.Helper()
+	if f.fetches != want {
+		t.Fatalf("got %d fetches, want %d", f.fetches, want)
+	}
+}
@@ -0,0 +1,70 @@
+package argocd
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// linkReadySeconds measures how long an ArgoCD instance waits for its
+// ConsoleLink, from the creation of the instance to the link being created,
+// e.g. for an SLO on new instances
+var linkReadySeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "gitops_console_link_ready_seconds",
+	Help:    "Time from the creation of an ArgoCD instance to the creation of its ConsoleLink",
+	Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+}, []string{"namespace", "name"})
+
+func init() {
+	// served on the metrics endpoint of the manager
+	metrics.Registry.MustRegister(linkReadySeconds)
+}
+
+// linkReadyTracker observes the time the ConsoleLink of each ArgoCD instance
+// took to be created, once per instance. Links found already created, e.g.
+// after the operator restarts, are not observed.
+type linkReadyTracker struct {
+	histogram *prometheus.HistogramVec
+	clock     clock.Clock
+
+	mu    sync.Mutex
+	ready map[types.NamespacedName]bool
+}
+
+func newLinkReadyTracker(histogram *prometheus.HistogramVec) *linkReadyTracker {
+	return &linkReadyTracker{
+		histogram: histogram,
+		clock:     clock.RealClock{},
+		ready:     map[types.NamespacedName]bool{},
+	}
+}
+
+// linkCreated observes the time since the instance was created, unless its
+// link has already been observed as created
+func (t *linkReadyTracker) linkCreated(instance metav1.Object) {
+	created := instance.GetCreationTimestamp()
+	if created.IsZero() {
+		return
+	}
+	key := types.NamespacedName{Namespace: instance.GetNamespace(), Name: instance.GetName()}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ready[key] {
+		return
+	}
+	t.ready[key] = true
+	t.histogram.WithLabelValues(key.Namespace, key.Name).Observe(t.clock.Since(created.Time).Seconds())
+}
+
+// forget drops the instance, and its histogram, e.g. once it is deleted, so
+// that an instance recreated with the same name is measured again
+func (t *linkReadyTracker) forget(instance types.NamespacedName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.ready, instance)
+	t.histogram.DeleteLabelValues(instance.Namespace, instance.Name)
+}
@@ -0,0 +1,109 @@
+package argocd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcile_link_ready_metric(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_link_ready_seconds"}, []string{"namespace", "name"})
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(histogram)
+	fakeClock := clock.NewFakeClock(time.Unix(1600000000, 0))
+
+	// the instance was created before the operator first saw it
+	instance := argoCD.DeepCopy()
+	instance.CreationTimestamp = metav1.NewTime(fakeClock.Now().Add(-10 * time.Second))
+	fakeClient := fake.NewFakeClient(instance)
+	reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+	reconcileArgoCD.linkReady = newLinkReadyTracker(histogram)
+	reconcileArgoCD.linkReady.clock = fakeClock
+
+	// the route is not provisioned yet
+	_, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+	assertLinkReadyObservations(t, registry, 0, 0)
+
+	fakeClock.Step(30 * time.Second)
+	assertNoError(t, fakeClient.Create(context.TODO(), argoCDRoute.DeepCopy()))
+	_, err = reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+	assertLinkReadyObservations(t, registry, 1, 40)
+
+	// later reconciles of the same instance are not observed
+	fakeClock.Step(time.Minute)
+	_, err = reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+	assertLinkReadyObservations(t, registry, 1, 40)
+
+	// the series is dropped once the instance is deleted
+	assertNoError(t, fakeClient.Delete(context.TODO(), instance))
+	_, err = reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+	assertLinkReadySeries(t, registry, 0)
+}
+
+func TestReconcile_link_ready_metric_existing_link(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_link_ready_seconds"}, []string{"namespace", "name"})
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(histogram)
+	fakeClock := clock.NewFakeClock(time.Unix(1600000000, 0))
+
+	instance := argoCD.DeepCopy()
+	instance.CreationTimestamp = metav1.NewTime(fakeClock.Now().Add(-time.Hour))
+	fakeClient := fake.NewFakeClient(instance, argoCDRoute.DeepCopy())
+	reconcileArgoCD := newFakeReconcileArgoCD(fakeClient, s)
+	_, err := reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+
+	// the operator restarts and finds the link already created
+	reconcileArgoCD = newFakeReconcileArgoCD(fakeClient, s)
+	reconcileArgoCD.linkReady = newLinkReadyTracker(histogram)
+	reconcileArgoCD.linkReady.clock = fakeClock
+	_, err = reconcileArgoCD.Reconcile(newRequest(argocdNS, argocdInstanceName))
+	assertNoError(t, err)
+	assertLinkReadyObservations(t, registry, 0, 0)
+}
+
+func assertLinkReadySeries(t *testing.T, registry *prometheus.Registry, want int) {
+	t.Helper()
+	families, err := registry.Gather()
+	assertNoError(t, err)
+	got := 0
+	for _, family := range families {
+		got += len(family.GetMetric())
+	}
+	if got != want {
+		t.Fatalf("got %d link ready series, want %d", got, want)
+	}
+}
+
+func assertLinkReadyObservations(t *testing.T, registry *prometheus.Registry, count uint64, sum float64) {
+	t.Helper()
+	families, err := registry.Gather()
+	assertNoError(t, err)
+	var gotCount uint64
+	var gotSum float64
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			gotCount += metric.GetHistogram().GetSampleCount()
+			gotSum += metric.GetHistogram().GetSampleSum()
+		}
+	}
+	if gotCount != count || gotSum != sum {
+		t.Fatalf("got %d observations summing to %vs, want %d summing to %vs", gotCount, gotSum, count, sum)
+	}
+}
@@ -0,0 +1,549 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	console "github.com/openshift/api/console/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// LinkRegistrar registers the link to the ArgoCD UI with a dashboard, the
+// OpenShift console by default
+type LinkRegistrar interface {
+	// Register creates the link to href, or updates it if it changed
+	Register(ctx context.Context, href string, log logr.Logger) error
+	// Unregister removes the link if it is present
+	Unregister(ctx context.Context, log logr.Logger) error
+}
+
+// blank assignment to verify that consoleLinkRegistrar implements LinkRegistrar
+var _ LinkRegistrar = &consoleLinkRegistrar{}
+
+// consoleLinkRegistrar registers the link as an OpenShift ConsoleLink
+type consoleLinkRegistrar struct {
+	client client.Client
+	config Config
+
+	// icon, if set, replaces the embedded icon with one fetched remotely
+	icon *remoteIcon
+
+	// orphansDeleted is set once ConsoleLinks left over by previous
+	// operator versions have been deleted
+	orphansDeleted bool
+
+	// lastApplied is the ConsoleLink applied by the last reconcile, used to
+	// skip reconciles which cannot change it
+	lastApplied *console.ConsoleLink
+	// lastAppliedAt is when lastApplied was last checked against the cluster
+	lastAppliedAt time.Time
+
+	// forbidden is set once access to ConsoleLinks has been denied, e.g.
+	// when the operator runs with namespace-scoped RBAC. ConsoleLink
+	// management is then off until the operator restarts.
+	forbidden bool
+}
+
+func newConsoleLinkRegistrar(c client.Client, config Config) *consoleLinkRegistrar {
+	r := &consoleLinkRegistrar{
+		client: c,
+		config: config,
+	}
+	if config.IconURL != "" {
+		r.icon = newRemoteIcon(config.IconURL, config.IconTTL)
+	}
+	return r
+}
+
+// newInstanceRegistrar returns the registrar of the ConsoleLink of a single
+// ArgoCD instance when all namespaces are linked. The links of the other
+// instances are not orphans, so none are deleted.
+func newInstanceRegistrar(c client.Client, config Config, icon *remoteIcon) *consoleLinkRegistrar {
+	return &consoleLinkRegistrar{
+		client:         c,
+		config:         config,
+		icon:           icon,
+		orphansDeleted: true,
+	}
+}
+
+// BuildConsoleLink returns the ConsoleLink the operator creates for a route
+// with host, which may include a path, e.g. to check the link before
+// deploying. The embedded icon is used even if an icon URL is configured. An
+// *InvalidConsoleLinkError is returned if the link would not be valid.
+func BuildConsoleLink(host string, config Config) (*console.ConsoleLink, error) {
+	if strings.TrimSpace(host) == "" {
+		return nil, &InvalidConsoleLinkError{Field: "host", Value: host, Reason: "must not be empty"}
+	}
+	route := &routev1.Route{}
+	route.Spec.Host = host
+	if i := strings.Index(host, "/"); i >= 0 {
+		route.Spec.Host, route.Spec.Path = host[:i], host[i:]
+	}
+	scheme := config.URLScheme
+	if scheme == "" {
+		scheme = "https"
+	}
+	consoleLink := buildConsoleLink(routeURL(scheme, route), config)
+	if err := validateConsoleLink(consoleLink); err != nil {
+		return nil, err
+	}
+	consoleLink.TypeMeta = metav1.TypeMeta{APIVersion: console.GroupVersion.String(), Kind: "ConsoleLink"}
+	return consoleLink, nil
+}
+
+// InvalidConsoleLinkError is returned when a field of the ConsoleLink built
+// for the ArgoCD UI is not valid, e.g. because of a misconfiguration
+type InvalidConsoleLinkError struct {
+	Field  string
+	Value  string
+	Reason string
+}
+
+func (e *InvalidConsoleLinkError) Error() string {
+	return fmt.Sprintf("invalid ConsoleLink %s %q: %s", e.Field, e.Value, e.Reason)
+}
+
+// validateConsoleLink checks the fields of the ConsoleLink the console relies
+// on to show it
+func validateConsoleLink(link *console.ConsoleLink) error {
+	href := link.Spec.Href
+	u, err := url.Parse(href)
+	switch {
+	case err != nil:
+		return &InvalidConsoleLinkError{Field: "href", Value: href, Reason: err.Error()}
+	case u.Scheme != "http" && u.Scheme != "https":
+		return &InvalidConsoleLinkError{Field: "href", Value: href, Reason: "scheme must be http or https"}
+	case u.Host == "":
+		return &InvalidConsoleLinkError{Field: "href", Value: href, Reason: "missing host"}
+	}
+	text := link.Spec.Text
+	switch {
+	case strings.TrimSpace(text) == "":
+		return &InvalidConsoleLinkError{Field: "text", Value: text, Reason: "must not be empty"}
+	case len(text) > maxLinkTextLength:
+		return &InvalidConsoleLinkError{Field: "text", Value: text, Reason: fmt.Sprintf("must be at most %d characters", maxLinkTextLength)}
+	}
+	if menu := link.Spec.ApplicationMenu; menu != nil {
+		switch {
+		case strings.TrimSpace(menu.Section) == "":
+			return &InvalidConsoleLinkError{Field: "section", Value: menu.Section, Reason: "must not be empty"}
+		case len(menu.Section) > maxSectionLength:
+			return &InvalidConsoleLinkError{Field: "section", Value: menu.Section, Reason: fmt.Sprintf("must be at most %d characters", maxSectionLength)}
+		}
+	}
+	return nil
+}
+
+// buildConsoleLink returns the ConsoleLink to href described by config
+func buildConsoleLink(href string, config Config) *console.ConsoleLink {
+	consoleLink := newConsoleLink(withDeepLink(href, config.DeepLink), config.LinkText)
+	consoleLink.Name = config.ConsoleLinkName
+	consoleLink.Annotations = map[string]string{}
+	for k, v := range config.Annotations {
+		consoleLink.Annotations[k] = v
+	}
+	if config.Namespace != "" {
+		consoleLink.Annotations[argocdNamespaceAnnotation] = config.Namespace
+	}
+	if config.CABundleConfigMap != "" {
+		consoleLink.Annotations[caBundleAnnotation] = config.Namespace + "/" + config.CABundleConfigMap
+	}
+	if config.Location != "" && config.Location != console.ApplicationMenu {
+		consoleLink.Spec.Location = config.Location
+		consoleLink.Spec.ApplicationMenu = nil
+	}
+	if consoleLink.Spec.ApplicationMenu != nil && config.Section != "" {
+		consoleLink.Spec.ApplicationMenu.Section = config.Section
+	}
+	if consoleLink.Spec.ApplicationMenu != nil && config.IconPath != "" && config.IconPath != iconFilePath {
+		consoleLink.Spec.ApplicationMenu.ImageURL = bundledImageURL(config.IconPath)
+	}
+	return consoleLink
+}
+
+// withDeepLink appends the deep link, if any, to the URL of the ArgoCD UI
+func withDeepLink(href, deepLink string) string {
+	if deepLink == "" {
+		return href
+	}
+	return strings.TrimSuffix(href, "/") + deepLink
+}
+
+// Register creates the ConsoleLink pointing to href, or updates it if it changed
+func (r *consoleLinkRegistrar) Register(ctx context.Context, href string, reqLogger logr.Logger) error {
+	if r.forbidden {
+		return nil
+	}
+	return r.checkForbidden(r.register(ctx, href, reqLogger), reqLogger)
+}
+
+func (r *consoleLinkRegistrar) register(ctx context.Context, href string, reqLogger logr.Logger) error {
+	if err := r.deleteOrphansOnce(ctx, reqLogger); err != nil {
+		return err
+	}
+
+	consoleLink := buildConsoleLink(href, r.config)
+	if err := validateConsoleLink(consoleLink); err != nil {
+		return err
+	}
+	if r.icon != nil && consoleLink.Spec.ApplicationMenu != nil {
+		imageURL, err := r.icon.imageURL()
+		if err != nil {
+			reqLogger.Error(err, "Failed to fetch ConsoleLink icon")
+		}
+		if imageURL != "" {
+			consoleLink.Spec.ApplicationMenu.ImageURL = imageURL
+		}
+	}
+
+	found := &console.ConsoleLink{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: consoleLink.Name}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		reqLogger.Error(err, "Failed to get ConsoleLink", "ConsoleLink.Name", consoleLink.Name)
+		return err
+	}
+
+	// Skip reconciles which cannot change the ConsoleLink, e.g. route updates
+	// which don't change its host, unless the link was deleted or edited
+	// since it was applied or a periodic resync is due
+	if err == nil && r.lastApplied != nil && sameConsoleLink(r.lastApplied, consoleLink) && !drifted(found, r.lastApplied) && !r.resyncDue() {
+		reqLogger.V(traceLevel).Info("Skip reconcile: ConsoleLink unchanged since last reconcile", "ConsoleLink.Name", consoleLink.Name)
+		return nil
+	}
+
+	if err := r.ensureCABundle(ctx, reqLogger); err != nil {
+		return err
+	}
+
+	if errors.IsNotFound(err) {
+		reqLogger.V(debugLevel).Info("Creating a new ConsoleLink", "ConsoleLink.Name", consoleLink.Name)
+		err = r.client.Create(ctx, consoleLink)
+		if err != nil {
+			return err
+		}
+		recordAction(ctx, linkCreated)
+		return r.applied(ctx, consoleLink, href, reqLogger)
+	}
+
+	// a link with the same name created by a user or another operator is
+	// left alone, unless it already links to ArgoCD, e.g. when created by an
+	// operator version which didn't label its links
+	if !isManaged(found) && found.Spec.Href != consoleLink.Spec.Href {
+		return &consoleLinkCollisionError{name: found.Name, href: found.Spec.Href}
+	}
+
+	if reason := r.recreateReason(found); reason != "" {
+		// recreate it rather than merging the desired state into it, which
+		// would keep e.g. annotations referring to the previous namespace
+		reqLogger.V(debugLevel).Info("Recreating ConsoleLink", "ConsoleLink.Name", consoleLink.Name, "Reason", reason)
+		if err := r.client.Delete(ctx, found); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		if err := r.client.Create(ctx, consoleLink); err != nil {
+			return err
+		}
+		recordAction(ctx, linkUpdated)
+		return r.applied(ctx, consoleLink, href, reqLogger)
+	}
+
+	if staleIcon(found, consoleLink) {
+		// the icon embedded in the operator changes across versions, links
+		// created by a previous version are updated with the current one
+		reqLogger.Info("ConsoleLink icon changed", "ConsoleLink.Name", consoleLink.Name)
+	}
+
+	if !equality.Semantic.DeepEqual(found.Spec, consoleLink.Spec) || found.Labels[managedByLabel] != managedByValue || !hasAnnotations(found, consoleLink.Annotations) {
+		reqLogger.V(debugLevel).Info("Updating ConsoleLink", "ConsoleLink.Name", consoleLink.Name)
+		// the console may edit the link concurrently, on conflict the latest
+		// version is fetched and the desired state applied to it again
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			if err := r.client.Get(ctx, types.NamespacedName{Name: consoleLink.Name}, found); err != nil {
+				return err
+			}
+			mergeConsoleLink(found, consoleLink)
+			return r.client.Update(ctx, found)
+		})
+		if err != nil {
+			return err
+		}
+		recordAction(ctx, linkUpdated)
+		return r.applied(ctx, consoleLink, href, reqLogger)
+	}
+
+	reqLogger.V(debugLevel).Info("Skip reconcile: ConsoleLink already exists", "ConsoleLink.Name", consoleLink.Name)
+	return r.applied(ctx, consoleLink, href, reqLogger)
+}
+
+// consoleLinkCollisionError is returned by Register when a ConsoleLink the
+// operator doesn't manage already has the name of its link
+type consoleLinkCollisionError struct {
+	name string
+	href string
+}
+
+func (e *consoleLinkCollisionError) Error() string {
+	return fmt.Sprintf("ConsoleLink %s linking to %s is not managed by %s, delete it or set %s=%s on it to let the operator manage it",
+		e.name, e.href, managedByValue, managedByLabel, managedByValue)
+}
+
+// isManaged reports whether the ConsoleLink was created by this operator
+func isManaged(link *console.ConsoleLink) bool {
+	return link.Labels[managedByLabel] == managedByValue
+}
+
+// recreateReason returns why the existing ConsoleLink must be replaced rather
+// than updated, either because it is malformed, e.g. created by a previous
+// buggy version, or because it links to ArgoCD in its previous namespace. An
+// empty string is returned if it can be updated.
+func (r *consoleLinkRegistrar) recreateReason(found *console.ConsoleLink) string {
+	if problem := malformedConsoleLink(found); problem != "" {
+		return "malformed: " + problem
+	}
+	previous := found.Annotations[argocdNamespaceAnnotation]
+	if isManaged(found) && previous != "" && previous != r.config.Namespace {
+		return "ArgoCD moved from namespace " + previous
+	}
+	return ""
+}
+
+// drifted reports whether the existing ConsoleLink no longer matches the one
+// last applied, e.g. when it was edited by hand
+func drifted(found, applied *console.ConsoleLink) bool {
+	return !equality.Semantic.DeepEqual(found.Spec, applied.Spec) || !isManaged(found) || !hasAnnotations(found, applied.Annotations)
+}
+
+// staleIcon reports whether the icon of the existing ConsoleLink differs
+// from the desired one. If the desired icon could not be loaded, the existing
+// one is kept rather than removed.
+func staleIcon(found, desired *console.ConsoleLink) bool {
+	if found.Spec.ApplicationMenu == nil || desired.Spec.ApplicationMenu == nil {
+		return false
+	}
+	if desired.Spec.ApplicationMenu.ImageURL == "" {
+		desired.Spec.ApplicationMenu.ImageURL = found.Spec.ApplicationMenu.ImageURL
+		return false
+	}
+	return found.Spec.ApplicationMenu.ImageURL != desired.Spec.ApplicationMenu.ImageURL
+}
+
+// malformedConsoleLink returns what is wrong with a ConsoleLink the console
+// cannot show properly, or an empty string if it is well-formed. Links
+// without an image are valid, they are created if the icon fails to load.
+func malformedConsoleLink(link *console.ConsoleLink) string {
+	switch {
+	case link.Spec.Href == "":
+		return "empty href"
+	case link.Spec.Location == "":
+		return "no location"
+	case link.Spec.Location == console.ApplicationMenu && link.Spec.ApplicationMenu == nil:
+		return "no application menu"
+	}
+	return ""
+}
+
+// ensureCABundle creates the ConfigMap holding the CA bundle trusted for the
+// ArgoCD route if one is configured and it doesn't exist yet. OpenShift
+// injects the cluster trusted CA bundle into it, existing ConfigMaps, e.g.
+// holding an internal CA, are left untouched.
+func (r *consoleLinkRegistrar) ensureCABundle(ctx context.Context, log logr.Logger) error {
+	if r.config.CABundleConfigMap == "" {
+		return nil
+	}
+	key := types.NamespacedName{Name: r.config.CABundleConfigMap, Namespace: r.config.Namespace}
+	err := r.client.Get(ctx, key, &corev1.ConfigMap{})
+	if err == nil || !errors.IsNotFound(err) {
+		return err
+	}
+	log.Info("Creating CA bundle ConfigMap", "ConfigMap.Namespace", key.Namespace, "ConfigMap.Name", key.Name)
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      key.Name,
+			Namespace: key.Namespace,
+			Labels: map[string]string{
+				managedByLabel:             managedByValue,
+				injectTrustedCABundleLabel: "true",
+			},
+		},
+	}
+	if err := r.client.Create(ctx, configMap); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// mergeConsoleLink applies the spec, label and annotations of the desired
+// ConsoleLink to found, keeping the annotations set by others
+func mergeConsoleLink(found, desired *console.ConsoleLink) {
+	found.Spec = desired.Spec
+	if found.Labels == nil {
+		found.Labels = map[string]string{}
+	}
+	found.Labels[managedByLabel] = managedByValue
+	if len(desired.Annotations) > 0 && found.Annotations == nil {
+		found.Annotations = map[string]string{}
+	}
+	for k, v := range desired.Annotations {
+		found.Annotations[k] = v
+	}
+}
+
+// Unregister deletes the ConsoleLink if it is present
+func (r *consoleLinkRegistrar) Unregister(ctx context.Context, log logr.Logger) error {
+	if r.forbidden {
+		return nil
+	}
+	return r.checkForbidden(r.unregister(ctx, log), log)
+}
+
+func (r *consoleLinkRegistrar) unregister(ctx context.Context, log logr.Logger) error {
+	if err := r.deleteOrphansOnce(ctx, log); err != nil {
+		return err
+	}
+	r.lastApplied = nil
+	if r.config.CLIDownload {
+		if err := r.deleteCLIDownload(ctx, log); err != nil {
+			return err
+		}
+	}
+	// the extra links are shared by all the instances when all namespaces
+	// are linked, they are only removed with the link of the single instance
+	if !r.config.AllNamespaces {
+		if err := r.syncExtraLinks(ctx, nil, log); err != nil {
+			return err
+		}
+	}
+	found := &console.ConsoleLink{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: r.config.ConsoleLinkName}, found)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !isManaged(found) {
+		log.Info("Skip deleting ConsoleLink not managed by the operator", "ConsoleLink.Name", r.config.ConsoleLinkName)
+		return nil
+	}
+	log.V(debugLevel).Info("Deleting ConsoleLink", "ConsoleLink.Name", r.config.ConsoleLinkName)
+	if err := r.client.Delete(ctx, &console.ConsoleLink{ObjectMeta: metav1.ObjectMeta{Name: r.config.ConsoleLinkName}}); err != nil {
+		return err
+	}
+	recordAction(ctx, linkDeleted)
+	return nil
+}
+
+// checkForbidden turns ConsoleLink management off if err denies access to
+// ConsoleLinks, so that the controller degrades gracefully instead of
+// failing every reconcile when it isn't allowed to manage cluster-scoped
+// resources
+func (r *consoleLinkRegistrar) checkForbidden(err error, log logr.Logger) error {
+	if !isConsoleLinkForbidden(err) {
+		return err
+	}
+	log.Info("Access to ConsoleLinks is forbidden, ConsoleLink management is off", "Error", err.Error())
+	r.forbidden = true
+	r.lastApplied = nil
+	return nil
+}
+
+// isConsoleLinkForbidden reports whether err denies access to ConsoleLinks,
+// rather than e.g. to the CA bundle ConfigMap
+func isConsoleLinkForbidden(err error) bool {
+	if !errors.IsForbidden(err) {
+		return false
+	}
+	status, ok := err.(errors.APIStatus)
+	if !ok || status.Status().Details == nil {
+		return false
+	}
+	details := status.Status().Details
+	return details.Group == console.GroupName && details.Kind == consoleLinkResource
+}
+
+// applied completes the ConsoleLink to href once its spec is applied, setting
+// the namespaces of NamespaceDashboard links and creating the CLI download if
+// enabled, and records it as last applied
+func (r *consoleLinkRegistrar) applied(ctx context.Context, link *console.ConsoleLink, href string, log logr.Logger) error {
+	if link.Spec.Location == namespaceDashboard {
+		namespaces := r.config.DashboardNamespaces
+		if len(namespaces) == 0 {
+			namespaces = []string{r.config.Namespace}
+		}
+		patch, err := namespaceDashboardPatch(namespaces)
+		if err != nil {
+			return err
+		}
+		if err := r.client.Patch(ctx, link, patch); err != nil {
+			return err
+		}
+	}
+	if r.config.CLIDownload {
+		if err := r.ensureCLIDownload(ctx, href, log); err != nil {
+			return err
+		}
+	}
+	if err := r.syncExtraLinks(ctx, r.config.ExtraLinks, log); err != nil {
+		return err
+	}
+	r.setLastApplied(link)
+	return nil
+}
+
+func (r *consoleLinkRegistrar) setLastApplied(link *console.ConsoleLink) {
+	r.lastApplied = link.DeepCopy()
+	r.lastAppliedAt = time.Now()
+}
+
+// resyncDue reports whether the ConsoleLink should be checked against the
+// cluster again to correct drift which didn't trigger an event
+func (r *consoleLinkRegistrar) resyncDue() bool {
+	return r.config.ResyncInterval > 0 && time.Since(r.lastAppliedAt) >= r.config.ResyncInterval
+}
+
+func (r *consoleLinkRegistrar) deleteOrphansOnce(ctx context.Context, log logr.Logger) error {
+	if r.orphansDeleted {
+		return nil
+	}
+	if err := r.deleteOrphanedConsoleLinks(ctx, log); err != nil {
+		return err
+	}
+	r.orphansDeleted = true
+	return nil
+}
+
+// deleteOrphanedConsoleLinks deletes the ConsoleLinks managed by the operator
+// which no longer correspond to an ArgoCD instance, e.g. because the naming
+// scheme changed in a previous operator version
+func (r *consoleLinkRegistrar) deleteOrphanedConsoleLinks(ctx context.Context, log logr.Logger) error {
+	links := &console.ConsoleLinkList{}
+	if err := r.client.List(ctx, links, client.MatchingLabels{managedByLabel: managedByValue}); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil
+		}
+		return err
+	}
+	for i := range links.Items {
+		link := &links.Items[i]
+		if link.Name == r.config.ConsoleLinkName || link.Labels[extraLinkLabel] == "true" {
+			continue
+		}
+		log.Info("Deleting orphaned ConsoleLink", "ConsoleLink.Name", link.Name)
+		if err := r.client.Delete(ctx, link); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
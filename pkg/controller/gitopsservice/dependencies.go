@@ -0,0 +1,104 @@
+package gitopsservice
+
+import (
+	"context"
+	goerrors "errors"
+
+	pipelinesv1alpha1 "github.com/redhat-developer/gitops-operator/pkg/apis/pipelines/v1alpha1"
+	"github.com/redhat-developer/gitops-operator/pkg/dependency"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// reconcileDependencies installs the operators the GitOps service depends on,
+// or uninstalls them once the GitopsService is deleted. If done is true,
+// Reconcile returns result and err without creating the GitOps service.
+func (r *ReconcileGitopsService) reconcileDependencies(instance *pipelinesv1alpha1.GitopsService) (result reconcile.Result, done bool, err error) {
+	reqLogger := log.WithValues("Request.Namespace", instance.Namespace, "Request.Name", instance.Name)
+
+	if !instance.GetDeletionTimestamp().IsZero() {
+		return reconcile.Result{}, true, r.finalize(instance)
+	}
+
+	if !hasFinalizer(instance, dependencyFinalizer) {
+		reqLogger.Info("Adding finalizer", "Finalizer", dependencyFinalizer)
+		controllerutil.AddFinalizer(instance, dependencyFinalizer)
+		if err := r.client.Update(context.TODO(), instance); err != nil {
+			return reconcile.Result{}, true, err
+		}
+	}
+
+	ctx, cancel := r.installContext()
+	defer cancel()
+	if err := r.installer.InstallContext(ctx); err != nil {
+		if goerrors.Is(err, context.Canceled) {
+			reqLogger.Info("Install aborted, the operator is shutting down")
+			return reconcile.Result{}, true, nil
+		}
+		if dependency.IsTimeout(err) {
+			// operators are still installing, check again later rather than hot-looping
+			reqLogger.Info("Dependencies not ready yet", "RequeueAfter", dependencyRequeueDelay)
+			return reconcile.Result{RequeueAfter: dependencyRequeueDelay}, true, nil
+		}
+		reqLogger.Error(err, "Failed to install dependencies")
+		return reconcile.Result{}, true, err
+	}
+	return reconcile.Result{}, false, nil
+}
+
+// InjectStopChannel is called by the manager with the channel closed when
+// the operator shuts down, so that in-flight installs can be aborted
+func (r *ReconcileGitopsService) InjectStopChannel(stop <-chan struct{}) error {
+	r.stop = stop
+	return nil
+}
+
+// installContext returns a context cancelled when the operator shuts down,
+// the returned function must be called to release it
+func (r *ReconcileGitopsService) installContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if r.stop != nil {
+		go func() {
+			select {
+			case <-r.stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	return ctx, cancel
+}
+
+// finalize uninstalls the dependent operators and then removes the finalizer.
+// Deletion reconciles which find the finalizer already removed are no-ops.
+func (r *ReconcileGitopsService) finalize(instance *pipelinesv1alpha1.GitopsService) error {
+	if !hasFinalizer(instance, dependencyFinalizer) {
+		return nil
+	}
+	reqLogger := log.WithValues("Request.Namespace", instance.Namespace, "Request.Name", instance.Name)
+
+	reqLogger.Info("Uninstalling dependencies")
+	if err := r.installer.Uninstall(); err != nil {
+		reqLogger.Error(err, "Failed to uninstall dependencies")
+		return err
+	}
+
+	reqLogger.Info("Removing finalizer", "Finalizer", dependencyFinalizer)
+	controllerutil.RemoveFinalizer(instance, dependencyFinalizer)
+	err := r.client.Update(context.TODO(), instance)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func hasFinalizer(obj metav1.Object, finalizer string) bool {
+	for _, f := range obj.GetFinalizers() {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package gitopsservice
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	olmv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	pipelinesv1alpha1 "github.com/redhat-developer/gitops-operator/pkg/apis/pipelines/v1alpha1"
+	"github.com/redhat-developer/gitops-operator/pkg/dependency"
+	"github.com/redhat-developer/gitops-operator/pkg/dependency/fake"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcile_installs_dependencies(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	fakeClient := fakeclient.NewFakeClient(newGitopsService(name))
+	installer := fake.NewInstaller()
+	reconciler := newFakeReconcileGitopsService(fakeClient, s, installer)
+
+	_, err := reconciler.Reconcile(newRequest(namespace, name))
+	assertNoError(t, err)
+
+	if calls := installer.InstallCalls(); calls != 1 {
+		t.Fatalf("got %d Install calls, want 1", calls)
+	}
+	deployment := &appsv1.Deployment{}
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, deployment))
+}
+
+func TestReconcile_dependency_install_failure(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	fakeClient := fakeclient.NewFakeClient(newGitopsService(name))
+	installer := fake.NewInstaller()
+	installer.InstallErr = errors.New("catalog source not found")
+	reconciler := newFakeReconcileGitopsService(fakeClient, s, installer)
+
+	_, err := reconciler.Reconcile(newRequest(namespace, name))
+	if err != installer.InstallErr {
+		t.Fatalf("got %v, want %v", err, installer.InstallErr)
+	}
+
+	deployment := &appsv1.Deployment{}
+	err = fakeClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, deployment)
+	if err == nil {
+		t.Fatal("was expecting the deployment not to be created")
+	}
+}
+
+func TestReconcile_dependencies_not_ready(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	fakeClient := fakeclient.NewFakeClient(newGitopsService(name))
+	installer := fake.NewInstaller()
+	installer.InstallErr = &dependency.TimeoutError{CSV: "argocd-operator.v0.0.13", Namespace: "argocd", Phase: olmv1alpha1.CSVPhasePending}
+	reconciler := newFakeReconcileGitopsService(fakeClient, s, installer)
+
+	result, err := reconciler.Reconcile(newRequest(namespace, name))
+	assertNoError(t, err)
+
+	if result.RequeueAfter != dependencyRequeueDelay {
+		t.Fatalf("got RequeueAfter %v, want %v", result.RequeueAfter, dependencyRequeueDelay)
+	}
+}
+
+func TestReconcile_adds_finalizer(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	fakeClient := fakeclient.NewFakeClient(newGitopsService(name))
+	reconciler := newFakeReconcileGitopsService(fakeClient, s, fake.NewInstaller())
+
+	_, err := reconciler.Reconcile(newRequest(namespace, name))
+	assertNoError(t, err)
+
+	assertFinalizers(t, fakeClient, []string{dependencyFinalizer})
+}
+
+func TestReconcile_deletion(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	t.Run("uninstalls dependencies then removes the finalizer", func(t *testing.T) {
+		fakeClient := fakeclient.NewFakeClient(newDeletedGitopsService(dependencyFinalizer))
+		installer := fake.NewInstaller()
+		reconciler := newFakeReconcileGitopsService(fakeClient, s, installer)
+
+		_, err := reconciler.Reconcile(newRequest(namespace, name))
+		assertNoError(t, err)
+
+		if calls := installer.UninstallCalls(); calls != 1 {
+			t.Fatalf("got %d Uninstall calls, want 1", calls)
+		}
+		if calls := installer.InstallCalls(); calls != 0 {
+			t.Fatalf("got %d Install calls, want 0", calls)
+		}
+		assertFinalizers(t, fakeClient, nil)
+
+		// the reconcile triggered by removing the finalizer is a no-op
+		_, err = reconciler.Reconcile(newRequest(namespace, name))
+		assertNoError(t, err)
+		if calls := installer.UninstallCalls(); calls != 1 {
+			t.Fatalf("got %d Uninstall calls, want 1", calls)
+		}
+	})
+	t.Run("keeps the finalizer if uninstall fails", func(t *testing.T) {
+		fakeClient := fakeclient.NewFakeClient(newDeletedGitopsService(dependencyFinalizer))
+		installer := fake.NewInstaller()
+		installer.UninstallErr = errors.New("subscription not deleted")
+		reconciler := newFakeReconcileGitopsService(fakeClient, s, installer)
+
+		_, err := reconciler.Reconcile(newRequest(namespace, name))
+		if err != installer.UninstallErr {
+			t.Fatalf("got %v, want %v", err, installer.UninstallErr)
+		}
+		assertFinalizers(t, fakeClient, []string{dependencyFinalizer})
+	})
+}
+
+func TestReconcileDependencies(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	tests := []struct {
+		name       string
+		installErr error
+		want       reconcile.Result
+		wantDone   bool
+		wantErr    bool
+	}{
+		{"installed", nil, reconcile.Result{}, false, false},
+		{"install failure", errors.New("catalog source not found"), reconcile.Result{}, true, true},
+		{"not ready", &dependency.TimeoutError{CSV: "argocd-operator.v0.0.13", Namespace: "argocd"}, reconcile.Result{RequeueAfter: dependencyRequeueDelay}, true, false},
+		{"shutting down", context.Canceled, reconcile.Result{}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newGitopsService(name)
+			fakeClient := fakeclient.NewFakeClient(instance)
+			installer := fake.NewInstaller()
+			installer.InstallErr = tt.installErr
+			reconciler := newFakeReconcileGitopsService(fakeClient, s, installer)
+
+			result, done, err := reconciler.reconcileDependencies(instance)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if done != tt.wantDone {
+				t.Fatalf("got done %v, want %v", done, tt.wantDone)
+			}
+			if result != tt.want {
+				t.Fatalf("got result %v, want %v", result, tt.want)
+			}
+		})
+	}
+}
+
+func newDeletedGitopsService(finalizers ...string) *pipelinesv1alpha1.GitopsService {
+	instance := newGitopsService(name)
+	now := metav1.Now()
+	instance.DeletionTimestamp = &now
+	instance.Finalizers = finalizers
+	return instance
+}
+
+func assertFinalizers(t *testing.T, c client.Client, want []string) {
+	t.Helper()
+	instance := &pipelinesv1alpha1.GitopsService{}
+	assertNoError(t, c.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, instance))
+	if diff := cmp.Diff(want, instance.Finalizers); diff != "" {
+		t.Fatalf("finalizers mismatch: %v", diff)
+	}
+}
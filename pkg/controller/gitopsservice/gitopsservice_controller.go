@@ -2,12 +2,19 @@ package gitopsservice
 
 import (
 	"context"
+	goerrors "errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 
 	routev1 "github.com/openshift/api/route/v1"
 
+	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 	pipelinesv1alpha1 "github.com/redhat-developer/gitops-operator/pkg/apis/pipelines/v1alpha1"
+	"github.com/redhat-developer/gitops-operator/pkg/dependency"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -36,17 +43,109 @@ var (
 	name                       = "cluster"
 	insecureEnvVar             = "INSECURE"
 	insecureEnvVarValue        = "true"
+
+	// dependencyRequeueDelay is how long to wait before retrying an install
+	// whose operators did not become ready in time
+	dependencyRequeueDelay = 30 * time.Second
+
+	// dependencyFinalizer makes sure the dependent operators are uninstalled
+	// before the GitopsService is removed
+	dependencyFinalizer = "gitops.openshift.io/dependencies"
+
+	// operatorUnhealthyReason and operatorRecoveredReason are the reasons of
+	// the events recorded when an installed operator becomes unhealthy or recovers
+	operatorUnhealthyReason = "OperatorUnhealthy"
+	operatorRecoveredReason = "OperatorRecovered"
+
+	// debugLevel is the verbosity of detailed log messages, enabled with --zap-level=1
+	debugLevel = 1
 )
 
 // Add creates a new GitopsService Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func Add(mgr manager.Manager) error {
-	return add(mgr, newReconciler(mgr))
+	r, err := newReconciler(mgr)
+	if err != nil {
+		return err
+	}
+	return add(mgr, r)
 }
 
 // newReconciler returns a new reconcile.Reconciler
-func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileGitopsService{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+func newReconciler(mgr manager.Manager) (reconcile.Reconciler, error) {
+	installer, err := dependency.NewClient(mgr.GetClient(),
+		dependency.WithEnvironment(os.Getenv(dependency.EnvironmentEnvVar)),
+		dependency.WithBackend(dependency.DetectBackend(mgr.GetRESTMapper())),
+		dependency.WithStatusObject(types.NamespacedName{Namespace: namespace, Name: name}))
+	if err != nil {
+		return nil, err
+	}
+	// Load the operators overridden by admins, e.g. to use a mirrored catalog.
+	// The manager cache isn't started yet so the ConfigMap is read directly.
+	operatorNs, err := k8sutil.GetOperatorNamespace()
+	if err == nil {
+		if err := installer.LoadOperators(context.Background(), mgr.GetAPIReader(), operatorNs); err != nil {
+			return nil, err
+		}
+		// pull secrets are copied from the operator namespace
+		if pullSecrets := parseList(os.Getenv(dependency.PullSecretsEnvVar)); len(pullSecrets) > 0 {
+			installer.PullSecretsNamespace = operatorNs
+			installer.PullSecrets = pullSecrets
+		}
+	} else if !goerrors.Is(err, k8sutil.ErrRunLocal) {
+		return nil, err
+	}
+	if err := addHealthCheck(mgr, installer); err != nil {
+		return nil, err
+	}
+	return &ReconcileGitopsService{
+		client:    mgr.GetClient(),
+		scheme:    mgr.GetScheme(),
+		installer: installer,
+	}, nil
+}
+
+// parseList splits a comma separated list, ignoring empty items
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// addHealthCheck supervises the installed operators in the background if a
+// health check interval is configured, recording an event on the
+// GitopsService whenever one becomes unhealthy or recovers
+func addHealthCheck(mgr manager.Manager, installer *dependency.Dependency) error {
+	value := os.Getenv(dependency.HealthCheckIntervalEnvVar)
+	if value == "" {
+		return nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		return fmt.Errorf("invalid %s %q: must be a positive duration", dependency.HealthCheckIntervalEnvVar, value)
+	}
+	recorder := mgr.GetEventRecorderFor("gitopsservice-controller")
+	installer.OnHealthChange = func(event dependency.HealthEvent) {
+		eventType, reason := corev1.EventTypeNormal, operatorRecoveredReason
+		if !event.Healthy {
+			eventType, reason = corev1.EventTypeWarning, operatorUnhealthyReason
+		}
+		recorder.Event(newGitopsService(name), eventType, reason, event.Message)
+	}
+	return mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-stop
+			cancel()
+		}()
+		installer.Supervise(ctx, interval)
+		return nil
+	}))
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -57,8 +156,9 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 
 	pred := predicate.Funcs{
 		UpdateFunc: func(e event.UpdateEvent) bool {
-			// Ignore updates to CR status in which case metadata.Generation does not change
-			return e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration()
+			// Ignore updates to CR status in which case metadata.Generation does not change,
+			// unless the CR is being deleted and needs finalizing
+			return e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration() || !e.MetaNew.GetDeletionTimestamp().IsZero()
 		},
 		DeleteFunc: func(e event.DeleteEvent) bool {
 			// Evaluates to false if the object has been confirmed deleted.
@@ -129,13 +229,19 @@ type ReconcileGitopsService struct {
 	// that reads objects from the cache and writes to the apiserver
 	client client.Client
 	scheme *runtime.Scheme
+
+	// installer installs the operators the GitOps service depends on
+	installer dependency.Installer
+
+	// stop is closed by the manager when the operator shuts down
+	stop <-chan struct{}
 }
 
 // Reconcile reads that state of the cluster for a GitopsService object and makes changes based on the state read
 // and what is in the GitopsService.Spec
 func (r *ReconcileGitopsService) Reconcile(request reconcile.Request) (reconcile.Result, error) {
 	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
-	reqLogger.Info("Reconciling GitopsService")
+	reqLogger.V(debugLevel).Info("Reconciling GitopsService")
 
 	// Fetch the GitopsService instance
 	instance := &pipelinesv1alpha1.GitopsService{}
@@ -151,6 +257,10 @@ func (r *ReconcileGitopsService) Reconcile(request reconcile.Request) (reconcile
 		return reconcile.Result{}, err
 	}
 
+	if result, done, err := r.reconcileDependencies(instance); done {
+		return result, err
+	}
+
 	// Define a new Pod object
 	deploymentObj := newDeploymentForCR(instance)
 
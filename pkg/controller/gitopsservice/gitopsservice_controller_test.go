@@ -0,0 +1,63 @@
+package gitopsservice
+
+import (
+	"context"
+	"testing"
+
+	routev1 "github.com/openshift/api/route/v1"
+	pipelinesv1alpha1 "github.com/redhat-developer/gitops-operator/pkg/apis/pipelines/v1alpha1"
+	"github.com/redhat-developer/gitops-operator/pkg/dependency/fake"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcile(t *testing.T) {
+	s := scheme.Scheme
+	addKnownTypesToScheme(s)
+
+	fakeClient := fakeclient.NewFakeClient(newGitopsService(name))
+	reconciler := newFakeReconcileGitopsService(fakeClient, s, fake.NewInstaller())
+
+	_, err := reconciler.Reconcile(newRequest(namespace, name))
+	assertNoError(t, err)
+
+	key := types.NamespacedName{Name: name, Namespace: namespace}
+	assertNoError(t, fakeClient.Get(context.TODO(), key, &appsv1.Deployment{}))
+	assertNoError(t, fakeClient.Get(context.TODO(), key, &corev1.Service{}))
+	assertNoError(t, fakeClient.Get(context.TODO(), key, &routev1.Route{}))
+}
+
+func newFakeReconcileGitopsService(client client.Client, scheme *runtime.Scheme, installer *fake.Installer) *ReconcileGitopsService {
+	return &ReconcileGitopsService{
+		client:    client,
+		scheme:    scheme,
+		installer: installer,
+	}
+}
+
+func addKnownTypesToScheme(scheme *runtime.Scheme) {
+	scheme.AddKnownTypes(pipelinesv1alpha1.SchemeGroupVersion, &pipelinesv1alpha1.GitopsService{})
+	scheme.AddKnownTypes(routev1.GroupVersion, &routev1.Route{})
+}
+
+func newRequest(namespace, name string) reconcile.Request {
+	return reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+}
+
+func assertNoError(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	roleFile                 = "../../deploy/role.yaml"
+	csvFile                  = "../../deploy/olm-catalog/gitops-operator/manifests/gitops-operator.clusterserviceversion.yaml"
+	installerRoleFile        = "../../deploy/installer_role.yaml"
+	bundledInstallerRoleFile = "../../deploy/olm-catalog/gitops-operator/manifests/gitops-operator-installer_rbac.authorization.k8s.io_v1_clusterrole.yaml"
+
+	// installerRole is the ClusterRole bound by the ClusterExtension backend
+	// of the dependency package
+	installerRole = "gitops-operator-installer"
+)
+
+// csvPermissions is the part of the bundle CSV holding the operator RBAC
+type csvPermissions struct {
+	Spec struct {
+		Install struct {
+			Spec struct {
+				Permissions []struct {
+					Rules []rbacv1.PolicyRule `json:"rules"`
+				} `json:"permissions"`
+			} `json:"spec"`
+		} `json:"install"`
+	} `json:"spec"`
+}
+
+// TestPermissions checks that the operator is granted the verbs the
+// controllers use, which the fake clients of their tests don't enforce
+func TestPermissions(t *testing.T) {
+	role := &rbacv1.ClusterRole{}
+	readYAML(t, roleFile, role)
+	csv := &csvPermissions{}
+	readYAML(t, csvFile, csv)
+	if len(csv.Spec.Install.Spec.Permissions) != 1 {
+		t.Fatalf("got %d permissions in %s, want 1", len(csv.Spec.Install.Spec.Permissions), csvFile)
+	}
+	if diff := cmp.Diff(role.Rules, csv.Spec.Install.Spec.Permissions[0].Rules); diff != "" {
+		t.Fatalf("the permissions of %s and %s differ: %v", roleFile, csvFile, diff)
+	}
+
+	tests := []struct {
+		group    string
+		resource string
+		verb     string
+	}{
+		// Shutdown removes the ConsoleLinks of all the instances at once
+		{"console.openshift.io", "consolelinks", "deletecollection"},
+		// Manual install plans of the operators are approved on install
+		{"operators.coreos.com", "installplans", "get"},
+		{"operators.coreos.com", "installplans", "patch"},
+		// drifted operator groups are restored on install
+		{"operators.coreos.com", "operatorgroups", "patch"},
+	}
+	for _, tt := range tests {
+		if !granted(role.Rules, tt.group, tt.resource, tt.verb) {
+			t.Errorf("%s is not granted %s on %s.%s", roleFile, tt.verb, tt.resource, tt.group)
+		}
+	}
+
+	// the installer service accounts of ClusterExtensions are bound to the
+	// installer role, which is the only role the operator may bind
+	for _, rule := range role.Rules {
+		if contains(rule.APIGroups, rbacv1.GroupName) && contains(rule.Resources, "clusterroles") && contains(rule.Verbs, "bind") && !cmp.Equal(rule.ResourceNames, []string{installerRole}) {
+			t.Errorf("%s grants bind on %v, want only %s", roleFile, rule.ResourceNames, installerRole)
+		}
+	}
+	installer := &rbacv1.ClusterRole{}
+	readYAML(t, installerRoleFile, installer)
+	if installer.Name != installerRole {
+		t.Fatalf("got installer role %s, want %s", installer.Name, installerRole)
+	}
+	bundled := &rbacv1.ClusterRole{}
+	readYAML(t, bundledInstallerRoleFile, bundled)
+	if diff := cmp.Diff(installer, bundled); diff != "" {
+		t.Fatalf("the installer roles of %s and %s differ: %v", installerRoleFile, bundledInstallerRoleFile, diff)
+	}
+}
+
+// granted reports whether rules grant verb on all the resources of a group
+func granted(rules []rbacv1.PolicyRule, group, resource, verb string) bool {
+	for _, rule := range rules {
+		if len(rule.ResourceNames) == 0 && contains(rule.APIGroups, group) && contains(rule.Resources, resource) && contains(rule.Verbs, verb) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value || v == rbacv1.ResourceAll {
+			return true
+		}
+	}
+	return false
+}
+
+func readYAML(t *testing.T, file string, obj interface{}) {
+	t.Helper()
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal(data, obj); err != nil {
+		t.Fatalf("failed to parse %s: %v", file, err)
+	}
+}
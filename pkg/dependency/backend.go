@@ -0,0 +1,248 @@
+package dependency
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	olmv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Backend selects the OLM API the operators are installed with
+type Backend string
+
+const (
+	// BackendSubscription installs the operators with OLM v0 Subscriptions
+	BackendSubscription Backend = "Subscription"
+	// BackendClusterExtension installs the operators with OLM v1
+	// ClusterExtensions, on clusters where OLM v0 is not available
+	BackendClusterExtension Backend = "ClusterExtension"
+)
+
+// installerClusterRole is the ClusterRole, shipped with the operator, granting
+// OLM v1 the permissions to install the operators. The operator may only bind
+// this role.
+const installerClusterRole = "gitops-operator-installer"
+
+// clusterExtensionGVK is the kind of the OLM v1 ClusterExtension, which is
+// not vendored and handled as unstructured
+var clusterExtensionGVK = schema.GroupVersionKind{Group: "olm.operatorframework.io", Version: "v1", Kind: "ClusterExtension"}
+
+// DetectBackend returns the backend supported by the cluster: Subscriptions
+// if OLM v0 is available, which is the default, and ClusterExtensions if
+// only OLM v1 is
+func DetectBackend(mapper meta.RESTMapper) Backend {
+	subscriptions := olmv1alpha1.SchemeGroupVersion.WithKind(olmv1alpha1.SubscriptionKind)
+	if _, err := mapper.RESTMapping(subscriptions.GroupKind(), subscriptions.Version); err == nil {
+		return BackendSubscription
+	}
+	if _, err := mapper.RESTMapping(clusterExtensionGVK.GroupKind(), clusterExtensionGVK.Version); err == nil {
+		log.Info("OLM v0 not available, installing operators with ClusterExtensions")
+		return BackendClusterExtension
+	}
+	return BackendSubscription
+}
+
+// installBackend subscribes to an operator once its namespace exists
+type installBackend interface {
+	// subscribe creates the resources installing the operator in namespace
+	// and returns the key of the resource to wait for
+	subscribe(ctx context.Context, operator operatorResource, namespace string) (types.NamespacedName, error)
+	// waitReady waits for the operator installed by subscribe to be ready
+	waitReady(ctx context.Context, operator operatorResource, key types.NamespacedName) error
+	// unsubscribe deletes the resources created by subscribe
+	unsubscribe(ctx context.Context, operator operatorResource, namespace string) error
+}
+
+// installBackend returns the implementation of the configured backend
+func (d *Dependency) installBackend() installBackend {
+	if d.Backend == BackendClusterExtension {
+		return &clusterExtensionBackend{d}
+	}
+	return &subscriptionBackend{d}
+}
+
+// subscriptionBackend installs the operators with OLM v0 Subscriptions and
+// waits for their CSVs, subscribe and unsubscribe are those of Dependency
+type subscriptionBackend struct {
+	*Dependency
+}
+
+func (b *subscriptionBackend) waitReady(ctx context.Context, operator operatorResource, csv types.NamespacedName) error {
+	return b.waitForOperator(ctx, operator, csv.Name, csv.Namespace)
+}
+
+// clusterExtensionBackend installs the operators with OLM v1
+// ClusterExtensions. OLM v1 installs the operator with the permissions of a
+// service account bound to the installer ClusterRole, created alongside the
+// extension.
+type clusterExtensionBackend struct {
+	*Dependency
+}
+
+func (b *clusterExtensionBackend) subscribe(ctx context.Context, operator operatorResource, namespace string) (types.NamespacedName, error) {
+	serviceAccount := installerServiceAccount(operator)
+	for _, obj := range []runtime.Object{
+		&corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: serviceAccount, Namespace: namespace, Labels: dependencyLabels(operator.name)},
+		},
+		newInstallerBinding(operator, namespace),
+		newClusterExtension(operator, namespace, b.operatorChannel(operator)),
+	} {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return types.NamespacedName{}, err
+		}
+		key := types.NamespacedName{Name: accessor.GetName(), Namespace: accessor.GetNamespace()}
+		result, err := createResourceIfAbsent(ctx, b.client, obj, key)
+		if err != nil {
+			return types.NamespacedName{}, err
+		}
+		log.V(debugLevel).Info("ClusterExtension resource applied", "Operator", operator.name, "Kind", kindOf(obj), "Name", keyString(key), "Result", result)
+	}
+	b.progress(ProgressEvent{Operator: operator.name, Namespace: namespace, Stage: StageSubscriptionCreated})
+	return types.NamespacedName{Name: operator.name, Namespace: namespace}, nil
+}
+
+// waitReady waits for the Installed condition of the ClusterExtension to be
+// true, the operator has no CSV to wait for with OLM v1
+func (b *clusterExtensionBackend) waitReady(ctx context.Context, operator operatorResource, key types.NamespacedName) error {
+	timeout := b.timeout
+	if operator.timeout > 0 {
+		timeout = operator.timeout
+	}
+	log.Info("Waiting for ClusterExtension to be installed", "ClusterExtension", key.Name, "Timeout", timeout)
+	message := ""
+	err := pollWithBackoff(ctx, pollBackoff, timeout, func() (bool, error) {
+		extension := newUnstructured(clusterExtensionGVK)
+		err := b.client.Get(ctx, types.NamespacedName{Name: key.Name}, extension)
+		if errors.IsNotFound(err) {
+			message = "not found"
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		installed, msg := clusterExtensionInstalled(extension)
+		message = msg
+		return installed, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("ClusterExtension %s not installed: %s: %w", key.Name, message, err)
+	}
+	return err
+}
+
+func (b *clusterExtensionBackend) unsubscribe(ctx context.Context, operator operatorResource, namespace string) error {
+	extension := newUnstructured(clusterExtensionGVK)
+	extension.SetName(operator.name)
+	for _, obj := range []runtime.Object{
+		extension,
+		newInstallerBinding(operator, namespace),
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: installerServiceAccount(operator), Namespace: namespace}},
+	} {
+		if err := deleteResourceIfPresent(ctx, b.client, obj); err != nil {
+			return err
+		}
+	}
+	if !b.WaitForRemoval {
+		return nil
+	}
+	log.Info("Waiting for ClusterExtension to be removed", "ClusterExtension", operator.name, "Timeout", b.timeout)
+	err := pollWithBackoff(ctx, pollBackoff, b.timeout, func() (bool, error) {
+		err := b.client.Get(ctx, types.NamespacedName{Name: operator.name}, newUnstructured(clusterExtensionGVK))
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("timed out waiting for ClusterExtension %s to be removed: %w", operator.name, err)
+	}
+	return err
+}
+
+// clusterExtensionInstalled reports whether the Installed condition of the
+// extension is true, along with the message of its conditions
+func clusterExtensionInstalled(extension *unstructured.Unstructured) (bool, string) {
+	conditions, _, _ := unstructured.NestedSlice(extension.Object, "status", "conditions")
+	messages := []string{}
+	installed := false
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condition["type"] == "Installed" && condition["status"] == "True" {
+			installed = true
+		}
+		if message, ok := condition["message"].(string); ok && message != "" {
+			messages = append(messages, fmt.Sprintf("%v: %s", condition["type"], message))
+		}
+	}
+	return installed, strings.Join(messages, ", ")
+}
+
+// newClusterExtension returns the ClusterExtension installing the operator
+// into namespace from channel. Like the StartingCSV of subscriptions, the
+// version of the CSV waited for is the minimum version installed.
+func newClusterExtension(operator operatorResource, namespace, channel string) *unstructured.Unstructured {
+	catalog := map[string]interface{}{
+		"packageName": operator.packageName,
+		"channels":    []interface{}{channel},
+	}
+	if i := strings.LastIndex(operator.csv, ".v"); i >= 0 {
+		catalog["version"] = ">=" + operator.csv[i+2:]
+	}
+	extension := newUnstructured(clusterExtensionGVK)
+	extension.SetName(operator.name)
+	extension.SetLabels(dependencyLabels(operator.name))
+	extension.Object["spec"] = map[string]interface{}{
+		"namespace": namespace,
+		"serviceAccount": map[string]interface{}{
+			"name": installerServiceAccount(operator),
+		},
+		"source": map[string]interface{}{
+			"sourceType": "Catalog",
+			"catalog":    catalog,
+		},
+	}
+	return extension
+}
+
+// newInstallerBinding binds the installer service account of the operator
+// in namespace to the installer ClusterRole
+func newInstallerBinding(operator operatorResource, namespace string) *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   installerServiceAccount(operator),
+			Labels: dependencyLabels(operator.name),
+		},
+		RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: installerClusterRole},
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.ServiceAccountKind, Name: installerServiceAccount(operator), Namespace: namespace},
+		},
+	}
+}
+
+// installerServiceAccount is the name of the service account OLM v1
+// installs the operator with
+func installerServiceAccount(operator operatorResource) string {
+	return operator.name + "-installer"
+}
+
+func newUnstructured(gvk schema.GroupVersionKind) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(gvk)
+	return u
+}
@@ -0,0 +1,164 @@
+package dependency
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"strings"
+
+	pipelinesv1alpha1 "github.com/redhat-developer/gitops-operator/pkg/apis/pipelines/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// ReasonInstalling is set while Install waits for the operator CSV
+	ReasonInstalling = "Installing"
+	// ReasonCSVSucceeded is set once the operator CSV has succeeded
+	ReasonCSVSucceeded = "CSVSucceeded"
+	// ReasonNotReady is set when the operator CSV did not succeed in time
+	ReasonNotReady = "NotReady"
+	// ReasonInstallFailed is set when the operator cannot be installed
+	ReasonInstallFailed = "InstallFailed"
+	// ReasonUnhealthy is set by Supervise when the CSV of an installed
+	// operator failed or is gone
+	ReasonUnhealthy = "Unhealthy"
+)
+
+// readyConditionType returns the type of the condition reporting the
+// readiness of the operator, e.g. ArgoCDOperatorReady. It is derived from
+// the operator name unless set explicitly.
+func (o operatorResource) readyConditionType() string {
+	if o.readyCondition != "" {
+		return o.readyCondition
+	}
+	var b strings.Builder
+	for _, part := range strings.Split(o.name, "-") {
+		if part != "" {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String() + "Ready"
+}
+
+// setCondition sets the readiness condition of the operator on the status
+// of StatusObject, if set. Failing to write it is logged rather than failing
+// the install.
+func (d *Dependency) setCondition(ctx context.Context, operator operatorResource, status corev1.ConditionStatus, reason, message string) {
+	d.updateCondition(ctx, pipelinesv1alpha1.GitopsServiceCondition{
+		Type:    operator.readyConditionType(),
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}, nil)
+}
+
+// setInstalling sets the condition of the operator to Installing while its
+// CSV is waited for, unless that CSV has already succeeded, so that the
+// conditions of ready operators don't flip whenever Install runs again
+func (d *Dependency) setInstalling(ctx context.Context, operator operatorResource, csv types.NamespacedName) {
+	d.updateCondition(ctx, pipelinesv1alpha1.GitopsServiceCondition{
+		Type:    operator.readyConditionType(),
+		Status:  corev1.ConditionFalse,
+		Reason:  ReasonInstalling,
+		Message: waitingMessage(csv),
+	}, func(existing pipelinesv1alpha1.GitopsServiceCondition) bool {
+		return existing.Status == corev1.ConditionTrue && existing.Message == succeededMessage(csv)
+	})
+}
+
+// updateCondition sets condition on the status of StatusObject, unless the
+// existing condition of the same type should be kept
+func (d *Dependency) updateCondition(ctx context.Context, condition pipelinesv1alpha1.GitopsServiceCondition, keep func(existing pipelinesv1alpha1.GitopsServiceCondition) bool) {
+	if d.StatusObject == nil {
+		return
+	}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		service := &pipelinesv1alpha1.GitopsService{}
+		if err := d.client.Get(ctx, *d.StatusObject, service); err != nil {
+			return err
+		}
+		if keep != nil {
+			for _, existing := range service.Status.Conditions {
+				if existing.Type == condition.Type && keep(existing) {
+					return nil
+				}
+			}
+		}
+		if !setStatusCondition(&service.Status.Conditions, condition) {
+			return nil
+		}
+		return d.client.Status().Update(ctx, service)
+	})
+	if err != nil {
+		log.Error(err, "Failed to update status condition", "Object", keyString(*d.StatusObject), "Condition", condition.Type)
+	}
+}
+
+// setStatusCondition sets condition in conditions, keeping its last
+// transition time unless its status changed, and reports whether conditions
+// changed
+func setStatusCondition(conditions *[]pipelinesv1alpha1.GitopsServiceCondition, condition pipelinesv1alpha1.GitopsServiceCondition) bool {
+	for i := range *conditions {
+		existing := &(*conditions)[i]
+		if existing.Type != condition.Type {
+			continue
+		}
+		if existing.Status == condition.Status && existing.Reason == condition.Reason && existing.Message == condition.Message {
+			return false
+		}
+		condition.LastTransitionTime = existing.LastTransitionTime
+		if existing.Status != condition.Status {
+			condition.LastTransitionTime = metav1.Now()
+		}
+		*existing = condition
+		return true
+	}
+	condition.LastTransitionTime = metav1.Now()
+	*conditions = append(*conditions, condition)
+	return true
+}
+
+// setParallelConditions sets the conditions of the operators once waiting
+// for their csvs in parallel failed with err. Operators whose CSV is not
+// listed as not ready by err succeeded.
+func (d *Dependency) setParallelConditions(ctx context.Context, csvs []types.NamespacedName, err error) {
+	notReady := &NotReadyError{}
+	if !goerrors.As(err, &notReady) {
+		for i := range csvs {
+			d.setCondition(ctx, d.operators[i], corev1.ConditionFalse, failureReason(err), err.Error())
+		}
+		return
+	}
+	for i, csv := range csvs {
+		var timeout *TimeoutError
+		for _, t := range notReady.Timeouts {
+			if t.CSV == csv.Name && t.Namespace == csv.Namespace {
+				timeout = t
+			}
+		}
+		if timeout != nil {
+			d.setCondition(ctx, d.operators[i], corev1.ConditionFalse, ReasonNotReady, timeout.Error())
+		} else {
+			d.setCondition(ctx, d.operators[i], corev1.ConditionTrue, ReasonCSVSucceeded, succeededMessage(csv))
+		}
+	}
+}
+
+func waitingMessage(csv types.NamespacedName) string {
+	return fmt.Sprintf("Waiting for CSV %s in namespace %s", csv.Name, csv.Namespace)
+}
+
+func succeededMessage(csv types.NamespacedName) string {
+	return fmt.Sprintf("CSV %s in namespace %s succeeded", csv.Name, csv.Namespace)
+}
+
+// failureReason returns the condition reason of an install failing with err
+func failureReason(err error) string {
+	if IsTimeout(err) {
+		return ReasonNotReady
+	}
+	return ReasonInstallFailed
+}
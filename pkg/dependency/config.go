@@ -0,0 +1,231 @@
+package dependency
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	olmv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// EnvironmentEnvVar is the environment variable identifying the cluster,
+	// e.g. dev or prod, to select the channels of the operators
+	EnvironmentEnvVar = "GITOPS_ENVIRONMENT"
+
+	// HealthCheckIntervalEnvVar is the environment variable holding how
+	// often the installed operators are checked for health, e.g. 5m. They
+	// are not supervised if it is unset.
+	HealthCheckIntervalEnvVar = "GITOPS_HEALTH_CHECK_INTERVAL"
+
+	// PullSecretsEnvVar is the environment variable holding the comma
+	// separated names of the pull secrets, in the namespace of the GitOps
+	// operator, linked to the operator namespaces
+	PullSecretsEnvVar = "GITOPS_OPERATOR_PULL_SECRETS"
+
+	// OperatorsConfigMapName is the ConfigMap, in the namespace of the GitOps
+	// operator, overriding the operators installed by Install
+	OperatorsConfigMapName = "gitops-operator-dependencies"
+
+	// operatorsConfigKey is the ConfigMap key holding the YAML list of operators
+	operatorsConfigKey = "operators.yaml"
+)
+
+// operatorConfig is an operator listed in the operators ConfigMap, e.g.
+//
+//   - name: argocd-operator
+//     package: argocd-operator
+//     namespace: argocd
+//     channel: alpha
+//     channels: {prod: stable}
+//     csv: argocd-operator.v0.0.13
+//     catalogSource: mirrored-operators
+//     catalogSourceNamespace: openshift-marketplace
+//     installMode: MultiNamespace
+//     targetNamespaces: [argocd, gitops]
+//     timeout: 5m
+type operatorConfig struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Channel   string `json:"channel"`
+	CSV       string `json:"csv"`
+
+	// Channels maps environments, see EnvironmentEnvVar, to the channel
+	// subscribed to in them instead of Channel
+	Channels map[string]string `json:"channels,omitempty"`
+
+	// Package is the package subscribed to, Name by default
+	Package string `json:"package,omitempty"`
+
+	// CatalogSource and CatalogSourceNamespace default to community-operators
+	// in openshift-marketplace
+	CatalogSource          string `json:"catalogSource,omitempty"`
+	CatalogSourceNamespace string `json:"catalogSourceNamespace,omitempty"`
+
+	// InstallMode is one of OwnNamespace (the default), SingleNamespace,
+	// MultiNamespace or AllNamespaces. TargetNamespaces are required by the
+	// SingleNamespace and MultiNamespace modes.
+	InstallMode      olmv1alpha1.InstallModeType `json:"installMode,omitempty"`
+	TargetNamespaces []string                    `json:"targetNamespaces,omitempty"`
+
+	// Timeout, if set, replaces the installer timeout for this operator
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+}
+
+// LoadOperators replaces the built-in operators with the ones listed in the
+// operators ConfigMap in namespace, so that e.g. mirrored catalogs can be used
+// without rebuilding the operator. The built-in operators are kept if the
+// ConfigMap doesn't exist.
+func (d *Dependency) LoadOperators(ctx context.Context, r client.Reader, namespace string) error {
+	configMap := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Name: OperatorsConfigMapName, Namespace: namespace}, configMap)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("Operators ConfigMap not found, using the built-in operators", "ConfigMap", OperatorsConfigMapName, "Namespace", namespace)
+			return nil
+		}
+		return fmt.Errorf("failed to get ConfigMap %s/%s: %w", namespace, OperatorsConfigMapName, err)
+	}
+	operators, err := parseOperators(configMap.Data[operatorsConfigKey])
+	if err != nil {
+		return fmt.Errorf("invalid ConfigMap %s/%s: %w", namespace, OperatorsConfigMapName, err)
+	}
+	previous := d.operators
+	d.operators = operators
+	if err := d.validatePrefix(); err != nil {
+		d.operators = previous
+		return err
+	}
+	log.Info("Loaded operators from ConfigMap", "ConfigMap", OperatorsConfigMapName, "Namespace", namespace, "Count", len(operators))
+	return nil
+}
+
+// parseOperators parses the YAML list of operators of the operators ConfigMap
+func parseOperators(data string) ([]operatorResource, error) {
+	configs := []operatorConfig{}
+	decoder := yaml.NewYAMLOrJSONDecoder(strings.NewReader(data), 4096)
+	if err := decoder.Decode(&configs); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", operatorsConfigKey, err)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("%s lists no operators", operatorsConfigKey)
+	}
+	operators := []operatorResource{}
+	for i, config := range configs {
+		if config.Name == "" || config.Namespace == "" || config.Channel == "" || config.CSV == "" {
+			return nil, fmt.Errorf("operator %d of %s: name, namespace, channel and csv are required", i, operatorsConfigKey)
+		}
+		for environment, channel := range config.Channels {
+			if environment == "" || channel == "" {
+				return nil, fmt.Errorf("operator %s of %s: channels must map environments to channels", config.Name, operatorsConfigKey)
+			}
+		}
+		if config.Timeout.Duration < 0 {
+			return nil, fmt.Errorf("operator %s of %s: timeout must be positive", config.Name, operatorsConfigKey)
+		}
+		if err := validateInstallMode(config.InstallMode, config.TargetNamespaces); err != nil {
+			return nil, fmt.Errorf("operator %s of %s: %w", config.Name, operatorsConfigKey, err)
+		}
+		if config.Package == "" {
+			config.Package = config.Name
+		}
+		operators = append(operators, operatorResource{
+			name:                   config.Name,
+			packageName:            config.Package,
+			namespace:              config.Namespace,
+			channel:                config.Channel,
+			channels:               config.Channels,
+			csv:                    config.CSV,
+			catalogSource:          config.CatalogSource,
+			catalogSourceNamespace: config.CatalogSourceNamespace,
+			installMode:            config.InstallMode,
+			targetNamespaces:       config.TargetNamespaces,
+			timeout:                config.Timeout.Duration,
+		})
+	}
+	return operators, nil
+}
+
+// validateInstallMode checks that targetNamespaces are set only, and as
+// required, by the installMode
+func validateInstallMode(installMode olmv1alpha1.InstallModeType, targetNamespaces []string) error {
+	switch installMode {
+	case "", olmv1alpha1.InstallModeTypeOwnNamespace, olmv1alpha1.InstallModeTypeAllNamespaces:
+		if len(targetNamespaces) > 0 {
+			return fmt.Errorf("targetNamespaces are not supported by install mode %s", installMode)
+		}
+	case olmv1alpha1.InstallModeTypeSingleNamespace:
+		if len(targetNamespaces) != 1 {
+			return fmt.Errorf("install mode %s requires one target namespace", installMode)
+		}
+	case olmv1alpha1.InstallModeTypeMultiNamespace:
+		if len(targetNamespaces) == 0 {
+			return fmt.Errorf("install mode %s requires target namespaces", installMode)
+		}
+	default:
+		return fmt.Errorf("invalid install mode %q", installMode)
+	}
+	return nil
+}
+
+// Validate checks that the fields required to install the operator are set,
+// reporting all the invalid fields at once
+func (o operatorResource) Validate() error {
+	var errs []error
+	for _, field := range []struct {
+		name  string
+		value string
+	}{
+		{"name", o.name},
+		{"package", o.packageName},
+		{"namespace", o.namespace + o.namespaceOverride},
+		{"channel", o.channel},
+		{"csv", o.csv},
+	} {
+		if strings.TrimSpace(field.value) == "" {
+			errs = append(errs, fmt.Errorf("%s must not be empty", field.name))
+		}
+	}
+	if o.timeout < 0 {
+		errs = append(errs, fmt.Errorf("timeout must be positive"))
+	}
+	if err := validateInstallMode(o.installMode, o.targetNamespaces); err != nil {
+		errs = append(errs, err)
+	}
+	if err := utilerrors.NewAggregate(errs); err != nil {
+		return fmt.Errorf("invalid operator %q: %w", o.name, err)
+	}
+	return nil
+}
+
+// Validate checks the definitions of all the operators installed by Install
+func (d *Dependency) Validate() error {
+	return validateOperators(d.operators)
+}
+
+// ValidateOperators checks the YAML list of operators of the operators
+// ConfigMap, so that it can be checked before being applied to a cluster
+func ValidateOperators(data string) error {
+	operators, err := parseOperators(data)
+	if err != nil {
+		return err
+	}
+	return validateOperators(operators)
+}
+
+func validateOperators(operators []operatorResource) error {
+	var errs []error
+	for _, operator := range operators {
+		if err := operator.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
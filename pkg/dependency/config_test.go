@@ -0,0 +1,197 @@
+package dependency
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	olmv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestLoadOperators(t *testing.T) {
+	t.Run("operators from the ConfigMap", func(t *testing.T) {
+		configMap := newOperatorsConfigMap(`
+- name: argocd-operator
+  package: argocd-operator-mirror
+  namespace: gitops
+  channel: stable
+  csv: argocd-operator.v0.0.14
+  catalogSource: mirrored-operators
+  catalogSourceNamespace: mirror
+- name: sealed-secrets-operator-helm
+  namespace: cicd
+  channel: alpha
+  csv: sealed-secrets-operator-helm.v0.0.2
+`)
+		fakeClient := newFakeClient(t, configMap)
+		d := newTestDependency(fakeClient, "")
+
+		assertNoError(t, d.LoadOperators(context.TODO(), fakeClient, "gitops-operator"))
+
+		want := []operatorResource{
+			{
+				name:                   "argocd-operator",
+				packageName:            "argocd-operator-mirror",
+				namespace:              "gitops",
+				channel:                "stable",
+				csv:                    "argocd-operator.v0.0.14",
+				catalogSource:          "mirrored-operators",
+				catalogSourceNamespace: "mirror",
+			},
+			{
+				name:        "sealed-secrets-operator-helm",
+				packageName: "sealed-secrets-operator-helm",
+				namespace:   "cicd",
+				channel:     "alpha",
+				csv:         "sealed-secrets-operator-helm.v0.0.2",
+			},
+		}
+		if diff := cmp.Diff(want, d.operators, cmp.AllowUnexported(operatorResource{})); diff != "" {
+			t.Fatalf("operators mismatch: %v", diff)
+		}
+
+		_, err := d.apply(context.TODO(), d.operators[0])
+		assertNoError(t, err)
+		subscription := &olmv1alpha1.Subscription{}
+		assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "argocd-operator", Namespace: "gitops"}, subscription))
+		if subscription.Spec.CatalogSource != "mirrored-operators" || subscription.Spec.CatalogSourceNamespace != "mirror" {
+			t.Fatalf("got catalog source %s/%s", subscription.Spec.CatalogSourceNamespace, subscription.Spec.CatalogSource)
+		}
+	})
+	t.Run("built-in operators without the ConfigMap", func(t *testing.T) {
+		fakeClient := newFakeClient(t)
+		d := newTestDependency(fakeClient, "")
+
+		assertNoError(t, d.LoadOperators(context.TODO(), fakeClient, "gitops-operator"))
+
+		want := []operatorResource{newArgoCDOperator(""), newSealedSecretsOperator("")}
+		if diff := cmp.Diff(want, d.operators, cmp.AllowUnexported(operatorResource{})); diff != "" {
+			t.Fatalf("operators mismatch: %v", diff)
+		}
+	})
+	t.Run("invalid ConfigMap", func(t *testing.T) {
+		configMap := newOperatorsConfigMap(`
+- name: argocd-operator
+  namespace: argocd
+`)
+		fakeClient := newFakeClient(t, configMap)
+		d := newTestDependency(fakeClient, "")
+
+		if err := d.LoadOperators(context.TODO(), fakeClient, "gitops-operator"); err == nil {
+			t.Fatal("was expecting an error for an operator without channel and csv")
+		}
+		if len(d.operators) != 2 {
+			t.Fatalf("got %d operators, want the 2 built-in ones to be kept", len(d.operators))
+		}
+	})
+	t.Run("install modes", func(t *testing.T) {
+		operators, err := parseOperators(`
+- name: argocd-operator
+  namespace: argocd
+  channel: alpha
+  csv: argocd-operator.v0.0.13
+  installMode: MultiNamespace
+  targetNamespaces: [argocd, gitops]
+  timeout: 5m
+`)
+		assertNoError(t, err)
+		if operators[0].timeout != 5*time.Minute {
+			t.Fatalf("got timeout %v, want 5m", operators[0].timeout)
+		}
+		if diff := cmp.Diff([]string{"argocd", "gitops"}, operators[0].operatorGroupTargets("argocd")); diff != "" {
+			t.Fatalf("target namespaces mismatch: %v", diff)
+		}
+
+		for _, invalid := range []string{"installMode: MultiNamespace", "installMode: AllNamespaces\n  targetNamespaces: [gitops]", "installMode: Everywhere"} {
+			_, err := parseOperators("- name: argocd-operator\n  namespace: argocd\n  channel: alpha\n  csv: argocd-operator.v0.0.13\n  " + invalid)
+			if err == nil {
+				t.Fatalf("was expecting an error for %q", invalid)
+			}
+		}
+	})
+}
+
+func newOperatorsConfigMap(operators string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: OperatorsConfigMapName, Namespace: "gitops-operator"},
+		Data:       map[string]string{operatorsConfigKey: operators},
+	}
+}
+
+func TestOperatorResource_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*operatorResource)
+		wantErr string
+	}{
+		{"valid", func(o *operatorResource) {}, ""},
+		{"namespace override", func(o *operatorResource) { o.namespace, o.namespaceOverride = "", "gitops" }, ""},
+		{"empty name", func(o *operatorResource) { o.name = "" }, `invalid operator "": name must not be empty`},
+		{"empty package", func(o *operatorResource) { o.packageName = "" }, "package must not be empty"},
+		{"empty namespace", func(o *operatorResource) { o.namespace = " " }, "namespace must not be empty"},
+		{"empty channel", func(o *operatorResource) { o.channel = "" }, "channel must not be empty"},
+		{"missing csv", func(o *operatorResource) { o.csv = "" }, "csv must not be empty"},
+		{"negative timeout", func(o *operatorResource) { o.timeout = -time.Minute }, "timeout must be positive"},
+		{"invalid install mode", func(o *operatorResource) { o.installMode = "Everywhere" }, `invalid install mode "Everywhere"`},
+		{"several fields", func(o *operatorResource) { o.namespace, o.csv = "", "" }, `invalid operator "argocd-operator": [namespace must not be empty, csv must not be empty]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operator := newArgoCDOperator("")
+			tt.mutate(&operator)
+			err := operator.Validate()
+			if tt.wantErr == "" {
+				assertNoError(t, err)
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInstall_invalid_operator(t *testing.T) {
+	fakeClient := newFakeClient(t)
+	d := newTestDependency(fakeClient, "")
+	d.operators[0].csv = ""
+	d.operators[1].packageName = ""
+
+	err := d.Install()
+	want := `failed to install dependencies: [invalid operator "argocd-operator": csv must not be empty, invalid operator "sealed-secrets-operator-helm": package must not be empty]`
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %s", err, want)
+	}
+
+	// nothing is created before the operators are validated
+	namespaces := &corev1.NamespaceList{}
+	assertNoError(t, fakeClient.List(context.TODO(), namespaces))
+	if len(namespaces.Items) != 0 {
+		t.Fatalf("got namespaces %v, want none", namespaces.Items)
+	}
+}
+
+func TestValidateOperators(t *testing.T) {
+	assertNoError(t, ValidateOperators(`
+- name: argocd-operator
+  namespace: argocd
+  channel: alpha
+  csv: argocd-operator.v0.0.13
+`))
+
+	err := ValidateOperators(`
+- name: argocd-operator
+  namespace: argocd
+  channel: alpha
+  csv: argocd-operator.v0.0.13
+  installMode: SingleNamespace
+`)
+	if err == nil || !strings.Contains(err.Error(), "requires one target namespace") {
+		t.Fatalf("got error %v, want the install mode to be rejected", err)
+	}
+}
@@ -0,0 +1,1495 @@
+package dependency
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	operatorsv1 "github.com/operator-framework/api/pkg/operators/v1"
+	olmv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("dependency")
+
+const (
+	catalogSource          = "community-operators"
+	catalogSourceNamespace = "openshift-marketplace"
+	operatorGroupName      = "gitops-operator-group"
+
+	// managedByLabel and dependencyLabel identify the resources created by Install
+	managedByLabel  = "app.kubernetes.io/managed-by"
+	managedByValue  = "gitops-operator"
+	dependencyLabel = "gitops.openshift.io/dependency"
+
+	// allReadyMessage is logged once every dependent operator is ready
+	allReadyMessage = "All GitOps dependencies ready"
+
+	// clusterWideNamespace is the namespace OLM uses for operators installed in AllNamespaces mode
+	clusterWideNamespace = "openshift-operators"
+
+	// debugLevel and traceLevel are the verbosities of detailed and of per
+	// poll log messages, enabled with --zap-level=1 and --zap-level=2
+	debugLevel = 1
+	traceLevel = 2
+
+	// subscriptionResolutionFailed is not yet defined by the vendored OLM API
+	subscriptionResolutionFailed olmv1alpha1.SubscriptionConditionType = "ResolutionFailed"
+)
+
+var (
+	// pollBackoff spaces the readiness checks of a CSV, checking often at
+	// first and then every few seconds to go easy on the apiserver. The
+	// jitter spreads out the checks of operators waited for in parallel.
+	pollBackoff = wait.Backoff{
+		Duration: 500 * time.Millisecond,
+		Factor:   2,
+		Jitter:   0.2,
+		Steps:    10,
+		Cap:      4 * time.Second,
+	}
+	pollTimeout = time.Minute
+
+	// unknownPhaseLimit is the number of polls a CSV may stay in the Unknown
+	// phase before giving up, 0 waits until the timeout
+	unknownPhaseLimit = 10
+)
+
+// operatorResource describes an operator installed through OLM
+type operatorResource struct {
+	// name is the name of the subscription, packageName the name of the
+	// package subscribed to in the catalog
+	name        string
+	packageName string
+	namespace   string
+	channel     string
+	csv         string
+
+	// channels, if set, maps environments to the channel subscribed to in
+	// them, e.g. alpha on dev clusters and stable in production. channel is
+	// used in environments not listed.
+	channels map[string]string
+
+	// namespaceOverride, if set, is used verbatim as the operator namespace
+	// instead of prefixing namespace
+	namespaceOverride string
+
+	// catalogSource and catalogSourceNamespace, if set, replace the
+	// community-operators catalog of the subscription
+	catalogSource          string
+	catalogSourceNamespace string
+
+	// installMode is the scope of the operator group, OwnNamespace if empty.
+	// targetNamespaces are the namespaces watched in MultiNamespace mode.
+	installMode      olmv1alpha1.InstallModeType
+	targetNamespaces []string
+
+	// readyCondition is the type of the status condition reporting the
+	// readiness of the operator, derived from name if empty
+	readyCondition string
+
+	// timeout, if set, replaces the installer timeout when waiting for the
+	// operator on its own, e.g. for operators known to install slowly
+	timeout time.Duration
+
+	// roles and roleBindings are created in the operator namespace
+	// alongside the operator, their namespace is set on install
+	roles        []rbacv1.Role
+	roleBindings []rbacv1.RoleBinding
+}
+
+// rbacObjects returns the roles and role bindings of the operator in namespace
+func (o operatorResource) rbacObjects(namespace string) []runtime.Object {
+	objs := []runtime.Object{}
+	for _, role := range o.roles {
+		role := role.DeepCopy()
+		role.Namespace = namespace
+		role.Labels = mergeLabels(role.Labels, dependencyLabels(o.name))
+		objs = append(objs, role)
+	}
+	for _, roleBinding := range o.roleBindings {
+		roleBinding := roleBinding.DeepCopy()
+		roleBinding.Namespace = namespace
+		roleBinding.Labels = mergeLabels(roleBinding.Labels, dependencyLabels(o.name))
+		objs = append(objs, roleBinding)
+	}
+	return objs
+}
+
+// operatorGroupTargets returns the target namespaces of the operator group of
+// the operator installed in namespace, empty in AllNamespaces mode
+func (o operatorResource) operatorGroupTargets(namespace string) []string {
+	switch o.installMode {
+	case olmv1alpha1.InstallModeTypeAllNamespaces:
+		return nil
+	case olmv1alpha1.InstallModeTypeMultiNamespace, olmv1alpha1.InstallModeTypeSingleNamespace:
+		return append([]string{}, o.targetNamespaces...)
+	}
+	return []string{namespace}
+}
+
+// newArgoCDOperator returns the argocd-operator, installed in namespaceOverride
+// if set and in the prefixed argocd namespace otherwise
+func newArgoCDOperator(namespaceOverride string) operatorResource {
+	return operatorResource{
+		name:              "argocd-operator",
+		packageName:       "argocd-operator",
+		namespace:         "argocd",
+		channel:           "alpha",
+		csv:               "argocd-operator.v0.0.13",
+		readyCondition:    "ArgoCDOperatorReady",
+		namespaceOverride: namespaceOverride,
+	}
+}
+
+// newSealedSecretsOperator returns the sealed-secrets operator, installed in
+// namespaceOverride if set and in the prefixed cicd namespace otherwise
+func newSealedSecretsOperator(namespaceOverride string) operatorResource {
+	return operatorResource{
+		name:              "sealed-secrets-operator-helm",
+		packageName:       "sealed-secrets-operator-helm",
+		namespace:         "cicd",
+		channel:           "alpha",
+		csv:               "sealed-secrets-operator-helm.v0.0.2",
+		readyCondition:    "SealedSecretsOperatorReady",
+		namespaceOverride: namespaceOverride,
+	}
+}
+
+// Installer installs and uninstalls the operators required by the GitOps service
+type Installer interface {
+	Install() error
+	// InstallContext is like Install but gives up once ctx is cancelled,
+	// e.g. when the operator shuts down
+	InstallContext(ctx context.Context) error
+	Uninstall() error
+}
+
+// blank assignment to verify that Dependency implements Installer
+var _ Installer = &Dependency{}
+
+// Dependency installs the operators required by the GitOps service
+type Dependency struct {
+	client    client.Client
+	prefix    string
+	timeout   time.Duration
+	operators []operatorResource
+
+	// Backend selects the OLM API the operators are installed with,
+	// Subscriptions by default, see DetectBackend
+	Backend Backend
+
+	// ReuseClusterWide makes Install skip operators that are already
+	// installed cluster-wide in openshift-operators and only wait for
+	// the existing CSV to be ready. Otherwise they are reported as a
+	// *SubscriptionConflictError.
+	ReuseClusterWide bool
+
+	// CatalogSource, if set, is created by Install and used by the
+	// subscriptions instead of the community-operators catalog, e.g. to
+	// install from an index image in disconnected clusters.
+	CatalogSource *CatalogSourceConfig
+
+	// SharedNamespace, if set, is the namespace all the operators are
+	// installed into, verbatim, with a single OperatorGroup targeting it,
+	// instead of a namespace per operator, e.g. on constrained clusters
+	SharedNamespace string
+
+	// NamespaceLabels are added to the operator namespaces created by
+	// Install, e.g. pod-security.kubernetes.io/enforce
+	NamespaceLabels map[string]string
+
+	// PatchExistingNamespaces makes Install add the NamespaceLabels missing
+	// from operator namespaces which already exist. Labels already set on
+	// them are left untouched.
+	PatchExistingNamespaces bool
+
+	// PullSecrets are the names of the secrets linked to the default service
+	// account of the operator namespaces, e.g. to pull the operator images
+	// from a private registry in disconnected clusters
+	PullSecrets []string
+
+	// PullSecretsNamespace, if set, is the namespace the PullSecrets are
+	// copied from into the operator namespaces. Otherwise they must already
+	// exist in the operator namespaces.
+	PullSecretsNamespace string
+
+	// WaitInParallel makes Install create every operator's resources first and
+	// then wait for all of their CSVs with WaitForAll, so the installer timeout
+	// bounds the whole bootstrap instead of each operator separately. The
+	// timeouts of individual operators are not used then. It only applies to
+	// the Subscription backend.
+	WaitInParallel bool
+
+	// WaitForRemoval makes Uninstall wait for the subscription and CSV of
+	// each operator to be gone, e.g. once their finalizers have run, before
+	// deleting its namespace
+	WaitForRemoval bool
+
+	// FailedPhaseTolerance is the number of consecutive polls a CSV may be
+	// in the Failed phase, e.g. while OLM retries a transient failure, before
+	// the install is aborted. 0 aborts as soon as the CSV has failed.
+	FailedPhaseTolerance int
+
+	// InstallPlanApproval sets the install plan approval of the subscription of
+	// operators, keyed by operator name, Automatic if absent. The initial
+	// install plan of operators with Manual approval is approved by Install so
+	// that they are installed, later upgrades have to be approved by hand.
+	InstallPlanApproval map[string]olmv1alpha1.Approval
+
+	// StatusObject, if set, is the GitopsService on whose status Install
+	// sets a condition reporting the readiness of each operator, e.g.
+	// ArgoCDOperatorReady, so that the install can be followed with kubectl
+	StatusObject *types.NamespacedName
+
+	// Environment identifies the cluster, e.g. dev or prod, to select the
+	// channel of operators whose channel depends on the environment
+	Environment string
+
+	// OnProgress, if set, is called by Install as each operator reaches a
+	// stage of its installation, e.g. to stream progress to a user
+	OnProgress func(ProgressEvent)
+
+	// OnHealthChange, if set, is called by Supervise when an installed
+	// operator becomes unhealthy or recovers
+	OnHealthChange func(HealthEvent)
+}
+
+// CatalogSourceConfig describes a CatalogSource created by Install
+type CatalogSourceConfig struct {
+	Name string
+	// Namespace defaults to openshift-marketplace, whose catalog sources are
+	// available in all namespaces
+	Namespace string
+	// SourceType defaults to grpc
+	SourceType olmv1alpha1.SourceType
+	// Image is the index image used by grpc catalog sources
+	Image string
+	// Address of a pre-existing registry used by grpc catalog sources without an image
+	Address string
+	// ConfigMap backs configmap catalog sources
+	ConfigMap string
+}
+
+// namespace returns the namespace the catalog source is created in
+func (c *CatalogSourceConfig) namespace() string {
+	if c.Namespace == "" {
+		return catalogSourceNamespace
+	}
+	return c.Namespace
+}
+
+// NewClient returns a Dependency which installs the operators configured by
+// opts. An error is returned if an option is invalid or if the operator
+// namespaces would not be valid namespace names.
+func NewClient(client client.Client, opts ...Option) (*Dependency, error) {
+	d := &Dependency{
+		client:  client,
+		timeout: pollTimeout,
+		operators: []operatorResource{
+			newArgoCDOperator(""),
+			newSealedSecretsOperator(""),
+		},
+	}
+	for _, opt := range opts {
+		if err := opt(d); err != nil {
+			return nil, err
+		}
+	}
+	if err := d.validatePrefix(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// NewClientWithPrefix returns a Dependency which installs the operators into
+// namespaces prefixed with prefix
+func NewClientWithPrefix(client client.Client, prefix string) (*Dependency, error) {
+	return NewClient(client, WithPrefix(prefix))
+}
+
+// NewClientWithNamespaces is like NewClientWithPrefix but installs the
+// operators listed in namespaces, keyed by operator name, into the given
+// namespace verbatim, regardless of prefix
+func NewClientWithNamespaces(client client.Client, prefix string, namespaces map[string]string) (*Dependency, error) {
+	return NewClient(client, WithPrefix(prefix), WithNamespaces(namespaces))
+}
+
+// findOperator returns the configured operator with name
+func (d *Dependency) findOperator(name string) (operatorResource, bool) {
+	for _, operator := range d.operators {
+		if operator.name == name {
+			return operator, true
+		}
+	}
+	return operatorResource{}, false
+}
+
+// validatePrefix checks that the prefix, every prefixed operator namespace
+// and every namespace override are valid DNS-1123 labels
+func (d *Dependency) validatePrefix() error {
+	if d.prefix != "" {
+		if errs := validation.IsDNS1123Label(d.prefix); len(errs) > 0 {
+			return fmt.Errorf("invalid prefix %q: %s", d.prefix, strings.Join(errs, ", "))
+		}
+	}
+	for _, operator := range d.operators {
+		namespace := d.operatorNamespace(operator)
+		errs := validation.IsDNS1123Label(namespace)
+		if len(errs) == 0 {
+			continue
+		}
+		if operator.namespaceOverride != "" {
+			return fmt.Errorf("invalid namespace %q for operator %s: %s", namespace, operator.name, strings.Join(errs, ", "))
+		}
+		return fmt.Errorf("invalid prefix %q, namespace %q is not valid: %s", d.prefix, namespace, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// Install creates the namespace, operator group and subscription for each
+// dependent operator and waits for its CSV to succeed
+func (d *Dependency) Install() error {
+	return d.InstallContext(context.Background())
+}
+
+// InstallContext is like Install but stops waiting for the operators, and
+// returns the context error, once ctx is cancelled
+func (d *Dependency) InstallContext(ctx context.Context) error {
+	if err := d.Validate(); err != nil {
+		return fmt.Errorf("failed to install dependencies: %w", err)
+	}
+	if err := d.ensureCatalogSource(ctx); err != nil {
+		return fmt.Errorf("failed to install dependencies: %w", err)
+	}
+	ready := []string{}
+	if d.WaitInParallel && d.Backend != BackendClusterExtension {
+		csvs := []types.NamespacedName{}
+		subscriptions := []string{}
+		for _, operator := range d.operators {
+			csv, err := d.apply(ctx, operator)
+			if err != nil {
+				d.setCondition(ctx, operator, corev1.ConditionFalse, ReasonInstallFailed, err.Error())
+				return fmt.Errorf("failed to install operator %s: %w", operator.name, err)
+			}
+			csvs = append(csvs, csv)
+			subscriptions = append(subscriptions, operator.name)
+		}
+		log.Info("Waiting for operators to be ready", "CSVs", csvs)
+		for i, csv := range csvs {
+			d.setInstalling(ctx, d.operators[i], csv)
+			d.progress(ProgressEvent{Operator: d.operators[i].name, Namespace: csv.Namespace, Stage: StageWaiting, CSV: csv.Name})
+		}
+		if err := waitForAll(ctx, d.client, csvs, subscriptions, d.timeout, d.FailedPhaseTolerance); err != nil {
+			d.setParallelConditions(ctx, csvs, err)
+			return err
+		}
+		for i, csv := range csvs {
+			d.setCondition(ctx, d.operators[i], corev1.ConditionTrue, ReasonCSVSucceeded, succeededMessage(csv))
+			d.progress(ProgressEvent{Operator: d.operators[i].name, Namespace: csv.Namespace, Stage: StageReady, CSV: csv.Name})
+			ready = append(ready, csv.Name)
+		}
+	} else {
+		for _, operator := range d.operators {
+			csv, err := d.install(ctx, operator)
+			if err != nil {
+				return fmt.Errorf("failed to install operator %s: %w", operator.name, err)
+			}
+			ready = append(ready, csv)
+		}
+	}
+	log.Info(allReadyMessage, "CSVs", ready)
+	return nil
+}
+
+// InstallOperator installs only the named operator of the configured set and
+// waits for it to be ready, e.g. to recover from the failure of one operator
+// without installing the others again
+func (d *Dependency) InstallOperator(name string) error {
+	operator, ok := d.findOperator(name)
+	if !ok {
+		return fmt.Errorf("failed to install operator %s: unknown operator", name)
+	}
+	ctx := context.Background()
+	if err := d.ensureCatalogSource(ctx); err != nil {
+		return fmt.Errorf("failed to install operator %s: %w", name, err)
+	}
+	if _, err := d.install(ctx, operator); err != nil {
+		return fmt.Errorf("failed to install operator %s: %w", name, err)
+	}
+	return nil
+}
+
+// ensureCatalogSource creates the configured catalog source, if any
+func (d *Dependency) ensureCatalogSource(ctx context.Context) error {
+	if d.CatalogSource == nil {
+		return nil
+	}
+	catalog := newCatalogSource(d.CatalogSource)
+	result, err := createResourceIfAbsent(ctx, d.client, catalog, types.NamespacedName{Name: catalog.Name, Namespace: catalog.Namespace})
+	if err != nil {
+		return err
+	}
+	log.Info("Catalog source applied", "Name", catalog.Name, "SourceType", catalog.Spec.SourceType, "Result", result)
+	return nil
+}
+
+// Uninstall removes the subscription, CSV and operator group of each
+// dependent operator installed by Install, and the namespaces Install created
+// for them. Operators reused from a cluster-wide install are left untouched.
+func (d *Dependency) Uninstall() error {
+	ctx := context.Background()
+	for _, operator := range d.operators {
+		if err := d.uninstall(ctx, operator); err != nil {
+			return fmt.Errorf("failed to uninstall operator %s: %w", operator.name, err)
+		}
+	}
+	return nil
+}
+
+func (d *Dependency) uninstall(ctx context.Context, operator operatorResource) error {
+	namespace := d.operatorNamespace(operator)
+
+	log.Info("Uninstalling operator", "Operator", operator.name, "Namespace", namespace)
+	for _, obj := range operator.rbacObjects(namespace) {
+		if err := deleteResourceIfPresent(ctx, d.client, obj); err != nil {
+			return err
+		}
+	}
+	if err := d.installBackend().unsubscribe(ctx, operator, namespace); err != nil {
+		return err
+	}
+	if !d.ownsNamespace(operator) {
+		log.Info("Keeping namespace not dedicated to the operator", "Operator", operator.name, "Namespace", namespace)
+		return nil
+	}
+	// the namespace may have existed before Install, which only labels the
+	// namespaces it creates
+	_, err := deleteIfLabelled(ctx, d.client, &corev1.Namespace{}, types.NamespacedName{Name: namespace}, dependencyLabels(operator.name))
+	return err
+}
+
+// ownsNamespace reports whether the namespace of the operator is dedicated to
+// it, i.e. prefixed rather than shared, overridden or the default namespace,
+// which may be used by other workloads
+func (d *Dependency) ownsNamespace(operator operatorResource) bool {
+	return d.prefix != "" && d.SharedNamespace == "" && operator.namespaceOverride == ""
+}
+
+// unsubscribe deletes the subscription, CSV and operator group of the
+// operator in namespace and, if enabled, waits for them to be gone.
+// Subscriptions and operator groups not created by Install are left
+// untouched, as is the CSV of a subscription left untouched.
+func (d *Dependency) unsubscribe(ctx context.Context, operator operatorResource, namespace string) error {
+	// read before the subscription is deleted, it may have upgraded the
+	// operator past its starting CSV
+	csv, err := d.installedCSV(ctx, operator, namespace)
+	if err != nil {
+		return err
+	}
+	subscription := types.NamespacedName{Name: operator.name, Namespace: namespace}
+	unsubscribed, err := deleteIfLabelled(ctx, d.client, &olmv1alpha1.Subscription{}, subscription, dependencyLabels(operator.name))
+	if err != nil {
+		return err
+	}
+	if unsubscribed {
+		if err := deleteResourceIfPresent(ctx, d.client, newClusterServiceVersion(csv, namespace)); err != nil {
+			return err
+		}
+	}
+	operatorGroup := types.NamespacedName{Name: operatorGroupName, Namespace: namespace}
+	if _, err := deleteIfLabelled(ctx, d.client, &operatorsv1.OperatorGroup{}, operatorGroup, map[string]string{managedByLabel: managedByValue}); err != nil {
+		return err
+	}
+	if unsubscribed && d.WaitForRemoval {
+		return d.waitForOperatorGone(ctx, operator, csv, namespace)
+	}
+	return nil
+}
+
+// install installs the operator and returns its CSV once it is ready
+func (d *Dependency) install(ctx context.Context, operator operatorResource) (string, error) {
+	csv, err := d.apply(ctx, operator)
+	if err != nil {
+		d.setCondition(ctx, operator, corev1.ConditionFalse, ReasonInstallFailed, err.Error())
+		return "", err
+	}
+	d.setInstalling(ctx, operator, csv)
+	d.progress(ProgressEvent{Operator: operator.name, Namespace: csv.Namespace, Stage: StageWaiting, CSV: csv.Name})
+	if err := d.installBackend().waitReady(ctx, operator, csv); err != nil {
+		d.setCondition(ctx, operator, corev1.ConditionFalse, failureReason(err), err.Error())
+		return "", err
+	}
+	d.setCondition(ctx, operator, corev1.ConditionTrue, ReasonCSVSucceeded, succeededMessage(csv))
+	d.progress(ProgressEvent{Operator: operator.name, Namespace: csv.Namespace, Stage: StageReady, CSV: csv.Name})
+	return csv.Name, nil
+}
+
+// apply creates the resources needed to install the operator, without
+// waiting for it, and returns the CSV, or ClusterExtension, to wait for
+func (d *Dependency) apply(ctx context.Context, operator operatorResource) (types.NamespacedName, error) {
+	reqLogger := log.WithValues("Operator", operator.name)
+
+	if d.ReuseClusterWide && d.Backend != BackendClusterExtension {
+		csv, err := d.clusterWideCSV(ctx, operator)
+		if err != nil {
+			return types.NamespacedName{}, fmt.Errorf("failed to look up cluster-wide Subscription: %w", err)
+		}
+		if csv != "" {
+			reqLogger.Info("Reusing cluster-wide operator install", "Namespace", clusterWideNamespace, "CSV", csv)
+			return types.NamespacedName{Name: csv, Namespace: clusterWideNamespace}, nil
+		}
+	}
+
+	namespace := d.operatorNamespace(operator)
+
+	if d.Backend != BackendClusterExtension {
+		if err := d.checkSubscriptionConflict(ctx, operator, namespace); err != nil {
+			return types.NamespacedName{}, err
+		}
+	}
+
+	reqLogger.Info("Installing operator", "Namespace", namespace)
+	if err := d.ensureNamespace(ctx, namespace, operator.name); err != nil {
+		return types.NamespacedName{}, fmt.Errorf("failed to ensure Namespace %s: %w", namespace, err)
+	}
+	d.progress(ProgressEvent{Operator: operator.name, Namespace: namespace, Stage: StageNamespaceCreated})
+	if err := d.ensurePullSecrets(ctx, namespace, operator.name); err != nil {
+		return types.NamespacedName{}, fmt.Errorf("failed to link pull secrets in namespace %s: %w", namespace, err)
+	}
+	waitFor, err := d.installBackend().subscribe(ctx, operator, namespace)
+	if err != nil {
+		return types.NamespacedName{}, err
+	}
+	for _, obj := range operator.rbacObjects(namespace) {
+		key, err := client.ObjectKeyFromObject(obj)
+		if err != nil {
+			return types.NamespacedName{}, fmt.Errorf("invalid %s: %w", kindOf(obj), err)
+		}
+		result, err := createResourceIfAbsent(ctx, d.client, obj, key)
+		if err != nil {
+			return types.NamespacedName{}, err
+		}
+		reqLogger.V(debugLevel).Info("RBAC resource applied", "Kind", kindOf(obj), "Name", keyString(key), "Result", result)
+	}
+	return waitFor, nil
+}
+
+// subscribe creates the operator group and subscription of the operator in
+// namespace and returns the CSV to wait for
+func (d *Dependency) subscribe(ctx context.Context, operator operatorResource, namespace string) (types.NamespacedName, error) {
+	reqLogger := log.WithValues("Operator", operator.name)
+	if err := d.ensureOperatorGroup(ctx, namespace, operator); err != nil {
+		return types.NamespacedName{}, err
+	}
+	channel := d.operatorChannel(operator)
+	subscription := newSubscription(operator.name, namespace, operator.packageName, channel)
+	reqLogger.V(debugLevel).Info("Resolved subscription channel", "Channel", channel, "Environment", d.Environment)
+	// pin the initial install to the CSV waited for rather than the channel head
+	subscription.Spec.StartingCSV = operator.csv
+	if operator.catalogSource != "" {
+		subscription.Spec.CatalogSource = operator.catalogSource
+	}
+	if operator.catalogSourceNamespace != "" {
+		subscription.Spec.CatalogSourceNamespace = operator.catalogSourceNamespace
+	}
+	if d.CatalogSource != nil {
+		subscription.Spec.CatalogSource = d.CatalogSource.Name
+		subscription.Spec.CatalogSourceNamespace = d.CatalogSource.namespace()
+	}
+	approval := d.InstallPlanApproval[operator.name]
+	subscription.Spec.InstallPlanApproval = approval
+	result, err := createResourceIfAbsent(ctx, d.client, subscription, types.NamespacedName{Name: operator.name, Namespace: namespace})
+	if err != nil {
+		return types.NamespacedName{}, err
+	}
+	reqLogger.Info("Subscription applied", "Namespace", namespace, "Result", result)
+	d.progress(ProgressEvent{Operator: operator.name, Namespace: namespace, Stage: StageSubscriptionCreated})
+	if approval == olmv1alpha1.ApprovalManual {
+		if err := d.approveInitialInstallPlan(ctx, operator.name, namespace); err != nil {
+			return types.NamespacedName{}, err
+		}
+	}
+	return types.NamespacedName{Name: operator.csv, Namespace: namespace}, nil
+}
+
+// OperatorVersion reports the CSV installed for an operator the installer
+// manages, alongside the CSV the installer expects
+type OperatorVersion struct {
+	Operator    string
+	Namespace   string
+	ExpectedCSV string
+	// InstalledCSV is empty if no CSV was found for the operator
+	InstalledCSV string
+	Version      string
+	Phase        olmv1alpha1.ClusterServiceVersionPhase
+}
+
+// InstalledVersions lists the CSV found for each configured operator, so that
+// drift from the expected versions, e.g. after a manual upgrade, can be detected
+func (d *Dependency) InstalledVersions() ([]OperatorVersion, error) {
+	ctx := context.Background()
+	versions := []OperatorVersion{}
+	for _, operator := range d.operators {
+		version, err := d.installedVersion(ctx, operator)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// IsInstalled reports whether the CSV of every operator is present and has
+// succeeded, without creating anything, so that callers can skip Install
+func (d *Dependency) IsInstalled() (bool, error) {
+	ctx := context.Background()
+	for _, operator := range d.operators {
+		version, err := d.installedVersion(ctx, operator)
+		if err != nil {
+			return false, err
+		}
+		if version.InstalledCSV == "" || version.Phase != olmv1alpha1.CSVPhaseSucceeded {
+			return false, nil
+		}
+		if version.Namespace == clusterWideNamespace && d.ReuseClusterWide {
+			continue
+		}
+		missing, err := d.missingComponents(ctx, operator, version.Namespace)
+		if err != nil {
+			return false, err
+		}
+		if len(missing) > 0 {
+			log.Info("Operator partially installed", "Operator", operator.name, "Namespace", version.Namespace, "Missing", missing)
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// missingComponents returns the kinds of the resources created by Install for
+// the operator in namespace which don't exist, e.g. because they were deleted
+// manually. A ready CSV doesn't mean that the operator is fully installed, it
+// is not upgraded anymore without its Subscription.
+func (d *Dependency) missingComponents(ctx context.Context, operator operatorResource, namespace string) ([]string, error) {
+	missing := []string{}
+	for _, component := range []struct {
+		obj runtime.Object
+		key types.NamespacedName
+	}{
+		{&corev1.Namespace{}, types.NamespacedName{Name: namespace}},
+		{&operatorsv1.OperatorGroup{}, types.NamespacedName{Name: operatorGroupName, Namespace: namespace}},
+		{&olmv1alpha1.Subscription{}, types.NamespacedName{Name: operator.name, Namespace: namespace}},
+	} {
+		err := d.client.Get(ctx, component.key, component.obj)
+		if errors.IsNotFound(err) {
+			missing = append(missing, kindOf(component.obj))
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s %s: %w", kindOf(component.obj), keyString(component.key), err)
+		}
+	}
+	return missing, nil
+}
+
+func (d *Dependency) installedVersion(ctx context.Context, operator operatorResource) (OperatorVersion, error) {
+	namespace := d.operatorNamespace(operator)
+	name := ""
+	if d.ReuseClusterWide {
+		csv, err := d.clusterWideCSV(ctx, operator)
+		if err != nil {
+			return OperatorVersion{}, err
+		}
+		if csv != "" {
+			namespace, name = clusterWideNamespace, csv
+		}
+	}
+	if name == "" {
+		csv, err := d.installedCSV(ctx, operator, namespace)
+		if err != nil {
+			return OperatorVersion{}, err
+		}
+		name = csv
+	}
+
+	version := OperatorVersion{Operator: operator.name, Namespace: namespace, ExpectedCSV: operator.csv}
+	csv, err := getCSV(ctx, d.client, name, namespace)
+	if err != nil {
+		return OperatorVersion{}, err
+	}
+	if csv != nil {
+		version.InstalledCSV = csv.Name
+		version.Version = csv.Spec.Version.String()
+		version.Phase = csv.Status.Phase
+	}
+	return version, nil
+}
+
+// installedCSV returns the CSV installed by the subscription of the operator
+// in namespace, or its starting CSV if the subscription doesn't report one
+func (d *Dependency) installedCSV(ctx context.Context, operator operatorResource, namespace string) (string, error) {
+	subscription := &olmv1alpha1.Subscription{}
+	err := d.client.Get(ctx, types.NamespacedName{Name: operator.name, Namespace: namespace}, subscription)
+	if err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	if subscription.Status.InstalledCSV != "" {
+		return subscription.Status.InstalledCSV, nil
+	}
+	return operator.csv, nil
+}
+
+// approveInitialInstallPlan waits for OLM to create the install plan of a
+// subscription with Manual approval and approves it, unless the subscription
+// has already installed a CSV
+func (d *Dependency) approveInitialInstallPlan(ctx context.Context, name, namespace string) error {
+	err := pollWithBackoff(ctx, pollBackoff, d.timeout, func() (bool, error) {
+		subscription := &olmv1alpha1.Subscription{}
+		if err := d.client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, subscription); err != nil {
+			return false, err
+		}
+		if subscription.Status.InstalledCSV != "" {
+			return true, nil
+		}
+		ref := subscription.Status.InstallPlanRef
+		if ref == nil {
+			return false, nil
+		}
+		plan := &olmv1alpha1.InstallPlan{}
+		if err := d.client.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: namespace}, plan); err != nil {
+			if errors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		if plan.Spec.Approved {
+			return true, nil
+		}
+		log.Info("Approving initial install plan", "InstallPlan", plan.Name, "Namespace", namespace, "CSVs", plan.Spec.ClusterServiceVersionNames)
+		// patched rather than updated as OLM keeps updating the plan status
+		patch := client.MergeFrom(plan.DeepCopy())
+		plan.Spec.Approved = true
+		return true, d.client.Patch(ctx, plan, patch)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to approve the initial InstallPlan of Subscription %s: %w", name, err)
+	}
+	return nil
+}
+
+// ensureNamespace creates the operator namespace with the configured labels,
+// or adds the missing labels to it if it exists and patching is enabled
+func (d *Dependency) ensureNamespace(ctx context.Context, name, operator string) error {
+	namespace := newNamespace(name, operator)
+	namespace.Labels = mergeLabels(d.NamespaceLabels, namespace.Labels)
+
+	existing := &corev1.Namespace{}
+	err := d.client.Get(ctx, types.NamespacedName{Name: name}, existing)
+	if errors.IsNotFound(err) {
+		err = d.client.Create(ctx, namespace)
+		if err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !d.PatchExistingNamespaces {
+		return nil
+	}
+
+	patch := client.MergeFrom(existing.DeepCopy())
+	missing := false
+	for k, v := range d.NamespaceLabels {
+		if _, ok := existing.Labels[k]; ok {
+			continue
+		}
+		if existing.Labels == nil {
+			existing.Labels = map[string]string{}
+		}
+		existing.Labels[k] = v
+		missing = true
+	}
+	if !missing {
+		return nil
+	}
+	log.Info("Adding missing labels to existing namespace", "Namespace", name)
+	return d.client.Patch(ctx, existing, patch)
+}
+
+// ensureOperatorGroup creates the operator group of the operator, or restores
+// its target namespaces if they were edited since, as the operator may stop
+// working otherwise. Operator groups not created by Install are left untouched.
+func (d *Dependency) ensureOperatorGroup(ctx context.Context, namespace string, operator operatorResource) error {
+	targets := operator.operatorGroupTargets(namespace)
+	if d.SharedNamespace != "" {
+		// the operators share a single operator group, watching the shared
+		// namespace whatever their install modes
+		targets = []string{namespace}
+	}
+	operatorGroup := newOperatorGroup(namespace, operator.name, targets)
+	key := types.NamespacedName{Name: operatorGroupName, Namespace: namespace}
+
+	existing := &operatorsv1.OperatorGroup{}
+	err := d.client.Get(ctx, key, existing)
+	if errors.IsNotFound(err) {
+		_, err := createResourceIfAbsent(ctx, d.client, operatorGroup, key)
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get %s %s: %w", kindOf(operatorGroup), keyString(key), err)
+	}
+	if existing.Labels[managedByLabel] != managedByValue ||
+		sets.NewString(existing.Spec.TargetNamespaces...).Equal(sets.NewString(operatorGroup.Spec.TargetNamespaces...)) {
+		return nil
+	}
+
+	log.Info("Restoring the target namespaces of the operator group", "OperatorGroup", keyString(key), "Found", existing.Spec.TargetNamespaces, "Want", operatorGroup.Spec.TargetNamespaces)
+	patch := client.MergeFrom(existing.DeepCopy())
+	existing.Spec.TargetNamespaces = operatorGroup.Spec.TargetNamespaces
+	if err := d.client.Patch(ctx, existing, patch); err != nil {
+		return fmt.Errorf("failed to update %s %s: %w", kindOf(operatorGroup), keyString(key), err)
+	}
+	return nil
+}
+
+// clusterWideCSV returns the CSV of the operator if it has been subscribed to
+// in openshift-operators, or an empty string if it has not
+func (d *Dependency) clusterWideCSV(ctx context.Context, operator operatorResource) (string, error) {
+	subscriptions := &olmv1alpha1.SubscriptionList{}
+	if err := d.client.List(ctx, subscriptions, client.InNamespace(clusterWideNamespace)); err != nil {
+		return "", err
+	}
+	for _, subscription := range subscriptions.Items {
+		// the spec may not be populated yet, e.g. while it is being created
+		if subscription.Spec == nil || subscription.Spec.Package != operator.packageName {
+			continue
+		}
+		if subscription.Status.InstalledCSV != "" {
+			return subscription.Status.InstalledCSV, nil
+		}
+		if subscription.Status.CurrentCSV != "" {
+			return subscription.Status.CurrentCSV, nil
+		}
+		return operator.csv, nil
+	}
+	return "", nil
+}
+
+// checkSubscriptionConflict returns a *SubscriptionConflictError if the package
+// of the operator is already subscribed to outside of its namespace, or on
+// another channel, e.g. by another tool, as OLM would not install it twice
+func (d *Dependency) checkSubscriptionConflict(ctx context.Context, operator operatorResource, namespace string) error {
+	subscriptions := &olmv1alpha1.SubscriptionList{}
+	if err := d.client.List(ctx, subscriptions); err != nil {
+		return fmt.Errorf("failed to list Subscriptions: %w", err)
+	}
+	for _, subscription := range subscriptions.Items {
+		if subscription.Spec == nil || subscription.Spec.Package != operator.packageName {
+			continue
+		}
+		if subscription.Namespace == namespace && subscription.Spec.Channel == d.operatorChannel(operator) {
+			continue
+		}
+		return &SubscriptionConflictError{
+			Package:       operator.packageName,
+			Name:          subscription.Name,
+			Namespace:     subscription.Namespace,
+			Channel:       subscription.Spec.Channel,
+			WantNamespace: namespace,
+			WantChannel:   d.operatorChannel(operator),
+		}
+	}
+	return nil
+}
+
+// SubscriptionConflictError is returned by Install when the package of an
+// operator is already subscribed to in another namespace or on another
+// channel. The existing Subscription has to be removed or aligned by hand.
+type SubscriptionConflictError struct {
+	Package string
+	// Name, Namespace and Channel describe the existing Subscription
+	Name      string
+	Namespace string
+	Channel   string
+	// WantNamespace and WantChannel are those of the Subscription Install creates
+	WantNamespace string
+	WantChannel   string
+}
+
+func (e *SubscriptionConflictError) Error() string {
+	return fmt.Sprintf("package %s is already subscribed to by Subscription %s in namespace %s on channel %s, conflicting with channel %s in namespace %s",
+		e.Package, e.Name, e.Namespace, e.Channel, e.WantChannel, e.WantNamespace)
+}
+
+// operatorChannel returns the channel the operator is subscribed to in the
+// environment of the cluster
+func (d *Dependency) operatorChannel(operator operatorResource) string {
+	if channel, ok := operator.channels[d.Environment]; ok && d.Environment != "" {
+		return channel
+	}
+	return operator.channel
+}
+
+// Namespaces returns the namespaces the configured operators are installed
+// into, once each and in the order of the operators, e.g. to set up RBAC
+// for them before Install
+func (d *Dependency) Namespaces() []string {
+	namespaces := []string{}
+	seen := map[string]bool{}
+	for _, operator := range d.operators {
+		namespace := d.operatorNamespace(operator)
+		if !seen[namespace] {
+			seen[namespace] = true
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces
+}
+
+// operatorNamespace returns the namespace the operator is installed into
+func (d *Dependency) operatorNamespace(operator operatorResource) string {
+	if d.SharedNamespace != "" {
+		return d.SharedNamespace
+	}
+	if operator.namespaceOverride != "" {
+		return operator.namespaceOverride
+	}
+	return d.addPrefixIfNecessary(operator.namespace)
+}
+
+func (d *Dependency) addPrefixIfNecessary(namespace string) string {
+	if d.prefix == "" {
+		return namespace
+	}
+	return fmt.Sprintf("%s-%s", d.prefix, namespace)
+}
+
+// waitForOperator waits for the CSV installed by the subscription of the
+// operator to succeed, failing fast if the subscription reports that it
+// cannot be installed
+func (d *Dependency) waitForOperator(ctx context.Context, operator operatorResource, csv, namespace string) error {
+	timeout := d.timeout
+	if operator.timeout > 0 {
+		timeout = operator.timeout
+	}
+	log.Info("Waiting for operator to be ready", "CSV", csv, "Namespace", namespace, "Timeout", timeout)
+	status := &csvStatus{subscription: operator.name, failedPhaseTolerance: d.FailedPhaseTolerance}
+	if err := status.wait(ctx, d.client, csv, namespace, timeout); err != nil {
+		return fmt.Errorf("ClusterServiceVersion %s not ready: %w", csv, err)
+	}
+	return nil
+}
+
+// waitForOperatorGone is the counterpart of waitForOperator once the operator
+// is uninstalled, waiting for its subscription and csv to be NotFound
+func (d *Dependency) waitForOperatorGone(ctx context.Context, operator operatorResource, csv, namespace string) error {
+	log.Info("Waiting for operator to be removed", "Operator", operator.name, "Namespace", namespace, "Timeout", d.timeout)
+	remaining := []string{}
+	err := pollWithBackoff(ctx, pollBackoff, d.timeout, func() (bool, error) {
+		remaining = remaining[:0]
+		for name, obj := range map[string]runtime.Object{
+			operator.name: &olmv1alpha1.Subscription{},
+			csv:           &olmv1alpha1.ClusterServiceVersion{},
+		} {
+			err := d.client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, obj)
+			if errors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return false, err
+			}
+			remaining = append(remaining, kindOf(obj)+" "+name)
+		}
+		return len(remaining) == 0, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		sort.Strings(remaining)
+		return fmt.Errorf("timed out waiting for operator %s to be removed from namespace %s, remaining: %s: %w", operator.name, namespace, strings.Join(remaining, ", "), err)
+	}
+	return err
+}
+
+// waitForCSV waits for the CSV to succeed. If it times out, the error
+// reports the last observed phase and reason of the CSV.
+func waitForCSV(ctx context.Context, c client.Client, name, namespace string, timeout time.Duration) error {
+	return (&csvStatus{}).wait(ctx, c, name, namespace, timeout)
+}
+
+// wait is waitForCSV applying the settings of the status, e.g. also checking
+// the conditions of its subscription
+func (s *csvStatus) wait(ctx context.Context, c client.Client, name, namespace string, timeout time.Duration) error {
+	err := pollWithBackoff(ctx, pollBackoff, timeout, s.isOperatorReady(ctx, c, name, namespace))
+	if err == wait.ErrWaitTimeout {
+		return &TimeoutError{CSV: name, Namespace: namespace, Found: s.found, Phase: s.phase, Reason: s.reason, Message: s.message}
+	}
+	return err
+}
+
+// pollWithBackoff checks condition immediately and then after each backoff
+// step until it is done, timeout elapses or ctx is cancelled
+func pollWithBackoff(ctx context.Context, backoff wait.Backoff, timeout time.Duration, condition wait.ConditionFunc) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		done, err := condition()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return wait.ErrWaitTimeout
+		}
+		delay := backoff.Step()
+		if delay > remaining {
+			delay = remaining
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// TimeoutError is returned when a CSV does not succeed in time
+type TimeoutError struct {
+	CSV       string
+	Namespace string
+	// Found is set if the CSV exists, its Phase is empty until OLM reconciles it
+	Found   bool
+	Phase   olmv1alpha1.ClusterServiceVersionPhase
+	Reason  olmv1alpha1.ConditionReason
+	Message string
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Phase == "" && e.Found {
+		return fmt.Sprintf("timed out waiting for CSV %s in namespace %s: CSV has no phase yet", e.CSV, e.Namespace)
+	}
+	if e.Phase == "" {
+		return fmt.Sprintf("timed out waiting for CSV %s in namespace %s: CSV not found", e.CSV, e.Namespace)
+	}
+	return fmt.Sprintf("timed out waiting for CSV %s in namespace %s: phase %s, reason %s: %s", e.CSV, e.Namespace, e.Phase, e.Reason, e.Message)
+}
+
+// Unwrap allows the error to be matched against wait.ErrWaitTimeout
+func (e *TimeoutError) Unwrap() error {
+	return wait.ErrWaitTimeout
+}
+
+// WaitForAll polls all the CSVs concurrently until they have all succeeded or
+// the shared timeout elapses. If some of them are not ready in time, the
+// returned *NotReadyError lists them.
+func WaitForAll(c client.Client, csvs []types.NamespacedName, timeout time.Duration) error {
+	return waitForAll(context.Background(), c, csvs, nil, timeout, 0)
+}
+
+// waitForAll is like WaitForAll, subscriptions, if set, name the subscription
+// installing each of the CSVs so that resolution failures are detected before
+// the CSVs exist. Waiting stops for all the CSVs as soon as one of them fails.
+func waitForAll(ctx context.Context, c client.Client, csvs []types.NamespacedName, subscriptions []string, timeout time.Duration, failedPhaseTolerance int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var failOnce sync.Once
+	var failed error
+	errs := make([]error, len(csvs))
+	var wg sync.WaitGroup
+	for i, csv := range csvs {
+		status := &csvStatus{failedPhaseTolerance: failedPhaseTolerance}
+		if subscriptions != nil {
+			status.subscription = subscriptions[i]
+		}
+		wg.Add(1)
+		go func(i int, csv types.NamespacedName, status *csvStatus) {
+			defer wg.Done()
+			err := status.wait(ctx, c, csv.Name, csv.Namespace, timeout)
+			if _, ok := err.(*TimeoutError); err != nil && !ok {
+				failOnce.Do(func() {
+					failed = err
+					cancel()
+				})
+			}
+			errs[i] = err
+		}(i, csv, status)
+	}
+	wg.Wait()
+	if failed != nil {
+		return failed
+	}
+
+	notReady := &NotReadyError{}
+	for _, err := range errs {
+		if timeoutErr, ok := err.(*TimeoutError); ok {
+			notReady.Timeouts = append(notReady.Timeouts, timeoutErr)
+		}
+	}
+	if len(notReady.Timeouts) > 0 {
+		return notReady
+	}
+	return nil
+}
+
+// NotReadyError is returned by WaitForAll when some CSVs did not succeed in time
+type NotReadyError struct {
+	Timeouts []*TimeoutError
+}
+
+func (e *NotReadyError) Error() string {
+	msgs := make([]string, len(e.Timeouts))
+	for i, err := range e.Timeouts {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d operator(s) not ready: %s", len(e.Timeouts), strings.Join(msgs, "; "))
+}
+
+// Unwrap allows the error to be matched against wait.ErrWaitTimeout
+func (e *NotReadyError) Unwrap() error {
+	return wait.ErrWaitTimeout
+}
+
+// IsTimeout returns true if err, or an error it wraps, was caused by an
+// operator not becoming ready in time
+func IsTimeout(err error) bool {
+	return goerrors.Is(err, wait.ErrWaitTimeout)
+}
+
+// csvStatus records the last observed status of a CSV while waiting for it
+type csvStatus struct {
+	// found is false while the CSV doesn't exist, e.g. before OLM creates it
+	// or while it is replaced during an upgrade
+	found        bool
+	checked      bool
+	phase        olmv1alpha1.ClusterServiceVersionPhase
+	reason       olmv1alpha1.ConditionReason
+	message      string
+	unknownPolls int
+
+	// subscription, if set, is the name of the subscription installing the
+	// CSV, whose conditions reveal resolution failures before the CSV exists
+	subscription string
+
+	// failedPhaseTolerance is the number of consecutive polls the CSV may be
+	// Failed before giving up, failedPolls counts them
+	failedPhaseTolerance int
+	failedPolls          int
+}
+
+func isOperatorReady(ctx context.Context, c client.Client, name, namespace string) wait.ConditionFunc {
+	return (&csvStatus{}).isOperatorReady(ctx, c, name, namespace)
+}
+
+func (s *csvStatus) isOperatorReady(ctx context.Context, c client.Client, name, namespace string) wait.ConditionFunc {
+	return func() (bool, error) {
+		if s.subscription != "" {
+			if err := checkSubscription(ctx, c, s.subscription, namespace); err != nil {
+				return false, err
+			}
+		}
+		log.V(traceLevel).Info("Checking CSV", "CSV", name, "Namespace", namespace)
+		csv, err := getCSV(ctx, c, name, namespace)
+		if err != nil {
+			return false, err
+		}
+		if csv == nil {
+			s.notFound(name, namespace)
+			return false, nil
+		}
+
+		// Operators installed in AllNamespaces mode have their CSV copied into
+		// every namespace, the copies do not reflect the install status
+		if csv.IsCopied() {
+			original := copiedFromNamespace(csv)
+			if original == "" || original == namespace {
+				return false, nil
+			}
+			csv, err = getCSV(ctx, c, name, original)
+			if err != nil || (csv != nil && csv.IsCopied()) {
+				return false, err
+			}
+			if csv == nil {
+				s.notFound(name, original)
+				return false, nil
+			}
+		}
+
+		if !s.found || s.phase != csv.Status.Phase {
+			log.Info("CSV phase changed", "CSV", name, "Namespace", csv.Namespace, "Phase", csv.Status.Phase, "Reason", csv.Status.Reason)
+		}
+		s.found, s.checked = true, true
+		s.phase, s.reason, s.message = csv.Status.Phase, csv.Status.Reason, csv.Status.Message
+
+		if csv.Status.Phase != olmv1alpha1.CSVPhaseFailed {
+			s.failedPolls = 0
+		}
+		switch csv.Status.Phase {
+		case olmv1alpha1.CSVPhaseFailed:
+			s.failedPolls++
+			if s.failedPolls > s.failedPhaseTolerance {
+				return false, fmt.Errorf("operator installation failed: %s", csv.Status.Reason)
+			}
+			log.Info("CSV failed, waiting for OLM to retry", "CSV", name, "Namespace", csv.Namespace, "FailedPolls", s.failedPolls, "Tolerance", s.failedPhaseTolerance)
+		case olmv1alpha1.CSVPhaseSucceeded:
+			return true, nil
+		case "":
+			// OLM has created the CSV but not reconciled it yet, unlike the
+			// Installing phase nothing has been deployed
+			return false, nil
+		case olmv1alpha1.CSVPhaseUnknown:
+			s.unknownPolls++
+			if unknownPhaseLimit > 0 && s.unknownPolls >= unknownPhaseLimit {
+				return false, fmt.Errorf("operator installation in unknown phase: %s: %s", csv.Status.Reason, csv.Status.Message)
+			}
+		}
+		return false, nil
+	}
+}
+
+// checkSubscription returns an error if the subscription reports that its
+// operator cannot be installed. A missing subscription, e.g. when a cluster-wide
+// install is reused, is not an error.
+func checkSubscription(ctx context.Context, c client.Client, name, namespace string) error {
+	subscription := &olmv1alpha1.Subscription{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, subscription); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	for _, condition := range subscription.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case subscriptionResolutionFailed, olmv1alpha1.SubscriptionInstallPlanFailed:
+			return fmt.Errorf("Subscription %s in namespace %s cannot be installed: %s: %s: %s", name, namespace, condition.Type, condition.Reason, condition.Message)
+		case olmv1alpha1.SubscriptionCatalogSourcesUnhealthy:
+			// catalog sources are commonly unhealthy for a while after they
+			// are created, keep waiting in case they recover
+			log.V(debugLevel).Info("Subscription catalog sources unhealthy", "Subscription", name, "Namespace", namespace, "Reason", condition.Reason, "Message", condition.Message)
+		}
+	}
+	return nil
+}
+
+// notFound records that the CSV doesn't exist, forgetting the phase it had if
+// it was deleted, e.g. when replaced during an upgrade
+func (s *csvStatus) notFound(name, namespace string) {
+	if s.found {
+		log.Info("CSV deleted, waiting for it to be recreated", "CSV", name, "Namespace", namespace, "LastPhase", s.phase)
+	} else if !s.checked {
+		log.Info("CSV not found, waiting for OLM to create it", "CSV", name, "Namespace", namespace)
+	}
+	s.found, s.checked = false, true
+	s.phase, s.reason, s.message = "", "", ""
+}
+
+// getCSV returns the named CSV, or nil if it does not exist yet
+func getCSV(ctx context.Context, c client.Client, name, namespace string) (*olmv1alpha1.ClusterServiceVersion, error) {
+	csv := &olmv1alpha1.ClusterServiceVersion{}
+	err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, csv)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return csv, nil
+}
+
+// copiedFromNamespace returns the namespace of the original CSV a copied CSV was created from
+func copiedFromNamespace(csv *olmv1alpha1.ClusterServiceVersion) string {
+	if namespace, ok := csv.Labels[olmv1alpha1.CopiedLabelKey]; ok {
+		return namespace
+	}
+	if namespace, ok := csv.Annotations[olmv1alpha1.CopiedLabelKey]; ok {
+		return namespace
+	}
+	return csv.Annotations[olmv1alpha1.OperatorGroupNamespaceAnnotationKey]
+}
+
+// createResourceIfAbsent creates obj unless a resource with the given key
+// already exists, and returns whether it was created
+func createResourceIfAbsent(ctx context.Context, c client.Client, obj runtime.Object, key types.NamespacedName) (controllerutil.OperationResult, error) {
+	err := c.Get(ctx, key, obj.DeepCopyObject())
+	if err == nil {
+		return controllerutil.OperationResultNone, nil
+	}
+	if !errors.IsNotFound(err) {
+		return controllerutil.OperationResultNone, fmt.Errorf("failed to get %s %s: %w", kindOf(obj), keyString(key), err)
+	}
+	err = c.Create(ctx, obj)
+	if errors.IsAlreadyExists(err) {
+		// created concurrently since the Get
+		return controllerutil.OperationResultNone, nil
+	}
+	if err != nil {
+		return controllerutil.OperationResultNone, fmt.Errorf("failed to create %s %s: %w", kindOf(obj), keyString(key), err)
+	}
+	return controllerutil.OperationResultCreated, nil
+}
+
+// deleteResourceIfPresent deletes obj, ignoring it if it does not exist
+func deleteResourceIfPresent(ctx context.Context, c client.Client, obj runtime.Object) error {
+	err := c.Delete(ctx, obj)
+	if err != nil && !errors.IsNotFound(err) {
+		key, _ := client.ObjectKeyFromObject(obj)
+		return fmt.Errorf("failed to delete %s %s: %w", kindOf(obj), keyString(key), err)
+	}
+	return nil
+}
+
+// deleteIfLabelled deletes the object found at key if it has all the labels,
+// ignoring it if it does not exist, and reports whether it was deleted
+func deleteIfLabelled(ctx context.Context, c client.Client, obj runtime.Object, key types.NamespacedName, labels map[string]string) (bool, error) {
+	if err := c.Get(ctx, key, obj); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get %s %s: %w", kindOf(obj), keyString(key), err)
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false, err
+	}
+	for k, v := range labels {
+		if accessor.GetLabels()[k] != v {
+			log.Info("Keeping resource not created by Install", "Kind", kindOf(obj), "Name", keyString(key))
+			return false, nil
+		}
+	}
+	if err := deleteResourceIfPresent(ctx, c, obj); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// keyString formats the key as namespace/name, or name for cluster-scoped resources
+func keyString(key types.NamespacedName) string {
+	if key.Namespace == "" {
+		return key.Name
+	}
+	return key.String()
+}
+
+// kindOf returns the kind of a typed object, e.g. Subscription, for error
+// messages, or the kind set on an unstructured one
+func kindOf(obj runtime.Object) string {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.GetKind()
+	}
+	return reflect.Indirect(reflect.ValueOf(obj)).Type().Name()
+}
+
+// mergeLabels returns labels with extra added to them
+func mergeLabels(labels, extra map[string]string) map[string]string {
+	merged := map[string]string{}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	for k, v := range extra {
+		merged[k] = v
+	}
+	return merged
+}
+
+// dependencyLabels returns the labels of the resources created for operator
+func dependencyLabels(operator string) map[string]string {
+	return map[string]string{
+		managedByLabel:  managedByValue,
+		dependencyLabel: operator,
+	}
+}
+
+func newNamespace(name, operator string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: dependencyLabels(operator),
+		},
+	}
+}
+
+func newOperatorGroup(namespace, operator string, targetNamespaces []string) *operatorsv1.OperatorGroup {
+	return &operatorsv1.OperatorGroup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      operatorGroupName,
+			Namespace: namespace,
+			Labels:    dependencyLabels(operator),
+		},
+		Spec: operatorsv1.OperatorGroupSpec{
+			TargetNamespaces: targetNamespaces,
+		},
+	}
+}
+
+func newSubscription(name, namespace, packageName, channel string) *olmv1alpha1.Subscription {
+	return &olmv1alpha1.Subscription{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    dependencyLabels(name),
+		},
+		Spec: &olmv1alpha1.SubscriptionSpec{
+			CatalogSource:          catalogSource,
+			CatalogSourceNamespace: catalogSourceNamespace,
+			Package:                packageName,
+			Channel:                channel,
+		},
+	}
+}
+
+func newClusterServiceVersion(name, namespace string) *olmv1alpha1.ClusterServiceVersion {
+	return &olmv1alpha1.ClusterServiceVersion{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+}
+
+func newCatalogSource(config *CatalogSourceConfig) *olmv1alpha1.CatalogSource {
+	sourceType := config.SourceType
+	if sourceType == "" {
+		sourceType = olmv1alpha1.SourceTypeGrpc
+	}
+	return &olmv1alpha1.CatalogSource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      config.Name,
+			Namespace: config.namespace(),
+			Labels: map[string]string{
+				managedByLabel: managedByValue,
+			},
+		},
+		Spec: olmv1alpha1.CatalogSourceSpec{
+			SourceType: sourceType,
+			Image:      config.Image,
+			Address:    config.Address,
+			ConfigMap:  config.ConfigMap,
+		},
+	}
+}
@@ -0,0 +1,1728 @@
+package dependency
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/operator-framework/api/pkg/lib/version"
+	operatorsv1 "github.com/operator-framework/api/pkg/operators/v1"
+	olmv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	pipelinesv1alpha1 "github.com/redhat-developer/gitops-operator/pkg/apis/pipelines/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestInstall_reuses_cluster_wide_operator(t *testing.T) {
+	operator := newArgoCDOperator("")
+	fakeClient := newFakeClient(t,
+		newClusterWideSubscription(operator.name, "argocd-operator.v0.0.14"),
+		newCSV("argocd-operator.v0.0.14", clusterWideNamespace, olmv1alpha1.CSVPhaseSucceeded),
+	)
+	d := newTestDependency(fakeClient, "")
+	d.ReuseClusterWide = true
+
+	_, err := d.install(context.TODO(), operator)
+	assertNoError(t, err)
+
+	assertNotFound(t, fakeClient, types.NamespacedName{Name: operator.namespace}, &corev1.Namespace{})
+	assertNotFound(t, fakeClient, types.NamespacedName{Name: operatorGroupName, Namespace: operator.namespace}, &operatorsv1.OperatorGroup{})
+	assertNotFound(t, fakeClient, types.NamespacedName{Name: operator.name, Namespace: operator.namespace}, &olmv1alpha1.Subscription{})
+}
+
+func TestInstall_cluster_wide_subscription_without_spec(t *testing.T) {
+	operator := newArgoCDOperator("")
+	partial := newClusterWideSubscription("pending-operator", "")
+	partial.Spec = nil
+	partial.Status.State = olmv1alpha1.SubscriptionStateUpgradePending
+	fakeClient := newFakeClient(t,
+		partial,
+		newCSV("pending-operator.v0.0.1", clusterWideNamespace, olmv1alpha1.CSVPhasePending),
+		newCSV(operator.csv, operator.namespace, olmv1alpha1.CSVPhaseSucceeded),
+	)
+	d := newTestDependency(fakeClient, "")
+	d.ReuseClusterWide = true
+
+	_, err := d.install(context.TODO(), operator)
+	assertNoError(t, err)
+
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: operator.name, Namespace: operator.namespace}, &olmv1alpha1.Subscription{}))
+}
+
+func TestInstall_cluster_wide_operator_conflicts_when_reuse_disabled(t *testing.T) {
+	operator := newArgoCDOperator("")
+	fakeClient := newFakeClient(t,
+		newClusterWideSubscription(operator.name, "argocd-operator.v0.0.14"),
+		newCSV("argocd-operator.v0.0.14", clusterWideNamespace, olmv1alpha1.CSVPhaseSucceeded),
+	)
+	d := newTestDependency(fakeClient, "test")
+
+	_, err := d.install(context.TODO(), operator)
+	conflict := &SubscriptionConflictError{}
+	if !goerrors.As(err, &conflict) {
+		t.Fatalf("got error %v, want a SubscriptionConflictError", err)
+	}
+	want := "package argocd-operator is already subscribed to by Subscription argocd-operator in namespace openshift-operators on channel alpha, conflicting with channel alpha in namespace test-argocd"
+	if err.Error() != want {
+		t.Fatalf("got error %q, want %q", err, want)
+	}
+	// nothing is created until the conflict is resolved
+	if err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: "test-argocd"}, &corev1.Namespace{}); !errors.IsNotFound(err) {
+		t.Fatalf("got error %v, want NotFound", err)
+	}
+}
+
+func TestInstall_subscription_channel_conflict(t *testing.T) {
+	operator := newArgoCDOperator("")
+	fakeClient := newFakeClient(t, newSubscription(operator.name, "argocd", operator.packageName, "stable"))
+	d := newTestDependency(fakeClient, "")
+
+	_, err := d.install(context.TODO(), operator)
+	conflict := &SubscriptionConflictError{}
+	if !goerrors.As(err, &conflict) {
+		t.Fatalf("got error %v, want a SubscriptionConflictError", err)
+	}
+	want := SubscriptionConflictError{Package: operator.packageName, Name: operator.name, Namespace: "argocd", Channel: "stable", WantNamespace: "argocd", WantChannel: "alpha"}
+	if diff := cmp.Diff(want, *conflict); diff != "" {
+		t.Fatalf("conflict mismatch: %v", diff)
+	}
+}
+
+func TestInstall_cluster_wide_operator_not_ready(t *testing.T) {
+	operator := newArgoCDOperator("")
+	fakeClient := newFakeClient(t,
+		newClusterWideSubscription(operator.name, "argocd-operator.v0.0.14"),
+		newCSV("argocd-operator.v0.0.14", clusterWideNamespace, olmv1alpha1.CSVPhaseFailed),
+	)
+	d := newTestDependency(fakeClient, "")
+	d.ReuseClusterWide = true
+
+	_, err := d.install(context.TODO(), operator)
+	if err == nil {
+		t.Fatal("was expecting an error, but got nil")
+	}
+}
+
+func TestInstall_catalog_source(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        *CatalogSourceConfig
+		want          olmv1alpha1.CatalogSourceSpec
+		wantNamespace string
+	}{
+		{
+			name:          "grpc by default",
+			config:        &CatalogSourceConfig{Name: "gitops-catalog", Image: "quay.io/example/index:latest"},
+			want:          olmv1alpha1.CatalogSourceSpec{SourceType: olmv1alpha1.SourceTypeGrpc, Image: "quay.io/example/index:latest"},
+			wantNamespace: catalogSourceNamespace,
+		},
+		{
+			name:          "configmap",
+			config:        &CatalogSourceConfig{Name: "gitops-catalog", SourceType: olmv1alpha1.SourceTypeConfigmap, ConfigMap: "gitops-catalog-data"},
+			want:          olmv1alpha1.CatalogSourceSpec{SourceType: olmv1alpha1.SourceTypeConfigmap, ConfigMap: "gitops-catalog-data"},
+			wantNamespace: catalogSourceNamespace,
+		},
+		{
+			name:          "namespace",
+			config:        &CatalogSourceConfig{Name: "gitops-catalog", Namespace: "gitops-catalogs", Image: "quay.io/example/index:latest"},
+			want:          olmv1alpha1.CatalogSourceSpec{SourceType: olmv1alpha1.SourceTypeGrpc, Image: "quay.io/example/index:latest"},
+			wantNamespace: "gitops-catalogs",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClient := newFakeClient(t,
+				newCSV(newArgoCDOperator("").csv, "argocd", olmv1alpha1.CSVPhaseSucceeded),
+				newCSV(newSealedSecretsOperator("").csv, "cicd", olmv1alpha1.CSVPhaseSucceeded),
+			)
+			d := newTestDependency(fakeClient, "")
+			d.CatalogSource = tt.config
+
+			assertNoError(t, d.Install())
+
+			catalog := &olmv1alpha1.CatalogSource{}
+			assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "gitops-catalog", Namespace: tt.wantNamespace}, catalog))
+			if diff := cmp.Diff(tt.want, catalog.Spec); diff != "" {
+				t.Fatalf("CatalogSource mismatch: %v", diff)
+			}
+
+			subscription := &olmv1alpha1.Subscription{}
+			assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: newArgoCDOperator("").name, Namespace: "argocd"}, subscription))
+			if subscription.Spec.CatalogSource != "gitops-catalog" || subscription.Spec.CatalogSourceNamespace != tt.wantNamespace {
+				t.Fatalf("got catalog source %s/%s, want %s/gitops-catalog", subscription.Spec.CatalogSourceNamespace, subscription.Spec.CatalogSource, tt.wantNamespace)
+			}
+		})
+	}
+}
+
+func TestInstall_labels(t *testing.T) {
+	operator := newArgoCDOperator("")
+	operator.roles = []rbacv1.Role{{ObjectMeta: metav1.ObjectMeta{Name: "argocd-extra"}}}
+	fakeClient := newFakeClient(t, newCSV(operator.csv, "argocd", olmv1alpha1.CSVPhaseSucceeded))
+	d := newTestDependency(fakeClient, "")
+	d.operators = []operatorResource{operator}
+
+	assertNoError(t, d.Install())
+
+	want := map[string]string{
+		"app.kubernetes.io/managed-by":   "gitops-operator",
+		"gitops.openshift.io/dependency": "argocd-operator",
+	}
+	objs := []struct {
+		key types.NamespacedName
+		obj runtime.Object
+	}{
+		{types.NamespacedName{Name: "argocd"}, &corev1.Namespace{}},
+		{types.NamespacedName{Name: operatorGroupName, Namespace: "argocd"}, &operatorsv1.OperatorGroup{}},
+		{types.NamespacedName{Name: operator.name, Namespace: "argocd"}, &olmv1alpha1.Subscription{}},
+		{types.NamespacedName{Name: "argocd-extra", Namespace: "argocd"}, &rbacv1.Role{}},
+	}
+	for _, o := range objs {
+		assertNoError(t, fakeClient.Get(context.TODO(), o.key, o.obj))
+		accessor, err := meta.Accessor(o.obj)
+		assertNoError(t, err)
+		if diff := cmp.Diff(want, accessor.GetLabels()); diff != "" {
+			t.Fatalf("%T %s labels mismatch: %v", o.obj, o.key, diff)
+		}
+	}
+}
+
+func TestInstall_namespace_labels(t *testing.T) {
+	operator := newArgoCDOperator("")
+	namespaceLabels := map[string]string{
+		"pod-security.kubernetes.io/enforce": "restricted",
+		"openshift.io/cluster-monitoring":    "true",
+	}
+
+	t.Run("created namespace carries the labels", func(t *testing.T) {
+		fakeClient := newFakeClient(t, newCSV(operator.csv, "argocd", olmv1alpha1.CSVPhaseSucceeded))
+		d := newTestDependency(fakeClient, "")
+		d.operators = []operatorResource{operator}
+		d.NamespaceLabels = namespaceLabels
+
+		assertNoError(t, d.Install())
+
+		want := mergeLabels(namespaceLabels, dependencyLabels(operator.name))
+		assertNamespaceLabels(t, fakeClient, "argocd", want)
+	})
+	t.Run("existing namespace gets the missing labels", func(t *testing.T) {
+		existing := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "argocd",
+				Labels: map[string]string{"pod-security.kubernetes.io/enforce": "privileged"},
+			},
+		}
+		fakeClient := newFakeClient(t, existing, newCSV(operator.csv, "argocd", olmv1alpha1.CSVPhaseSucceeded))
+		d := newTestDependency(fakeClient, "")
+		d.operators = []operatorResource{operator}
+		d.NamespaceLabels = namespaceLabels
+		d.PatchExistingNamespaces = true
+
+		assertNoError(t, d.Install())
+
+		want := map[string]string{
+			"pod-security.kubernetes.io/enforce": "privileged",
+			"openshift.io/cluster-monitoring":    "true",
+		}
+		assertNamespaceLabels(t, fakeClient, "argocd", want)
+	})
+	t.Run("existing namespace left alone without patching", func(t *testing.T) {
+		existing := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "argocd"}}
+		fakeClient := newFakeClient(t, existing, newCSV(operator.csv, "argocd", olmv1alpha1.CSVPhaseSucceeded))
+		d := newTestDependency(fakeClient, "")
+		d.operators = []operatorResource{operator}
+		d.NamespaceLabels = namespaceLabels
+
+		assertNoError(t, d.Install())
+
+		assertNamespaceLabels(t, fakeClient, "argocd", nil)
+	})
+}
+
+func TestInstalledVersions(t *testing.T) {
+	argocd := newCSV("argocd-operator.v0.0.14", "argocd", olmv1alpha1.CSVPhaseSucceeded)
+	argocd.Spec.Version = version.OperatorVersion{Version: semver.MustParse("0.0.14")}
+	subscription := newSubscription("argocd-operator", "argocd", "argocd-operator", "alpha")
+	subscription.Status.InstalledCSV = argocd.Name
+	sealedSecrets := newCSV("sealed-secrets-operator-helm.v0.0.2", "cicd", olmv1alpha1.CSVPhaseInstalling)
+	sealedSecrets.Spec.Version = version.OperatorVersion{Version: semver.MustParse("0.0.2")}
+	fakeClient := newFakeClient(t, argocd, subscription, sealedSecrets)
+	d := newTestDependency(fakeClient, "")
+	missing := operatorResource{name: "missing-operator", namespace: "missing", csv: "missing-operator.v1.0.0"}
+	d.operators = append(d.operators, missing)
+
+	got, err := d.InstalledVersions()
+	assertNoError(t, err)
+
+	want := []OperatorVersion{
+		{
+			Operator:     "argocd-operator",
+			Namespace:    "argocd",
+			ExpectedCSV:  "argocd-operator.v0.0.13",
+			InstalledCSV: "argocd-operator.v0.0.14",
+			Version:      "0.0.14",
+			Phase:        olmv1alpha1.CSVPhaseSucceeded,
+		},
+		{
+			Operator:     "sealed-secrets-operator-helm",
+			Namespace:    "cicd",
+			ExpectedCSV:  "sealed-secrets-operator-helm.v0.0.2",
+			InstalledCSV: "sealed-secrets-operator-helm.v0.0.2",
+			Version:      "0.0.2",
+			Phase:        olmv1alpha1.CSVPhaseInstalling,
+		},
+		{
+			Operator:    "missing-operator",
+			Namespace:   "missing",
+			ExpectedCSV: "missing-operator.v1.0.0",
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("installed versions mismatch: %v", diff)
+	}
+}
+
+func TestIsInstalled(t *testing.T) {
+	argocd := newCSV("argocd-operator.v0.0.13", "argocd", olmv1alpha1.CSVPhaseSucceeded)
+
+	t.Run("all installed", func(t *testing.T) {
+		sealedSecrets := newCSV("sealed-secrets-operator-helm.v0.0.2", "cicd", olmv1alpha1.CSVPhaseSucceeded)
+		objs := []runtime.Object{argocd.DeepCopy(), sealedSecrets}
+		objs = append(objs, installedComponents(newArgoCDOperator(""), "argocd")...)
+		objs = append(objs, installedComponents(newSealedSecretsOperator(""), "cicd")...)
+		fakeClient := newFakeClient(t, objs...)
+		d := newTestDependency(fakeClient, "")
+
+		installed, err := d.IsInstalled()
+		assertNoError(t, err)
+		if !installed {
+			t.Fatal("expected the dependencies to be installed")
+		}
+	})
+	t.Run("partially installed", func(t *testing.T) {
+		sealedSecrets := newCSV("sealed-secrets-operator-helm.v0.0.2", "cicd", olmv1alpha1.CSVPhaseInstalling)
+		for name, fakeClient := range map[string]client.Client{
+			"missing CSV":    newFakeClient(t, argocd.DeepCopy()),
+			"installing CSV": newFakeClient(t, argocd.DeepCopy(), sealedSecrets),
+		} {
+			d := newTestDependency(fakeClient, "")
+
+			installed, err := d.IsInstalled()
+			assertNoError(t, err)
+			if installed {
+				t.Fatalf("%s: expected the dependencies not to be installed", name)
+			}
+			namespaces := &corev1.NamespaceList{}
+			assertNoError(t, fakeClient.List(context.TODO(), namespaces))
+			if len(namespaces.Items) != 0 {
+				t.Fatalf("%s: expected no resources to be created, got namespaces %v", name, namespaces.Items)
+			}
+		}
+	})
+}
+
+func TestInstall_wrapped_errors(t *testing.T) {
+	fakeClient := &failingClient{Client: newFakeClient(t), createErr: goerrors.New("admission webhook denied the request")}
+	d := newTestDependency(fakeClient, "")
+	d.operators = []operatorResource{newArgoCDOperator("")}
+	fakeClient.failKind = "Subscription"
+
+	err := d.Install()
+
+	want := "failed to install operator argocd-operator: failed to create Subscription argocd/argocd-operator: admission webhook denied the request"
+	if err == nil || err.Error() != want {
+		t.Fatalf("got %v, want %s", err, want)
+	}
+	if !goerrors.Is(err, fakeClient.createErr) {
+		t.Fatalf("expected %v to wrap %v", err, fakeClient.createErr)
+	}
+}
+
+func TestIsTimeout_wrapped(t *testing.T) {
+	err := fmt.Errorf("failed to install operator argocd-operator: %w", &TimeoutError{CSV: "argocd-operator.v0.0.13", Namespace: "argocd"})
+	if !IsTimeout(err) {
+		t.Fatalf("expected %v to be a timeout", err)
+	}
+	if IsTimeout(goerrors.New("not found")) {
+		t.Fatal("unexpected timeout")
+	}
+}
+
+// failingClient fails the creation of resources of failKind
+type failingClient struct {
+	client.Client
+	failKind  string
+	createErr error
+}
+
+func (c *failingClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	if kindOf(obj) == c.failKind {
+		return c.createErr
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func TestCreateResourceIfAbsent(t *testing.T) {
+	fakeClient := newFakeClient(t)
+	key := types.NamespacedName{Name: "argocd"}
+
+	for _, want := range []controllerutil.OperationResult{controllerutil.OperationResultCreated, controllerutil.OperationResultNone} {
+		result, err := createResourceIfAbsent(context.TODO(), fakeClient, newNamespace(key.Name, "argocd-operator"), key)
+		assertNoError(t, err)
+		if result != want {
+			t.Fatalf("got result %q, want %q", result, want)
+		}
+	}
+}
+
+// createRecordingClient records the kinds of the resources it creates
+// disappearingClient deletes the objects it gets once they have been found
+// a number of times, like OLM removing them asynchronously
+type disappearingClient struct {
+	client.Client
+	found map[string]int
+	after int
+}
+
+func (c *disappearingClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if err := c.Client.Get(ctx, key, obj); err != nil {
+		return err
+	}
+	id := kindOf(obj) + "/" + keyString(key)
+	if c.found[id]++; c.found[id] > c.after {
+		if err := c.Client.Delete(ctx, obj); err != nil {
+			return err
+		}
+		return c.Client.Get(ctx, key, obj)
+	}
+	return nil
+}
+
+type createRecordingClient struct {
+	client.Client
+	created []string
+}
+
+func (c *createRecordingClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	c.created = append(c.created, kindOf(obj))
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func TestInstall_repairs_deleted_subscription(t *testing.T) {
+	argocd, sealedSecrets := newArgoCDOperator(""), newSealedSecretsOperator("")
+	objs := []runtime.Object{
+		newCSV(argocd.csv, "argocd", olmv1alpha1.CSVPhaseSucceeded),
+		newCSV(sealedSecrets.csv, "cicd", olmv1alpha1.CSVPhaseSucceeded),
+		// the argocd Subscription was deleted manually
+		newNamespace("argocd", argocd.name),
+		newOperatorGroup("argocd", argocd.name, []string{"argocd"}),
+	}
+	objs = append(objs, installedComponents(sealedSecrets, "cicd")...)
+	fakeClient := &createRecordingClient{Client: newFakeClient(t, objs...)}
+	d := newTestDependency(fakeClient, "")
+	events := []ProgressEvent{}
+	d.OnProgress = func(event ProgressEvent) {
+		events = append(events, event)
+	}
+
+	installed, err := d.IsInstalled()
+	assertNoError(t, err)
+	if installed {
+		t.Fatal("expected the dependencies not to be installed without the argocd Subscription")
+	}
+
+	assertNoError(t, d.Install())
+
+	if diff := cmp.Diff([]string{"Subscription"}, fakeClient.created); diff != "" {
+		t.Fatalf("created resources mismatch: %v", diff)
+	}
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: argocd.name, Namespace: "argocd"}, &olmv1alpha1.Subscription{}))
+	ready := 0
+	for _, event := range events {
+		if event.Operator == argocd.name && event.Stage == StageReady {
+			ready++
+		}
+	}
+	if ready != 1 {
+		t.Fatalf("got progress events %v, want the readiness of %s to be waited for again", events, argocd.name)
+	}
+}
+
+func TestInstall_manual_install_plan_approval(t *testing.T) {
+	operator := newArgoCDOperator("")
+	fakeClient := newFakeClient(t, newCSV(operator.csv, "argocd", olmv1alpha1.CSVPhaseSucceeded))
+	d := newTestDependency(fakeClient, "")
+	d.operators = []operatorResource{operator}
+	d.InstallPlanApproval = map[string]olmv1alpha1.Approval{operator.name: olmv1alpha1.ApprovalManual}
+
+	// simulate OLM creating the install plan for the new subscription
+	go func() {
+		key := types.NamespacedName{Name: operator.name, Namespace: "argocd"}
+		subscription := &olmv1alpha1.Subscription{}
+		for fakeClient.Get(context.TODO(), key, subscription) != nil {
+			time.Sleep(100 * time.Millisecond)
+		}
+		plan := &olmv1alpha1.InstallPlan{
+			ObjectMeta: metav1.ObjectMeta{Name: "install-abcde", Namespace: "argocd"},
+			Spec: olmv1alpha1.InstallPlanSpec{
+				ClusterServiceVersionNames: []string{operator.csv},
+				Approval:                   olmv1alpha1.ApprovalManual,
+			},
+		}
+		if err := fakeClient.Create(context.TODO(), plan); err != nil {
+			t.Error(err)
+			return
+		}
+		subscription.Status.InstallPlanRef = &corev1.ObjectReference{Name: plan.Name, Namespace: plan.Namespace}
+		if err := fakeClient.Update(context.TODO(), subscription); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	assertNoError(t, d.Install())
+
+	subscription := &olmv1alpha1.Subscription{}
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: operator.name, Namespace: "argocd"}, subscription))
+	if subscription.Spec.InstallPlanApproval != olmv1alpha1.ApprovalManual {
+		t.Fatalf("got install plan approval %q, want %q", subscription.Spec.InstallPlanApproval, olmv1alpha1.ApprovalManual)
+	}
+	plan := &olmv1alpha1.InstallPlan{}
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "install-abcde", Namespace: "argocd"}, plan))
+	if !plan.Spec.Approved {
+		t.Fatal("expected the initial install plan to be approved")
+	}
+}
+
+func TestInstall_pending_install_plan(t *testing.T) {
+	operator := newArgoCDOperator("")
+	// a previous install was aborted before approving the install plan
+	subscription := newSubscription(operator.name, "argocd", operator.packageName, operator.channel)
+	subscription.Spec.InstallPlanApproval = olmv1alpha1.ApprovalManual
+	subscription.Status.InstallPlanRef = &corev1.ObjectReference{Name: "install-abcde", Namespace: "argocd"}
+	plan := &olmv1alpha1.InstallPlan{
+		ObjectMeta: metav1.ObjectMeta{Name: "install-abcde", Namespace: "argocd"},
+		Spec: olmv1alpha1.InstallPlanSpec{
+			ClusterServiceVersionNames: []string{operator.csv},
+			Approval:                   olmv1alpha1.ApprovalManual,
+		},
+	}
+	fakeClient := &patchRecordingClient{Client: newFakeClient(t, subscription, plan, newCSV(operator.csv, "argocd", olmv1alpha1.CSVPhaseSucceeded))}
+	d := newTestDependency(fakeClient, "")
+	d.InstallPlanApproval = map[string]olmv1alpha1.Approval{operator.name: olmv1alpha1.ApprovalManual}
+
+	_, err := d.install(context.TODO(), operator)
+	assertNoError(t, err)
+
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: plan.Name, Namespace: plan.Namespace}, plan))
+	if !plan.Spec.Approved {
+		t.Fatal("expected the pending install plan to be approved")
+	}
+	if diff := cmp.Diff([]string{"InstallPlan"}, fakeClient.patched); diff != "" {
+		t.Fatalf("patched kinds mismatch: %v", diff)
+	}
+}
+
+// patchRecordingClient records the kinds of the objects patched through it
+type patchRecordingClient struct {
+	client.Client
+	patched []string
+}
+
+func (c *patchRecordingClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.patched = append(c.patched, kindOf(obj))
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func TestUninstall(t *testing.T) {
+	operator := newArgoCDOperator("")
+	fakeClient := newFakeClient(t, append(installedComponents(operator, "test-argocd"),
+		newCSV(operator.csv, "test-argocd", olmv1alpha1.CSVPhaseSucceeded),
+	)...)
+	d := newTestDependency(fakeClient, "test")
+
+	err := d.Uninstall()
+	assertNoError(t, err)
+
+	assertNotFound(t, fakeClient, types.NamespacedName{Name: "test-argocd"}, &corev1.Namespace{})
+	assertNotFound(t, fakeClient, types.NamespacedName{Name: operatorGroupName, Namespace: "test-argocd"}, &operatorsv1.OperatorGroup{})
+	assertNotFound(t, fakeClient, types.NamespacedName{Name: operator.name, Namespace: "test-argocd"}, &olmv1alpha1.Subscription{})
+	assertNotFound(t, fakeClient, types.NamespacedName{Name: operator.csv, Namespace: "test-argocd"}, &olmv1alpha1.ClusterServiceVersion{})
+}
+
+func TestUninstall_keeps_namespaces(t *testing.T) {
+	operator := newArgoCDOperator("")
+	tests := []struct {
+		name      string
+		namespace *corev1.Namespace
+		newClient func(c client.Client) (*Dependency, error)
+	}{
+		{
+			"pre-existing namespace",
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-argocd", Labels: map[string]string{"team": "gitops"}}},
+			func(c client.Client) (*Dependency, error) { return NewClientWithPrefix(c, "test") },
+		},
+		{
+			"default namespace",
+			newNamespace("argocd", operator.name),
+			func(c client.Client) (*Dependency, error) { return NewClient(c) },
+		},
+		{
+			"namespace override",
+			newNamespace("gitops", operator.name),
+			func(c client.Client) (*Dependency, error) {
+				return NewClientWithNamespaces(c, "test", map[string]string{operator.name: "gitops"})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace := tt.namespace.Name
+			fakeClient := newFakeClient(t, append(installedComponents(operator, namespace)[1:],
+				tt.namespace,
+				newCSV(operator.csv, namespace, olmv1alpha1.CSVPhaseSucceeded),
+			)...)
+			d, err := tt.newClient(fakeClient)
+			assertNoError(t, err)
+
+			assertNoError(t, d.Uninstall())
+
+			assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: namespace}, &corev1.Namespace{}))
+			assertNotFound(t, fakeClient, types.NamespacedName{Name: operatorGroupName, Namespace: namespace}, &operatorsv1.OperatorGroup{})
+			assertNotFound(t, fakeClient, types.NamespacedName{Name: operator.name, Namespace: namespace}, &olmv1alpha1.Subscription{})
+			assertNotFound(t, fakeClient, types.NamespacedName{Name: operator.csv, Namespace: namespace}, &olmv1alpha1.ClusterServiceVersion{})
+		})
+	}
+}
+
+func TestUninstall_keeps_user_subscription(t *testing.T) {
+	operator := newArgoCDOperator("")
+	subscription := newSubscription(operator.name, "test-argocd", operator.packageName, operator.channel)
+	subscription.Labels = nil
+	fakeClient := newFakeClient(t,
+		subscription,
+		newCSV(operator.csv, "test-argocd", olmv1alpha1.CSVPhaseSucceeded),
+	)
+	d := newTestDependency(fakeClient, "test")
+	d.operators = []operatorResource{operator}
+	d.WaitForRemoval = true
+
+	assertNoError(t, d.Uninstall())
+
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: operator.name, Namespace: "test-argocd"}, &olmv1alpha1.Subscription{}))
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: operator.csv, Namespace: "test-argocd"}, &olmv1alpha1.ClusterServiceVersion{}))
+}
+
+func TestUninstall_upgraded_operator(t *testing.T) {
+	operator := newArgoCDOperator("")
+	subscription := newSubscription(operator.name, "test-argocd", operator.packageName, operator.channel)
+	subscription.Status.InstalledCSV = "argocd-operator.v0.0.14"
+	fakeClient := newFakeClient(t,
+		subscription,
+		newCSV("argocd-operator.v0.0.14", "test-argocd", olmv1alpha1.CSVPhaseSucceeded),
+	)
+	d := newTestDependency(fakeClient, "test")
+	d.operators = []operatorResource{operator}
+	d.WaitForRemoval = true
+
+	assertNoError(t, d.Uninstall())
+
+	assertNotFound(t, fakeClient, types.NamespacedName{Name: operator.name, Namespace: "test-argocd"}, &olmv1alpha1.Subscription{})
+	assertNotFound(t, fakeClient, types.NamespacedName{Name: "argocd-operator.v0.0.14", Namespace: "test-argocd"}, &olmv1alpha1.ClusterServiceVersion{})
+}
+
+func TestIsOperatorReady_copied_csv(t *testing.T) {
+	csvName := "argocd-operator.v0.0.13"
+	copied := func(phase olmv1alpha1.ClusterServiceVersionPhase) *olmv1alpha1.ClusterServiceVersion {
+		csv := newCSV(csvName, "argocd", phase)
+		csv.Labels = map[string]string{olmv1alpha1.CopiedLabelKey: clusterWideNamespace}
+		csv.Status.Reason = olmv1alpha1.CSVReasonCopied
+		return csv
+	}
+
+	tests := []struct {
+		name      string
+		objs      []runtime.Object
+		wantReady bool
+		wantErr   bool
+	}{
+		{
+			name:      "original succeeded",
+			objs:      []runtime.Object{copied(olmv1alpha1.CSVPhaseFailed), newCSV(csvName, clusterWideNamespace, olmv1alpha1.CSVPhaseSucceeded)},
+			wantReady: true,
+		},
+		{
+			name: "original still installing",
+			objs: []runtime.Object{copied(olmv1alpha1.CSVPhaseSucceeded), newCSV(csvName, clusterWideNamespace, olmv1alpha1.CSVPhaseInstalling)},
+		},
+		{
+			name:    "original failed",
+			objs:    []runtime.Object{copied(olmv1alpha1.CSVPhaseSucceeded), newCSV(csvName, clusterWideNamespace, olmv1alpha1.CSVPhaseFailed)},
+			wantErr: true,
+		},
+		{
+			name: "original missing",
+			objs: []runtime.Object{copied(olmv1alpha1.CSVPhaseSucceeded)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready, err := isOperatorReady(context.TODO(), newFakeClient(t, tt.objs...), csvName, "argocd")()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if ready != tt.wantReady {
+				t.Fatalf("got ready %v, want %v", ready, tt.wantReady)
+			}
+		})
+	}
+}
+
+func TestInstall_operator_rbac(t *testing.T) {
+	operator := newArgoCDOperator("")
+	operator.roles = []rbacv1.Role{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "argocd-extra"},
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+			},
+		},
+	}
+	operator.roleBindings = []rbacv1.RoleBinding{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "argocd-extra"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "argocd-extra"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "argocd-operator"}},
+		},
+	}
+	fakeClient := newFakeClient(t, newCSV(operator.csv, "test-argocd", olmv1alpha1.CSVPhaseSucceeded))
+	d := newTestDependency(fakeClient, "test")
+	d.operators = []operatorResource{operator}
+
+	assertNoError(t, d.Install())
+
+	key := types.NamespacedName{Name: "argocd-extra", Namespace: "test-argocd"}
+	assertNoError(t, fakeClient.Get(context.TODO(), key, &rbacv1.Role{}))
+	assertNoError(t, fakeClient.Get(context.TODO(), key, &rbacv1.RoleBinding{}))
+
+	assertNoError(t, d.Uninstall())
+
+	assertNotFound(t, fakeClient, key, &rbacv1.Role{})
+	assertNotFound(t, fakeClient, key, &rbacv1.RoleBinding{})
+}
+
+func TestWaitForCSV_pending_timeout(t *testing.T) {
+	csv := newCSV("argocd-operator.v0.0.13", "argocd", olmv1alpha1.CSVPhasePending)
+	csv.Status.Reason = olmv1alpha1.CSVReasonRequirementsNotMet
+	csv.Status.Message = "one or more requirements couldn't be found"
+	fakeClient := newFakeClient(t, csv)
+
+	err := waitForCSV(context.TODO(), fakeClient, csv.Name, csv.Namespace, 2*time.Second)
+
+	want := "timed out waiting for CSV argocd-operator.v0.0.13 in namespace argocd: phase Pending, reason RequirementsNotMet: one or more requirements couldn't be found"
+	if err == nil || err.Error() != want {
+		t.Fatalf("got %v, want %s", err, want)
+	}
+	if !IsTimeout(err) {
+		t.Fatalf("expected %v to be a timeout", err)
+	}
+}
+
+func TestWaitForAll(t *testing.T) {
+	fakeClient := newFakeClient(t,
+		newCSV("argocd-operator.v0.0.13", "argocd", olmv1alpha1.CSVPhaseSucceeded),
+		newCSV("sealed-secrets-operator-helm.v0.0.2", "cicd", olmv1alpha1.CSVPhaseInstalling))
+	csvs := []types.NamespacedName{
+		{Name: "argocd-operator.v0.0.13", Namespace: "argocd"},
+		{Name: "sealed-secrets-operator-helm.v0.0.2", Namespace: "cicd"},
+	}
+
+	start := time.Now()
+	err := WaitForAll(fakeClient, csvs, 2*time.Second)
+
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("waited %v, more than the shared timeout", elapsed)
+	}
+	notReady, ok := err.(*NotReadyError)
+	if !ok {
+		t.Fatalf("got %v, want a *NotReadyError", err)
+	}
+	if len(notReady.Timeouts) != 1 || notReady.Timeouts[0].CSV != "sealed-secrets-operator-helm.v0.0.2" {
+		t.Fatalf("got %v, want only sealed-secrets-operator-helm.v0.0.2 to be reported", err)
+	}
+	if !IsTimeout(err) {
+		t.Fatalf("expected %v to be a timeout", err)
+	}
+}
+
+func TestWaitForCSV_backoff_detects_success(t *testing.T) {
+	csv := newCSV("argocd-operator.v0.0.13", "argocd", olmv1alpha1.CSVPhaseInstalling)
+	fakeClient := newFakeClient(t, csv)
+	go func() {
+		time.Sleep(time.Second)
+		csv := csv.DeepCopy()
+		csv.Status.Phase = olmv1alpha1.CSVPhaseSucceeded
+		if err := fakeClient.Update(context.TODO(), csv); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	start := time.Now()
+	assertNoError(t, waitForCSV(context.TODO(), fakeClient, csv.Name, csv.Namespace, 10*time.Second))
+
+	// the CSV is checked after about 0.5s and 1.5s, so success must be seen well
+	// before the steady-state interval kicks in
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("took %v to detect the Succeeded CSV", elapsed)
+	}
+}
+
+func TestWaitForOperatorGone(t *testing.T) {
+	operator := newArgoCDOperator("")
+	t.Run("removed after a few polls", func(t *testing.T) {
+		fakeClient := &disappearingClient{
+			Client: newFakeClient(t,
+				newSubscription(operator.name, "argocd", operator.packageName, operator.channel),
+				newCSV(operator.csv, "argocd", olmv1alpha1.CSVPhaseDeleting),
+			),
+			found: map[string]int{},
+			after: 2,
+		}
+		d := newTestDependency(fakeClient, "")
+
+		assertNoError(t, d.waitForOperatorGone(context.TODO(), operator, operator.csv, "argocd"))
+		for id, found := range fakeClient.found {
+			if found != 3 {
+				t.Fatalf("%s found %d times, want 3", id, found)
+			}
+		}
+	})
+	t.Run("timeout", func(t *testing.T) {
+		fakeClient := newFakeClient(t, newCSV(operator.csv, "argocd", olmv1alpha1.CSVPhaseDeleting))
+		d := newTestDependency(fakeClient, "")
+		d.timeout = 100 * time.Millisecond
+
+		err := d.waitForOperatorGone(context.TODO(), operator, operator.csv, "argocd")
+		if !IsTimeout(err) {
+			t.Fatalf("got error %v, want a timeout", err)
+		}
+		if !strings.Contains(err.Error(), "remaining: ClusterServiceVersion "+operator.csv) {
+			t.Fatalf("got error %q, want it to list the remaining CSV", err)
+		}
+	})
+}
+
+func TestInstall_operator_timeout(t *testing.T) {
+	argocd, sealedSecrets := newArgoCDOperator(""), newSealedSecretsOperator("")
+	argocd.timeout = time.Second
+	d := newTestDependency(newFakeClient(t), "")
+	d.timeout = 200 * time.Millisecond
+
+	for _, tt := range []struct {
+		operator operatorResource
+		min, max time.Duration
+	}{
+		{argocd, time.Second, 2 * time.Second},
+		{sealedSecrets, 200 * time.Millisecond, 900 * time.Millisecond},
+	} {
+		start := time.Now()
+		_, err := d.install(context.TODO(), tt.operator)
+		if !IsTimeout(err) {
+			t.Fatalf("%s: got error %v, want a timeout", tt.operator.name, err)
+		}
+		if elapsed := time.Since(start); elapsed < tt.min || elapsed > tt.max {
+			t.Fatalf("%s: waited %v, want between %v and %v", tt.operator.name, elapsed, tt.min, tt.max)
+		}
+	}
+}
+
+func TestInstall_failed_phase_tolerance(t *testing.T) {
+	operator := newArgoCDOperator("")
+
+	t.Run("abort", func(t *testing.T) {
+		d := newTestDependency(newFakeClient(t, newCSV(operator.csv, "argocd", olmv1alpha1.CSVPhaseFailed)), "")
+
+		if _, err := d.install(context.TODO(), operator); err == nil || IsTimeout(err) {
+			t.Fatalf("got error %v, want the install to be aborted", err)
+		}
+	})
+	t.Run("tolerate", func(t *testing.T) {
+		csv := newCSV(operator.csv, "argocd", olmv1alpha1.CSVPhaseFailed)
+		fakeClient := newFakeClient(t, csv)
+		d := newTestDependency(fakeClient, "")
+		d.timeout = 10 * time.Second
+		d.FailedPhaseTolerance = 5
+		go func() {
+			// OLM retries the install after a transient failure
+			time.Sleep(time.Second)
+			csv := csv.DeepCopy()
+			csv.Status.Phase = olmv1alpha1.CSVPhaseSucceeded
+			if err := fakeClient.Update(context.TODO(), csv); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		_, err := d.install(context.TODO(), operator)
+		assertNoError(t, err)
+	})
+}
+
+func TestWaitForAll_jittered_backoff(t *testing.T) {
+	if pollBackoff.Jitter <= 0 {
+		t.Fatal("expected the readiness polls to be jittered")
+	}
+	backoff := pollBackoff
+	for want := backoff.Duration; backoff.Steps > 1; {
+		delay := backoff.Step()
+		if max := time.Duration(float64(want) * (1 + pollBackoff.Jitter)); delay < want || delay > max {
+			t.Fatalf("got delay %v, want between %v and %v", delay, want, max)
+		}
+		if want *= 2; want > pollBackoff.Cap {
+			want = pollBackoff.Cap
+		}
+	}
+
+	csvs := []types.NamespacedName{}
+	objs := []runtime.Object{}
+	for i := 0; i < 5; i++ {
+		csv := newCSV(fmt.Sprintf("operator-%d.v1.0.0", i), "operators", olmv1alpha1.CSVPhaseInstalling)
+		csvs = append(csvs, types.NamespacedName{Name: csv.Name, Namespace: csv.Namespace})
+		objs = append(objs, csv)
+	}
+	fakeClient := newFakeClient(t, objs...)
+	go func() {
+		time.Sleep(time.Second)
+		for _, obj := range objs {
+			csv := obj.(*olmv1alpha1.ClusterServiceVersion).DeepCopy()
+			csv.Status.Phase = olmv1alpha1.CSVPhaseSucceeded
+			if err := fakeClient.Update(context.TODO(), csv); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	start := time.Now()
+	assertNoError(t, WaitForAll(fakeClient, csvs, 10*time.Second))
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("took %v to detect the Succeeded CSVs", elapsed)
+	}
+}
+
+func TestInstallContext_cancelled(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		t.Run(fmt.Sprintf("WaitInParallel=%v", parallel), func(t *testing.T) {
+			fakeClient := newFakeClient(t,
+				newCSV(newArgoCDOperator("").csv, "argocd", olmv1alpha1.CSVPhaseInstalling),
+				newCSV(newSealedSecretsOperator("").csv, "cicd", olmv1alpha1.CSVPhaseInstalling),
+			)
+			d := newTestDependency(fakeClient, "")
+			d.timeout = 30 * time.Second
+			d.WaitInParallel = parallel
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(200*time.Millisecond, cancel)
+
+			start := time.Now()
+			err := d.InstallContext(ctx)
+			if !goerrors.Is(err, context.Canceled) {
+				t.Fatalf("got error %v, want %v", err, context.Canceled)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Fatalf("took %v to abort the install", elapsed)
+			}
+		})
+	}
+}
+
+func TestIsOperatorReady_unknown_phase(t *testing.T) {
+	fakeClient := newFakeClient(t, newCSV("argocd-operator.v0.0.13", "argocd", olmv1alpha1.CSVPhaseUnknown))
+	ready := isOperatorReady(context.TODO(), fakeClient, "argocd-operator.v0.0.13", "argocd")
+
+	for i := 1; i < unknownPhaseLimit; i++ {
+		_, err := ready()
+		assertNoError(t, err)
+	}
+	if _, err := ready(); err == nil {
+		t.Fatalf("was expecting an error after %d polls in the Unknown phase", unknownPhaseLimit)
+	}
+}
+
+func TestIsOperatorReady_missing_csv(t *testing.T) {
+	csv := newCSV("argocd-operator.v0.0.13", "argocd", olmv1alpha1.CSVPhaseInstalling)
+	fakeClient := newFakeClient(t, csv)
+	status := &csvStatus{}
+	ready := status.isOperatorReady(context.TODO(), fakeClient, csv.Name, csv.Namespace)
+
+	done, err := ready()
+	assertNoError(t, err)
+	if done || !status.found || status.phase != olmv1alpha1.CSVPhaseInstalling {
+		t.Fatalf("got done %v, found %v and phase %q", done, status.found, status.phase)
+	}
+
+	// the CSV is deleted, e.g. replaced during an upgrade
+	assertNoError(t, fakeClient.Delete(context.TODO(), csv))
+	done, err = ready()
+	assertNoError(t, err)
+	if done || status.found || status.phase != "" {
+		t.Fatalf("got done %v, found %v and phase %q, want the missing CSV not to be reported as installing", done, status.found, status.phase)
+	}
+
+	err = waitForCSV(context.TODO(), fakeClient, csv.Name, csv.Namespace, 100*time.Millisecond)
+	want := "timed out waiting for CSV argocd-operator.v0.0.13 in namespace argocd: CSV not found"
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %s", err, want)
+	}
+}
+
+func TestIsOperatorReady_empty_phase(t *testing.T) {
+	csv := newCSV("argocd-operator.v0.0.13", "argocd", "")
+	fakeClient := newFakeClient(t, csv)
+	status := &csvStatus{}
+
+	done, err := status.isOperatorReady(context.TODO(), fakeClient, csv.Name, csv.Namespace)()
+	assertNoError(t, err)
+	if done || !status.found {
+		t.Fatalf("got done %v and found %v, want a CSV without phase to be found but not ready", done, status.found)
+	}
+
+	err = waitForCSV(context.TODO(), fakeClient, csv.Name, csv.Namespace, 100*time.Millisecond)
+	want := "timed out waiting for CSV argocd-operator.v0.0.13 in namespace argocd: CSV has no phase yet"
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %s", err, want)
+	}
+}
+
+func TestInstall_operator_group_install_mode(t *testing.T) {
+	tests := []struct {
+		name             string
+		installMode      olmv1alpha1.InstallModeType
+		targetNamespaces []string
+		want             []string
+	}{
+		{"default", "", nil, []string{"argocd"}},
+		{"OwnNamespace", olmv1alpha1.InstallModeTypeOwnNamespace, nil, []string{"argocd"}},
+		{"AllNamespaces", olmv1alpha1.InstallModeTypeAllNamespaces, nil, nil},
+		{"MultiNamespace", olmv1alpha1.InstallModeTypeMultiNamespace, []string{"argocd", "gitops"}, []string{"argocd", "gitops"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operator := newArgoCDOperator("")
+			operator.installMode, operator.targetNamespaces = tt.installMode, tt.targetNamespaces
+			fakeClient := newFakeClient(t)
+			d := newTestDependency(fakeClient, "")
+
+			_, err := d.apply(context.TODO(), operator)
+			assertNoError(t, err)
+
+			operatorGroup := &operatorsv1.OperatorGroup{}
+			assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: operatorGroupName, Namespace: "argocd"}, operatorGroup))
+			if diff := cmp.Diff(tt.want, operatorGroup.Spec.TargetNamespaces); diff != "" {
+				t.Fatalf("target namespaces mismatch: %v", diff)
+			}
+		})
+	}
+}
+
+func TestInstall_restores_drifted_operator_group(t *testing.T) {
+	operator := newArgoCDOperator("")
+	drifted := newOperatorGroup("argocd", operator.name, []string{"argocd", "other"})
+	unmanaged := newOperatorGroup("cicd", "", []string{"other"})
+	unmanaged.Labels = nil
+	fakeClient := newFakeClient(t, drifted, unmanaged)
+	d := newTestDependency(fakeClient, "")
+
+	for _, operator := range []operatorResource{operator, newSealedSecretsOperator("")} {
+		_, err := d.apply(context.TODO(), operator)
+		assertNoError(t, err)
+	}
+
+	for namespace, want := range map[string][]string{"argocd": {"argocd"}, "cicd": {"other"}} {
+		operatorGroup := &operatorsv1.OperatorGroup{}
+		assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: operatorGroupName, Namespace: namespace}, operatorGroup))
+		if diff := cmp.Diff(want, operatorGroup.Spec.TargetNamespaces); diff != "" {
+			t.Fatalf("target namespaces of the operator group in %s mismatch: %v", namespace, diff)
+		}
+	}
+}
+
+func TestInstall_subscription_resolution_failed(t *testing.T) {
+	operator := newArgoCDOperator("")
+	subscription := newSubscription(operator.name, "argocd", operator.packageName, operator.channel)
+	subscription.Status.Conditions = []olmv1alpha1.SubscriptionCondition{
+		{
+			Type:    subscriptionResolutionFailed,
+			Status:  corev1.ConditionTrue,
+			Reason:  "ConstraintsNotSatisfiable",
+			Message: "no operators found in channel alpha of package argocd-operator",
+		},
+	}
+	d := newTestDependency(newFakeClient(t, subscription), "")
+	d.timeout = 30 * time.Second
+
+	start := time.Now()
+	_, err := d.install(context.TODO(), operator)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("waited %v for a subscription that failed to resolve", elapsed)
+	}
+	if err == nil || IsTimeout(err) {
+		t.Fatalf("got error %v, want a resolution failure", err)
+	}
+	for _, want := range []string{"ResolutionFailed", "ConstraintsNotSatisfiable", "no operators found in channel alpha"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("error %q does not contain %q", err, want)
+		}
+	}
+}
+
+func TestInstall_subscription_resolution_failed_in_parallel(t *testing.T) {
+	operator := newArgoCDOperator("")
+	subscription := newSubscription(operator.name, "argocd", operator.packageName, operator.channel)
+	subscription.Status.Conditions = []olmv1alpha1.SubscriptionCondition{
+		{
+			Type:    subscriptionResolutionFailed,
+			Status:  corev1.ConditionTrue,
+			Reason:  "ConstraintsNotSatisfiable",
+			Message: "no operators found in channel alpha of package argocd-operator",
+		},
+	}
+	fakeClient := newFakeClient(t, subscription, newCSV(newSealedSecretsOperator("").csv, "cicd", olmv1alpha1.CSVPhaseInstalling))
+	d := newTestDependency(fakeClient, "")
+	d.timeout = 30 * time.Second
+	d.WaitInParallel = true
+
+	start := time.Now()
+	err := d.Install()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("waited %v for a subscription that failed to resolve", elapsed)
+	}
+	if err == nil || IsTimeout(err) {
+		t.Fatalf("got error %v, want a resolution failure", err)
+	}
+	for _, want := range []string{"ResolutionFailed", "ConstraintsNotSatisfiable"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("error %q does not contain %q", err, want)
+		}
+	}
+}
+
+func TestInstall_all_ready_signal(t *testing.T) {
+	t.Run("emitted once all operators are ready", func(t *testing.T) {
+		logger := recordLogs(t)
+		fakeClient := newFakeClient(t,
+			newCSV(newArgoCDOperator("").csv, "argocd", olmv1alpha1.CSVPhaseSucceeded),
+			newCSV(newSealedSecretsOperator("").csv, "cicd", olmv1alpha1.CSVPhaseSucceeded),
+		)
+
+		assertNoError(t, newTestDependency(fakeClient, "").Install())
+
+		if got := logger.count(allReadyMessage); got != 1 {
+			t.Fatalf("got %d %q messages, want 1", got, allReadyMessage)
+		}
+		if last := logger.messages[len(logger.messages)-1]; last != allReadyMessage {
+			t.Fatalf("got last message %q, want %q", last, allReadyMessage)
+		}
+	})
+	t.Run("not emitted when an operator fails", func(t *testing.T) {
+		logger := recordLogs(t)
+		fakeClient := newFakeClient(t,
+			newCSV(newArgoCDOperator("").csv, "argocd", olmv1alpha1.CSVPhaseSucceeded),
+			newCSV(newSealedSecretsOperator("").csv, "cicd", olmv1alpha1.CSVPhaseFailed),
+		)
+
+		if err := newTestDependency(fakeClient, "").Install(); err == nil {
+			t.Fatal("was expecting an error, but got nil")
+		}
+		if got := logger.count(allReadyMessage); got != 0 {
+			t.Fatalf("got %d %q messages, want 0", got, allReadyMessage)
+		}
+	})
+}
+
+func TestInstall_subscription_package(t *testing.T) {
+	operator := operatorResource{
+		name:        "gitops-argocd",
+		packageName: "argocd-operator",
+		namespace:   "argocd",
+		channel:     "alpha",
+		csv:         "argocd-operator.v0.0.13",
+	}
+	fakeClient := newFakeClient(t, newCSV(operator.csv, "argocd", olmv1alpha1.CSVPhaseSucceeded))
+	d := newTestDependency(fakeClient, "")
+	d.operators = []operatorResource{operator}
+
+	assertNoError(t, d.Install())
+
+	subscription := &olmv1alpha1.Subscription{}
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "gitops-argocd", Namespace: "argocd"}, subscription))
+	if subscription.Spec.Package != "argocd-operator" {
+		t.Fatalf("got package %q, want %q", subscription.Spec.Package, "argocd-operator")
+	}
+	assertNotFound(t, fakeClient, types.NamespacedName{Name: "argocd-operator", Namespace: "argocd"}, &olmv1alpha1.Subscription{})
+}
+
+func TestInstall_subscription_starting_csv(t *testing.T) {
+	fakeClient := newFakeClient(t)
+	d := newTestDependency(fakeClient, "")
+
+	for _, operator := range d.operators {
+		csv, err := d.apply(context.TODO(), operator)
+		assertNoError(t, err)
+
+		subscription := &olmv1alpha1.Subscription{}
+		assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: operator.name, Namespace: csv.Namespace}, subscription))
+		if subscription.Spec.StartingCSV != operator.csv {
+			t.Fatalf("got starting CSV %q, want %q", subscription.Spec.StartingCSV, operator.csv)
+		}
+	}
+}
+
+func TestInstallOperator(t *testing.T) {
+	argocd, sealedSecrets := newArgoCDOperator(""), newSealedSecretsOperator("")
+	fakeClient := newFakeClient(t, newCSV(argocd.csv, "argocd", olmv1alpha1.CSVPhaseSucceeded))
+	d := newTestDependency(fakeClient, "")
+
+	assertNoError(t, d.InstallOperator(argocd.name))
+
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: argocd.name, Namespace: "argocd"}, &olmv1alpha1.Subscription{}))
+	assertNotFound(t, fakeClient, types.NamespacedName{Name: "cicd"}, &corev1.Namespace{})
+	assertNotFound(t, fakeClient, types.NamespacedName{Name: sealedSecrets.name, Namespace: "cicd"}, &olmv1alpha1.Subscription{})
+
+	if err := d.InstallOperator("unknown-operator"); err == nil {
+		t.Fatal("was expecting an error for an unknown operator")
+	}
+}
+
+func TestInstall_progress(t *testing.T) {
+	argocd, sealedSecrets := newArgoCDOperator(""), newSealedSecretsOperator("")
+	fakeClient := newFakeClient(t,
+		newCSV(argocd.csv, "argocd", olmv1alpha1.CSVPhaseSucceeded),
+		newCSV(sealedSecrets.csv, "cicd", olmv1alpha1.CSVPhaseSucceeded),
+	)
+	d := newTestDependency(fakeClient, "")
+	events := []ProgressEvent{}
+	d.OnProgress = func(event ProgressEvent) {
+		events = append(events, event)
+	}
+
+	assertNoError(t, d.Install())
+
+	want := []ProgressEvent{
+		{Operator: argocd.name, Namespace: "argocd", Stage: StageNamespaceCreated},
+		{Operator: argocd.name, Namespace: "argocd", Stage: StageSubscriptionCreated},
+		{Operator: argocd.name, Namespace: "argocd", Stage: StageWaiting, CSV: argocd.csv},
+		{Operator: argocd.name, Namespace: "argocd", Stage: StageReady, CSV: argocd.csv},
+		{Operator: sealedSecrets.name, Namespace: "cicd", Stage: StageNamespaceCreated},
+		{Operator: sealedSecrets.name, Namespace: "cicd", Stage: StageSubscriptionCreated},
+		{Operator: sealedSecrets.name, Namespace: "cicd", Stage: StageWaiting, CSV: sealedSecrets.csv},
+		{Operator: sealedSecrets.name, Namespace: "cicd", Stage: StageReady, CSV: sealedSecrets.csv},
+	}
+	if diff := cmp.Diff(want, events); diff != "" {
+		t.Fatalf("progress events mismatch: %v", diff)
+	}
+}
+
+func TestNewClient_prefix_validation(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		wantErr bool
+	}{
+		{"", false},
+		{"test", false},
+		{"team-a1", false},
+		{"Test", true},
+		{"test-", true},
+		{"-test", true},
+		{"test_a", true},
+		{strings.Repeat("a", 60), true},
+	}
+	for _, tt := range tests {
+		_, err := NewClientWithPrefix(newFakeClient(t), tt.prefix)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewClientWithPrefix(%q) got error %v, want error %v", tt.prefix, err, tt.wantErr)
+		}
+	}
+}
+
+func TestInstall_environment_channel(t *testing.T) {
+	operators, err := parseOperators(`
+- name: argocd-operator
+  namespace: argocd
+  channel: alpha
+  channels:
+    prod: stable
+    staging: beta
+  csv: argocd-operator.v0.0.13
+`)
+	assertNoError(t, err)
+
+	for _, tt := range []struct {
+		environment string
+		want        string
+	}{
+		{"", "alpha"},
+		{"dev", "alpha"},
+		{"staging", "beta"},
+		{"prod", "stable"},
+	} {
+		fakeClient := newFakeClient(t)
+		d := newTestDependency(fakeClient, "")
+		d.Environment = tt.environment
+
+		_, err := d.apply(context.TODO(), operators[0])
+		assertNoError(t, err)
+
+		subscription := &olmv1alpha1.Subscription{}
+		assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "argocd-operator", Namespace: "argocd"}, subscription))
+		if subscription.Spec.Channel != tt.want {
+			t.Errorf("environment %q: got channel %q, want %q", tt.environment, subscription.Spec.Channel, tt.want)
+		}
+	}
+}
+
+func TestNewClient_options(t *testing.T) {
+	catalog := &CatalogSourceConfig{Name: "gitops-index", Image: "registry.example.com/gitops-index:latest"}
+	d, err := NewClient(newFakeClient(t),
+		WithPrefix("test"),
+		WithNamespaces(map[string]string{"sealed-secrets-operator-helm": "sealed-secrets"}),
+		WithTimeout(time.Minute),
+		WithChannel("argocd-operator", "stable"),
+		WithCatalogSource(catalog),
+	)
+	assertNoError(t, err)
+
+	if d.prefix != "test" || d.timeout != time.Minute || d.CatalogSource != catalog {
+		t.Fatalf("got prefix %q, timeout %v and catalog source %v", d.prefix, d.timeout, d.CatalogSource)
+	}
+	argocd, _ := d.findOperator("argocd-operator")
+	if argocd.channel != "stable" {
+		t.Fatalf("got channel %q, want stable", argocd.channel)
+	}
+	sealedSecrets, _ := d.findOperator("sealed-secrets-operator-helm")
+	if namespace := d.operatorNamespace(sealedSecrets); namespace != "sealed-secrets" {
+		t.Fatalf("got namespace %q, want sealed-secrets", namespace)
+	}
+
+	for _, opt := range []Option{WithTimeout(0), WithChannel("unknown-operator", "stable"), WithPrefix("Test")} {
+		if _, err := NewClient(newFakeClient(t), opt); err == nil {
+			t.Fatal("was expecting an error for an invalid option")
+		}
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	d, err := NewClientWithPrefix(newFakeClient(t), "test")
+	assertNoError(t, err)
+	if diff := cmp.Diff([]string{"test-argocd", "test-cicd"}, d.Namespaces()); diff != "" {
+		t.Fatalf("namespaces mismatch: %v", diff)
+	}
+
+	d, err = NewClientWithNamespaces(newFakeClient(t), "test", map[string]string{"sealed-secrets-operator-helm": "test-argocd"})
+	assertNoError(t, err)
+	if diff := cmp.Diff([]string{"test-argocd"}, d.Namespaces()); diff != "" {
+		t.Fatalf("namespaces mismatch: %v", diff)
+	}
+}
+
+func TestInstall_status_conditions(t *testing.T) {
+	argocd, sealedSecrets := newArgoCDOperator(""), newSealedSecretsOperator("")
+	key := types.NamespacedName{Name: "cluster", Namespace: "gitops"}
+	fakeClient := newFakeClient(t,
+		&pipelinesv1alpha1.GitopsService{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}},
+		newCSV(argocd.csv, "argocd", olmv1alpha1.CSVPhaseSucceeded),
+	)
+	d, err := NewClient(fakeClient, WithStatusObject(key), WithTimeout(time.Second))
+	assertNoError(t, err)
+
+	// sealed-secrets is not ready yet
+	if err := d.Install(); !IsTimeout(err) {
+		t.Fatalf("got error %v, want a timeout", err)
+	}
+	argocdReady := assertCondition(t, fakeClient, key, "ArgoCDOperatorReady", corev1.ConditionTrue, ReasonCSVSucceeded)
+	assertCondition(t, fakeClient, key, "SealedSecretsOperatorReady", corev1.ConditionFalse, ReasonNotReady)
+
+	assertNoError(t, fakeClient.Create(context.TODO(), newCSV(sealedSecrets.csv, "cicd", olmv1alpha1.CSVPhaseSucceeded)))
+	assertNoError(t, d.Install())
+	if got := assertCondition(t, fakeClient, key, "ArgoCDOperatorReady", corev1.ConditionTrue, ReasonCSVSucceeded); !got.LastTransitionTime.Equal(&argocdReady.LastTransitionTime) {
+		t.Fatalf("got last transition time %v, want it unchanged at %v", got.LastTransitionTime, argocdReady.LastTransitionTime)
+	}
+	assertCondition(t, fakeClient, key, "SealedSecretsOperatorReady", corev1.ConditionTrue, ReasonCSVSucceeded)
+}
+
+func TestReadyConditionType(t *testing.T) {
+	operator := operatorResource{name: "pipelines-operator"}
+	if got := operator.readyConditionType(); got != "PipelinesOperatorReady" {
+		t.Fatalf("got condition type %q, want PipelinesOperatorReady", got)
+	}
+}
+
+func assertCondition(t *testing.T, c client.Client, key types.NamespacedName, conditionType string, status corev1.ConditionStatus, reason string) pipelinesv1alpha1.GitopsServiceCondition {
+	t.Helper()
+	service := &pipelinesv1alpha1.GitopsService{}
+	assertNoError(t, c.Get(context.TODO(), key, service))
+	for _, condition := range service.Status.Conditions {
+		if condition.Type != conditionType {
+			continue
+		}
+		if condition.Status != status || condition.Reason != reason || condition.LastTransitionTime.IsZero() {
+			t.Fatalf("got condition %s %s with reason %s at %v, want %s with reason %s", conditionType, condition.Status, condition.Reason, condition.LastTransitionTime, status, reason)
+		}
+		return condition
+	}
+	t.Fatalf("condition %s not found in %v", conditionType, service.Status.Conditions)
+	return pipelinesv1alpha1.GitopsServiceCondition{}
+}
+
+func TestInstall_shared_namespace(t *testing.T) {
+	fakeClient := newFakeClient(t,
+		newCSV(newArgoCDOperator("").csv, "gitops", olmv1alpha1.CSVPhaseSucceeded),
+		newCSV(newSealedSecretsOperator("").csv, "gitops", olmv1alpha1.CSVPhaseSucceeded),
+	)
+	d, err := NewClient(fakeClient, WithPrefix("test"), WithSharedNamespace("gitops"))
+	assertNoError(t, err)
+	d.timeout = time.Second
+
+	assertNoError(t, d.Install())
+
+	for _, name := range []string{"argocd-operator", "sealed-secrets-operator-helm"} {
+		assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: "gitops"}, &olmv1alpha1.Subscription{}))
+	}
+	operatorGroups := &operatorsv1.OperatorGroupList{}
+	assertNoError(t, fakeClient.List(context.TODO(), operatorGroups))
+	if len(operatorGroups.Items) != 1 {
+		t.Fatalf("got %d operator groups, want 1", len(operatorGroups.Items))
+	}
+	if diff := cmp.Diff([]string{"gitops"}, operatorGroups.Items[0].Spec.TargetNamespaces); diff != "" {
+		t.Fatalf("target namespaces mismatch: %v", diff)
+	}
+	namespaces := &corev1.NamespaceList{}
+	assertNoError(t, fakeClient.List(context.TODO(), namespaces))
+	if len(namespaces.Items) != 1 || namespaces.Items[0].Name != "gitops" {
+		t.Fatalf("got namespaces %v, want only gitops", namespaces.Items)
+	}
+	if diff := cmp.Diff([]string{"gitops"}, d.Namespaces()); diff != "" {
+		t.Fatalf("namespaces mismatch: %v", diff)
+	}
+}
+
+func TestInstall_namespace_override(t *testing.T) {
+	fakeClient := newFakeClient(t,
+		newCSV(newArgoCDOperator("").csv, "test-argocd", olmv1alpha1.CSVPhaseSucceeded),
+		newCSV(newSealedSecretsOperator("").csv, "sealed-secrets", olmv1alpha1.CSVPhaseSucceeded),
+	)
+	d, err := NewClientWithNamespaces(fakeClient, "test", map[string]string{"sealed-secrets-operator-helm": "sealed-secrets"})
+	assertNoError(t, err)
+	d.timeout = 3 * time.Second
+
+	assertNoError(t, d.Install())
+
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "sealed-secrets"}, &corev1.Namespace{}))
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "sealed-secrets-operator-helm", Namespace: "sealed-secrets"}, &olmv1alpha1.Subscription{}))
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "test-argocd"}, &corev1.Namespace{}))
+	assertNotFound(t, fakeClient, types.NamespacedName{Name: "test-cicd"}, &corev1.Namespace{})
+
+	if _, err := NewClientWithNamespaces(fakeClient, "", map[string]string{"sealed-secrets-operator-helm": "Sealed_Secrets"}); err == nil {
+		t.Fatal("was expecting an error for an invalid namespace")
+	}
+	if _, err := NewClientWithNamespaces(fakeClient, "", map[string]string{"unknown-operator": "unknown"}); err == nil {
+		t.Fatal("was expecting an error for an unknown operator")
+	}
+}
+
+// installedComponents returns the resources created by Install for the
+// operator in namespace, apart from its CSV
+func installedComponents(operator operatorResource, namespace string) []runtime.Object {
+	return []runtime.Object{
+		newNamespace(namespace, operator.name),
+		newOperatorGroup(namespace, operator.name, []string{namespace}),
+		newSubscription(operator.name, namespace, operator.packageName, operator.channel),
+	}
+}
+
+func newTestDependency(c client.Client, prefix string) *Dependency {
+	d := &Dependency{
+		client:    c,
+		prefix:    prefix,
+		timeout:   3 * time.Second,
+		operators: []operatorResource{newArgoCDOperator(""), newSealedSecretsOperator("")},
+	}
+	return d
+}
+
+func newFakeClient(t *testing.T, objs ...runtime.Object) client.Client {
+	t.Helper()
+	s := scheme.Scheme
+	assertNoError(t, olmv1alpha1.AddToScheme(s))
+	assertNoError(t, operatorsv1.AddToScheme(s))
+	assertNoError(t, pipelinesv1alpha1.SchemeBuilder.AddToScheme(s))
+	return fake.NewFakeClientWithScheme(s, objs...)
+}
+
+func newClusterWideSubscription(name, installedCSV string) *olmv1alpha1.Subscription {
+	subscription := newSubscription(name, clusterWideNamespace, name, "alpha")
+	subscription.Status.InstalledCSV = installedCSV
+	return subscription
+}
+
+func newCSV(name, namespace string, phase olmv1alpha1.ClusterServiceVersionPhase) *olmv1alpha1.ClusterServiceVersion {
+	return &olmv1alpha1.ClusterServiceVersion{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Status: olmv1alpha1.ClusterServiceVersionStatus{
+			Phase: phase,
+		},
+	}
+}
+
+func assertNoError(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertNamespaceLabels(t *testing.T, c client.Client, name string, want map[string]string) {
+	t.Helper()
+	namespace := &corev1.Namespace{}
+	assertNoError(t, c.Get(context.TODO(), types.NamespacedName{Name: name}, namespace))
+	if diff := cmp.Diff(want, namespace.Labels); diff != "" {
+		t.Fatalf("namespace %s labels mismatch: %v", name, diff)
+	}
+}
+
+func assertNotFound(t *testing.T, c client.Client, key types.NamespacedName, obj runtime.Object) {
+	t.Helper()
+	err := c.Get(context.TODO(), key, obj)
+	if !errors.IsNotFound(err) {
+		t.Fatalf("got %v, want a not found error for %s", err, key)
+	}
+}
+
+// recordingLogger records the messages logged through it
+type recordingLogger struct {
+	logr.Logger
+	messages []string
+}
+
+// recordLogs replaces the package logger for the duration of the test
+func recordLogs(t *testing.T) *recordingLogger {
+	old := log
+	l := &recordingLogger{Logger: old}
+	log = l
+	t.Cleanup(func() { log = old })
+	return l
+}
+
+func (l *recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.messages = append(l.messages, msg)
+}
+
+func (l *recordingLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	return l
+}
+
+func (l *recordingLogger) count(msg string) int {
+	n := 0
+	for _, m := range l.messages {
+		if m == msg {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSupervise_csv_failed_after_install(t *testing.T) {
+	argocd := newArgoCDOperator("")
+	key := types.NamespacedName{Name: "cluster", Namespace: "gitops"}
+	csv := newCSV(argocd.csv, "argocd", olmv1alpha1.CSVPhaseSucceeded)
+	fakeClient := newFakeClient(t,
+		&pipelinesv1alpha1.GitopsService{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}},
+		csv,
+	)
+	d, err := NewClient(fakeClient, WithStatusObject(key))
+	assertNoError(t, err)
+	healthy := map[string]bool{}
+
+	// operators healthy on the first check and those not installed aren't reported
+	if events := d.checkHealth(context.TODO(), healthy); events[0] != nil || events[1] != nil {
+		t.Fatalf("got events %v, want none", events)
+	}
+
+	// a later upgrade fails
+	csv.Status.Phase = olmv1alpha1.CSVPhaseFailed
+	assertNoError(t, fakeClient.Update(context.TODO(), csv))
+	events := make(chan HealthEvent, 2)
+	d.OnHealthChange = func(event HealthEvent) { events <- event }
+	ctx, cancel := context.WithCancel(context.TODO())
+	done := make(chan struct{})
+	go func() {
+		d.Supervise(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+	select {
+	case event := <-events:
+		want := HealthEvent{Operator: argocd.name, Namespace: "argocd", CSV: argocd.csv, Phase: olmv1alpha1.CSVPhaseFailed,
+			Message: fmt.Sprintf("CSV %s in namespace argocd is Failed", argocd.csv)}
+		if diff := cmp.Diff(want, event); diff != "" {
+			t.Fatalf("health event mismatch: %v", diff)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the failed CSV was not reported")
+	}
+	cancel()
+	<-done
+	assertCondition(t, fakeClient, key, "ArgoCDOperatorReady", corev1.ConditionFalse, ReasonUnhealthy)
+	if len(events) != 0 {
+		t.Fatalf("got unexpected events after the failure: %v", <-events)
+	}
+
+	// recovering is reported once
+	csv.Status.Phase = olmv1alpha1.CSVPhaseSucceeded
+	assertNoError(t, fakeClient.Update(context.TODO(), csv))
+	healthy = map[string]bool{argocd.name: false}
+	if got := d.checkHealth(context.TODO(), healthy); got[0] == nil || !got[0].Healthy {
+		t.Fatalf("got events %v, want argocd to have recovered", got)
+	}
+	if got := d.checkHealth(context.TODO(), healthy); got[0] != nil {
+		t.Fatalf("got event %v, want none once recovered", got[0])
+	}
+}
+
+func TestInstall_pull_secrets(t *testing.T) {
+	operator := newArgoCDOperator("")
+	source := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: "gitops"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`)},
+	}
+	// the default service account may already have been created with its token
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Name: "default", Namespace: "argocd"},
+		ImagePullSecrets: []corev1.LocalObjectReference{{Name: "default-dockercfg-abcde"}},
+	}
+	fakeClient := newFakeClient(t, source, serviceAccount, newCSV(operator.csv, "argocd", olmv1alpha1.CSVPhaseSucceeded))
+	d := newTestDependency(fakeClient, "")
+	assertNoError(t, WithPullSecrets("gitops", "registry")(d))
+
+	for i := 0; i < 2; i++ {
+		_, err := d.install(context.TODO(), operator)
+		assertNoError(t, err)
+	}
+
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "default", Namespace: "argocd"}, serviceAccount))
+	want := []corev1.LocalObjectReference{{Name: "default-dockercfg-abcde"}, {Name: "registry"}}
+	if diff := cmp.Diff(want, serviceAccount.ImagePullSecrets); diff != "" {
+		t.Fatalf("pull secrets mismatch: %v", diff)
+	}
+	secret := &corev1.Secret{}
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "registry", Namespace: "argocd"}, secret))
+	if diff := cmp.Diff(source.Data, secret.Data); diff != "" || secret.Type != source.Type {
+		t.Fatalf("copied pull secret mismatch: %v", diff)
+	}
+
+	// the default service account of a namespace just created may not exist yet
+	sealedSecrets := newSealedSecretsOperator("")
+	assertNoError(t, fakeClient.Create(context.TODO(), newCSV(sealedSecrets.csv, "cicd", olmv1alpha1.CSVPhaseSucceeded)))
+	_, err := d.install(context.TODO(), sealedSecrets)
+	assertNoError(t, err)
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "default", Namespace: "cicd"}, serviceAccount))
+	if diff := cmp.Diff([]corev1.LocalObjectReference{{Name: "registry"}}, serviceAccount.ImagePullSecrets); diff != "" {
+		t.Fatalf("pull secrets mismatch: %v", diff)
+	}
+}
+
+func TestInstall_cluster_extension_backend(t *testing.T) {
+	operator := newArgoCDOperator("")
+	fakeClient := newFakeClient(t)
+	d := newTestDependency(fakeClient, "")
+	d.Backend = BackendClusterExtension
+	d.operators = []operatorResource{operator}
+
+	// simulate OLM v1 installing the extension
+	go func() {
+		extension := newUnstructured(clusterExtensionGVK)
+		for fakeClient.Get(context.TODO(), types.NamespacedName{Name: operator.name}, extension) != nil {
+			time.Sleep(100 * time.Millisecond)
+		}
+		conditions := []interface{}{map[string]interface{}{"type": "Installed", "status": "True"}}
+		if err := unstructured.SetNestedSlice(extension.Object, conditions, "status", "conditions"); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := fakeClient.Update(context.TODO(), extension); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	assertNoError(t, d.Install())
+
+	extension := newUnstructured(clusterExtensionGVK)
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: operator.name}, extension))
+	want := map[string]interface{}{
+		"namespace":      "argocd",
+		"serviceAccount": map[string]interface{}{"name": "argocd-operator-installer"},
+		"source": map[string]interface{}{
+			"sourceType": "Catalog",
+			"catalog": map[string]interface{}{
+				"packageName": operator.packageName,
+				"channels":    []interface{}{operator.channel},
+				"version":     ">=" + strings.TrimPrefix(operator.csv, "argocd-operator.v"),
+			},
+		},
+	}
+	if diff := cmp.Diff(want, extension.Object["spec"]); diff != "" {
+		t.Fatalf("ClusterExtension spec mismatch: %v", diff)
+	}
+	assertNoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "argocd-operator-installer", Namespace: "argocd"}, &corev1.ServiceAccount{}))
+	err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: operator.name, Namespace: "argocd"}, &olmv1alpha1.Subscription{})
+	if !errors.IsNotFound(err) {
+		t.Fatalf("got error %v, want no Subscription with the ClusterExtension backend", err)
+	}
+
+	assertNoError(t, d.Uninstall())
+	err = fakeClient.Get(context.TODO(), types.NamespacedName{Name: operator.name}, newUnstructured(clusterExtensionGVK))
+	if !errors.IsNotFound(err) {
+		t.Fatalf("got error %v, want the ClusterExtension to be deleted", err)
+	}
+}
+
+func TestDetectBackend(t *testing.T) {
+	subscriptionGVK := olmv1alpha1.SchemeGroupVersion.WithKind(olmv1alpha1.SubscriptionKind)
+	tests := []struct {
+		name  string
+		kinds []schema.GroupVersionKind
+		want  Backend
+	}{
+		{"OLM v0", []schema.GroupVersionKind{subscriptionGVK}, BackendSubscription},
+		{"OLM v0 and v1", []schema.GroupVersionKind{subscriptionGVK, clusterExtensionGVK}, BackendSubscription},
+		{"OLM v1", []schema.GroupVersionKind{clusterExtensionGVK}, BackendClusterExtension},
+		{"no OLM", nil, BackendSubscription},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := meta.NewDefaultRESTMapper(nil)
+			for _, gvk := range tt.kinds {
+				mapper.Add(gvk, meta.RESTScopeRoot)
+			}
+			if got := DetectBackend(mapper); got != tt.want {
+				t.Fatalf("got backend %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
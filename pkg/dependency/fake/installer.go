@@ -0,0 +1,64 @@
+package fake
+
+import (
+	"context"
+	"sync"
+
+	"github.com/redhat-developer/gitops-operator/pkg/dependency"
+)
+
+// blank assignment to verify that Installer implements dependency.Installer
+var _ dependency.Installer = &Installer{}
+
+// Installer is an in-memory dependency.Installer for tests. It records the
+// calls made to it and returns the configured errors.
+type Installer struct {
+	// InstallErr is returned from Install
+	InstallErr error
+	// UninstallErr is returned from Uninstall
+	UninstallErr error
+
+	mu             sync.Mutex
+	installCalls   int
+	uninstallCalls int
+}
+
+// NewInstaller returns an Installer whose calls always succeed
+func NewInstaller() *Installer {
+	return &Installer{}
+}
+
+// Install records the call and returns InstallErr
+func (i *Installer) Install() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.installCalls++
+	return i.InstallErr
+}
+
+// InstallContext records the call as an Install and returns InstallErr
+func (i *Installer) InstallContext(ctx context.Context) error {
+	return i.Install()
+}
+
+// Uninstall records the call and returns UninstallErr
+func (i *Installer) Uninstall() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.uninstallCalls++
+	return i.UninstallErr
+}
+
+// InstallCalls returns the number of times Install has been called
+func (i *Installer) InstallCalls() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.installCalls
+}
+
+// UninstallCalls returns the number of times Uninstall has been called
+func (i *Installer) UninstallCalls() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.uninstallCalls
+}
@@ -0,0 +1,107 @@
+package dependency
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	olmv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// HealthEvent reports that an installed operator became unhealthy, e.g. its
+// CSV failed during an upgrade, or recovered
+type HealthEvent struct {
+	Operator  string
+	Namespace string
+	// CSV is empty if the CSV of the operator is gone
+	CSV     string
+	Phase   olmv1alpha1.ClusterServiceVersionPhase
+	Healthy bool
+	Message string
+}
+
+// Supervise checks the health of the installed operators every interval
+// until ctx is cancelled. Whenever the health of an operator changes, its
+// readiness condition is set on StatusObject and OnHealthChange is called.
+func (d *Dependency) Supervise(ctx context.Context, interval time.Duration) {
+	log.Info("Supervising operators", "Interval", interval)
+	healthy := map[string]bool{}
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		for i, event := range d.checkHealth(ctx, healthy) {
+			if event == nil {
+				continue
+			}
+			operator := d.operators[i]
+			if event.Healthy {
+				log.Info("Operator recovered", "Operator", event.Operator, "Namespace", event.Namespace, "CSV", event.CSV)
+				d.setCondition(ctx, operator, corev1.ConditionTrue, ReasonCSVSucceeded, event.Message)
+			} else {
+				log.Info("Operator unhealthy", "Operator", event.Operator, "Namespace", event.Namespace, "CSV", event.CSV, "Phase", event.Phase)
+				d.setCondition(ctx, operator, corev1.ConditionFalse, ReasonUnhealthy, event.Message)
+			}
+			if d.OnHealthChange != nil {
+				d.OnHealthChange(*event)
+			}
+		}
+	}, interval)
+}
+
+// checkHealth evaluates the CSV of each operator and returns, indexed like
+// the operators, an event for those whose health changed since the previous
+// check recorded in healthy, nil for the others. Operators are healthy once
+// their CSV has succeeded and unhealthy if it failed or is gone, other phases,
+// e.g. while an upgrade is installing, don't change their health. Operators
+// which have never been seen installed are left to Install.
+func (d *Dependency) checkHealth(ctx context.Context, healthy map[string]bool) []*HealthEvent {
+	events := make([]*HealthEvent, len(d.operators))
+	for i, operator := range d.operators {
+		version, err := d.installedVersion(ctx, operator)
+		if err != nil {
+			log.Error(err, "Failed to check operator health", "Operator", operator.name)
+			continue
+		}
+		wasHealthy, seen := healthy[operator.name]
+		var isHealthy bool
+		switch {
+		case version.InstalledCSV == "":
+			if !seen {
+				continue
+			}
+			isHealthy = false
+		case version.Phase == olmv1alpha1.CSVPhaseSucceeded:
+			isHealthy = true
+		case version.Phase == olmv1alpha1.CSVPhaseFailed:
+			isHealthy = false
+		default:
+			continue
+		}
+		healthy[operator.name] = isHealthy
+		// operators found healthy on the first check are not reported
+		if (seen && isHealthy == wasHealthy) || (!seen && isHealthy) {
+			continue
+		}
+		events[i] = &HealthEvent{
+			Operator:  operator.name,
+			Namespace: version.Namespace,
+			CSV:       version.InstalledCSV,
+			Phase:     version.Phase,
+			Healthy:   isHealthy,
+			Message:   healthMessage(version),
+		}
+	}
+	return events
+}
+
+func healthMessage(version OperatorVersion) string {
+	csv := types.NamespacedName{Name: version.InstalledCSV, Namespace: version.Namespace}
+	switch {
+	case version.InstalledCSV == "":
+		return fmt.Sprintf("CSV of operator %s not found in namespace %s", version.Operator, version.Namespace)
+	case version.Phase == olmv1alpha1.CSVPhaseSucceeded:
+		return succeededMessage(csv)
+	}
+	return fmt.Sprintf("CSV %s in namespace %s is %s", csv.Name, csv.Namespace, version.Phase)
+}
@@ -0,0 +1,120 @@
+package dependency
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Option configures a Dependency created by NewClient
+type Option func(*Dependency) error
+
+// WithPrefix installs the operators into namespaces prefixed with prefix
+func WithPrefix(prefix string) Option {
+	return func(d *Dependency) error {
+		d.prefix = prefix
+		return nil
+	}
+}
+
+// WithNamespaces installs the operators listed in namespaces, keyed by
+// operator name, into the given namespace verbatim, regardless of prefix
+func WithNamespaces(namespaces map[string]string) Option {
+	return func(d *Dependency) error {
+		for name, namespace := range namespaces {
+			if err := d.updateOperator(name, func(o *operatorResource) { o.namespaceOverride = namespace }); err != nil {
+				return fmt.Errorf("invalid namespace override: %w", err)
+			}
+		}
+		return nil
+	}
+}
+
+// WithSharedNamespace installs all the operators into namespace, see
+// Dependency.SharedNamespace
+func WithSharedNamespace(namespace string) Option {
+	return func(d *Dependency) error {
+		d.SharedNamespace = namespace
+		return nil
+	}
+}
+
+// WithPullSecrets links the secrets with names, copied from namespace if it
+// is not empty, to the default service account of the operator namespaces,
+// see Dependency.PullSecrets
+func WithPullSecrets(namespace string, names ...string) Option {
+	return func(d *Dependency) error {
+		d.PullSecretsNamespace = namespace
+		d.PullSecrets = names
+		return nil
+	}
+}
+
+// WithBackend installs the operators with backend, see DetectBackend
+func WithBackend(backend Backend) Option {
+	return func(d *Dependency) error {
+		switch backend {
+		case BackendSubscription, BackendClusterExtension:
+			d.Backend = backend
+			return nil
+		}
+		return fmt.Errorf("invalid backend %q: must be %s or %s", backend, BackendSubscription, BackendClusterExtension)
+	}
+}
+
+// WithTimeout sets how long Install waits for each operator to be ready
+func WithTimeout(timeout time.Duration) Option {
+	return func(d *Dependency) error {
+		if timeout <= 0 {
+			return fmt.Errorf("invalid timeout %v: must be positive", timeout)
+		}
+		d.timeout = timeout
+		return nil
+	}
+}
+
+// WithChannel subscribes the operator with name to channel
+func WithChannel(name, channel string) Option {
+	return func(d *Dependency) error {
+		return d.updateOperator(name, func(o *operatorResource) { o.channel = channel })
+	}
+}
+
+// WithEnvironment sets the environment of the cluster, see
+// Dependency.Environment
+func WithEnvironment(environment string) Option {
+	return func(d *Dependency) error {
+		d.Environment = environment
+		return nil
+	}
+}
+
+// WithStatusObject sets the readiness conditions of the operators on the
+// status of the GitopsService with key, see Dependency.StatusObject
+func WithStatusObject(key types.NamespacedName) Option {
+	return func(d *Dependency) error {
+		d.StatusObject = &key
+		return nil
+	}
+}
+
+// WithCatalogSource installs the operators from the CatalogSource described
+// by config, see Dependency.CatalogSource
+func WithCatalogSource(config *CatalogSourceConfig) Option {
+	return func(d *Dependency) error {
+		d.CatalogSource = config
+		return nil
+	}
+}
+
+// updateOperator applies update to the configured operator with name
+func (d *Dependency) updateOperator(name string, update func(*operatorResource)) error {
+	for i := range d.operators {
+		if d.operators[i].name == name {
+			update(&d.operators[i])
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown operator %s", name)
+}
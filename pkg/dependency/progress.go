@@ -0,0 +1,31 @@
+package dependency
+
+// InstallStage is a step of the installation of an operator
+type InstallStage string
+
+const (
+	// StageNamespaceCreated is reported once the operator namespace exists
+	StageNamespaceCreated InstallStage = "NamespaceCreated"
+	// StageSubscriptionCreated is reported once the operator subscription exists
+	StageSubscriptionCreated InstallStage = "SubscriptionCreated"
+	// StageWaiting is reported when Install starts waiting for the operator CSV
+	StageWaiting InstallStage = "Waiting"
+	// StageReady is reported once the operator CSV has succeeded
+	StageReady InstallStage = "Ready"
+)
+
+// ProgressEvent reports that an operator reached a stage of its installation
+type ProgressEvent struct {
+	Operator  string
+	Namespace string
+	Stage     InstallStage
+	// CSV is the ClusterServiceVersion waited for, set from StageWaiting on
+	CSV string
+}
+
+// progress calls the OnProgress callback, if any, with event
+func (d *Dependency) progress(event ProgressEvent) {
+	if d.OnProgress != nil {
+		d.OnProgress(event)
+	}
+}
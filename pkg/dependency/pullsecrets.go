@@ -0,0 +1,97 @@
+package dependency
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+// defaultServiceAccount is the service account of the operator namespaces
+// the pull secrets are linked to
+const defaultServiceAccount = "default"
+
+// ensurePullSecrets copies the configured pull secrets into the operator
+// namespace, unless they already exist there, and links them to its default
+// service account so that images can be pulled from a private registry
+func (d *Dependency) ensurePullSecrets(ctx context.Context, namespace, operator string) error {
+	if len(d.PullSecrets) == 0 {
+		return nil
+	}
+	if d.PullSecretsNamespace != "" && d.PullSecretsNamespace != namespace {
+		for _, name := range d.PullSecrets {
+			if err := d.copyPullSecret(ctx, name, namespace, operator); err != nil {
+				return err
+			}
+		}
+	}
+
+	key := types.NamespacedName{Name: defaultServiceAccount, Namespace: namespace}
+	// the default service account is created asynchronously once the
+	// namespace exists, it may be created concurrently or be updated by
+	// the token controller
+	return retry.OnError(retry.DefaultRetry, func(err error) bool {
+		return errors.IsConflict(err) || errors.IsAlreadyExists(err)
+	}, func() error {
+		serviceAccount := &corev1.ServiceAccount{}
+		err := d.client.Get(ctx, key, serviceAccount)
+		if errors.IsNotFound(err) {
+			serviceAccount = &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}}
+			serviceAccount.ImagePullSecrets = missingPullSecrets(serviceAccount, d.PullSecrets)
+			log.Info("Creating service account with pull secrets", "ServiceAccount", keyString(key), "PullSecrets", d.PullSecrets)
+			return d.client.Create(ctx, serviceAccount)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get ServiceAccount %s: %w", keyString(key), err)
+		}
+		missing := missingPullSecrets(serviceAccount, d.PullSecrets)
+		if len(missing) == 0 {
+			return nil
+		}
+		log.Info("Linking pull secrets to service account", "ServiceAccount", keyString(key), "PullSecrets", missing)
+		serviceAccount.ImagePullSecrets = append(serviceAccount.ImagePullSecrets, missing...)
+		return d.client.Update(ctx, serviceAccount)
+	})
+}
+
+// copyPullSecret copies the pull secret with name from PullSecretsNamespace
+// into namespace. A secret which already exists in namespace is left as is.
+func (d *Dependency) copyPullSecret(ctx context.Context, name, namespace, operator string) error {
+	source := &corev1.Secret{}
+	sourceKey := types.NamespacedName{Name: name, Namespace: d.PullSecretsNamespace}
+	if err := d.client.Get(ctx, sourceKey, source); err != nil {
+		return fmt.Errorf("failed to get pull secret %s: %w", keyString(sourceKey), err)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    dependencyLabels(operator),
+		},
+		Type: source.Type,
+		Data: source.Data,
+	}
+	_, err := createResourceIfAbsent(ctx, d.client, secret, types.NamespacedName{Name: name, Namespace: namespace})
+	return err
+}
+
+// missingPullSecrets returns the references to the secrets with names which
+// are not yet linked to the service account
+func missingPullSecrets(serviceAccount *corev1.ServiceAccount, names []string) []corev1.LocalObjectReference {
+	linked := map[string]bool{}
+	for _, ref := range serviceAccount.ImagePullSecrets {
+		linked[ref.Name] = true
+	}
+	var missing []corev1.LocalObjectReference
+	for _, name := range names {
+		if !linked[name] {
+			linked[name] = true
+			missing = append(missing, corev1.LocalObjectReference{Name: name})
+		}
+	}
+	return missing
+}